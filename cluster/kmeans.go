@@ -5,6 +5,7 @@
 package cluster
 
 import (
+	"errors"
 	"fmt"
 	"gonum.org/v1/gonum/mat"
 	"math"
@@ -25,6 +26,15 @@ func KMeans(df *utils.DataFrame, nclust int) []int {
 		fmt.Println("Not enough data")
 		return []int{}
 	}
+	clusters, _ := kmeans(m, nclust, maxIterations, rand.Intn, true)
+	return clusters
+}
+
+// KMeans clustering of the rows of a matrix, returns the cluster number of
+// each row, and the final centroids. Uses the random function to assign
+// initial clusters, and shows progress if verbose.
+func kmeans(m *mat.Dense, nclust, maxIter int, random func(n int) int, verbose bool) ([]int, [][]float64) {
+	nr, nc := m.Dims()
 
 	// Initialize array of centroids
 	centroids := make([][]float64, nclust, nclust)
@@ -36,9 +46,11 @@ func KMeans(df *utils.DataFrame, nclust int) []int {
 	// assigned to a random cluster
 	clusters := make([]int, nr, nr)
 	for i := 0; i < nr; i++ {
-		clusters[i] = rand.Intn(nclust)
+		clusters[i] = random(nclust)
+	}
+	if verbose {
+		fmt.Println("Initial assignments:", clusters)
 	}
-	fmt.Println("Initial assignments:", clusters)
 
 	// Begin iterations
 	iter := 0
@@ -67,7 +79,9 @@ func KMeans(df *utils.DataFrame, nclust int) []int {
 				}
 			}
 		}
-		fmt.Println("Iteration", iter, ": Centroids =", centroids)
+		if verbose {
+			fmt.Println("Iteration", iter, ": Centroids =", centroids)
+		}
 
 		// Assign each row to the closest cluster
 		moved := false
@@ -82,13 +96,53 @@ func KMeans(df *utils.DataFrame, nclust int) []int {
 
 		// Stop when no more movement, or max iterations reached
 		iter++
-		if !moved || iter > maxIterations {
+		if !moved || iter > maxIter {
 			break
 		}
 	}
 
 	// Return final cluster assignments
-	return clusters
+	return clusters, centroids
+}
+
+// KMeans clustering model on matrices, for the utils.Estimator interface
+type KMeansModel struct {
+	K             int   // number of clusters
+	MaxIterations int   // maximum iterations, default 1000
+	Seed          int64 // random seed for initial cluster assignments
+	Verbose       bool  // show progress during training
+	centroids     [][]float64
+}
+
+// Find the clusters in X, Y is not used and may be nil
+func (km *KMeansModel) Fit(X, Y *mat.Dense) error {
+	if X == nil || X.IsEmpty() {
+		return errors.New("KMeans: no data to fit")
+	}
+	if km.K < 1 || km.K > utils.MatRows(X) {
+		return fmt.Errorf("KMeans: invalid number of clusters %d", km.K)
+	}
+	if km.MaxIterations <= 0 {
+		km.MaxIterations = maxIterations
+	}
+	rng := rand.New(rand.NewSource(km.Seed))
+	_, km.centroids = kmeans(X, km.K, km.MaxIterations, rng.Intn, km.Verbose)
+	return nil
+}
+
+// Predict the cluster number for each row, i.e., the closest centroid
+func (km *KMeansModel) Predict(X *mat.Dense) *mat.Dense {
+	nr, _ := X.Dims()
+	res := mat.NewDense(nr, 1, nil)
+	for i := 0; i < nr; i++ {
+		res.Set(i, 0, float64(closestCluster(X.RowView(i), km.centroids)))
+	}
+	return res
+}
+
+// The centroid of each cluster, after fitting
+func (km *KMeansModel) Centroids() [][]float64 {
+	return km.centroids
 }
 
 // Find the index of the closest cluster centroid for a row
@@ -114,3 +168,23 @@ func distance(r mat.Vector, p []float64) float64 {
 	}
 	return math.Sqrt(d)
 }
+
+// Version of the saved centroids, to be increased when the saved data
+// changes in an incompatible way
+const kmeansVersion = 1
+
+// Save the centroids of a trained model to a file, as JSON if the file
+// name ends in .json, otherwise in binary gob format
+func (km *KMeansModel) Save(filename string) error {
+	return utils.SaveModel(filename, "KMeans", kmeansVersion, km.centroids)
+}
+
+// Load the centroids of a trained model from a file
+func (km *KMeansModel) Load(filename string) error {
+	var centroids [][]float64
+	if err := utils.LoadModel(filename, "KMeans", kmeansVersion, &centroids); err != nil {
+		return err
+	}
+	km.centroids, km.K = centroids, len(centroids)
+	return nil
+}
@@ -0,0 +1,158 @@
+// AdaBoost: a weighted vote of small decision trees, usually stumps (one
+// split), each trained on the same rows with different weights. After each
+// tree, the rows it got wrong are given more weight, so the next tree
+// concentrates on them, and the tree's vote is weighted by how accurate it
+// was. For more than two classes this is SAMME (Zhu et al., 2009), as in
+// AdaBoostClassifier in scikit-learn, which for two classes is the same as
+// the original AdaBoost:
+//
+//	error = total weight of the rows predicted wrong / total weight
+//	alpha = learning rate * (log((1 - error) / error) + log(classes - 1))
+//	weight of each row predicted wrong *= exp(alpha)
+//
+// Sample usage:
+//
+//	df, _ := utils.ReadCSV("data/iris.csv")
+//	model, err := TrainAdaBoost(df, "variety", AdaParams{NRounds: 50})
+//	preds := model.PredictLabels(df)
+
+package decision_tree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"mlcode/utils"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Settings for AdaBoost, zero values use the defaults
+type AdaParams struct {
+	NRounds      int     // maximum number of trees, default 50
+	LearningRate float64 // multiplies the vote of each tree, default 1
+	MaxDepth     int     // depth of each tree, default 1 (stumps)
+	Verbose      bool    // whether to show the error of each tree
+}
+
+// Trained AdaBoost model
+type AdaBoost struct {
+	Classes []string  // class labels, in order
+	Trees   []Node    // the trees, in the order trained
+	Alphas  []float64 // weight of the vote of each tree
+	Errors  []float64 // weighted training error of each tree
+}
+
+// Train an AdaBoost classifier to predict a column of string labels.
+// Stops early if a tree has no errors, or is no better than chance.
+func TrainAdaBoost(df *utils.DataFrame, depv string, p AdaParams) (*AdaBoost, error) {
+	if p.NRounds < 0 || p.LearningRate < 0 || p.MaxDepth < 0 {
+		return nil, errors.New("settings must not be negative")
+	}
+	p.NRounds = utils.IfThenElse(p.NRounds == 0, 50, p.NRounds)
+	p.LearningRate = utils.IfThenElse(p.LearningRate == 0, 1, p.LearningRate)
+	p.MaxDepth = utils.IfThenElse(p.MaxDepth == 0, 1, p.MaxDepth)
+	col := df.GetColumn(depv)
+	if col == nil || col.Dtype != "string" {
+		return nil, errors.New("target " + depv + " must be a column of string labels")
+	}
+	m := AdaBoost{Classes: utils.Unique(col.Strings)}
+	k := float64(len(m.Classes))
+	if k < 2 {
+		return nil, errors.New("need at least two classes")
+	}
+
+	// Copy of the dataframe with a column for the weights, all the same
+	// to start with
+	n := df.NRows()
+	weights := make([]float64, n)
+	for i := range weights {
+		weights[i] = 1 / float64(n)
+	}
+	df2 := append(utils.DataFrame{}, *df...)
+	df2 = append(df2, utils.Series{Name: weightCol, Dtype: "float64", Floats: weights})
+	tp := TreeParams{MaxDepth: p.MaxDepth, MinLeaf: 1, WeightCol: weightCol}
+	cols := columnMap(df)
+
+	for round := 0; round < p.NRounds; round++ {
+
+		// Train a tree on the weighted rows, and find its weighted error
+		tree := DecisionTreeWith(&df2, depv, tp)
+		wrong := make([]bool, n)
+		var errTot, tot float64
+		for i := range wrong {
+			wrong[i] = leafAt(tree, cols, i).Value != col.Strings[i]
+			if wrong[i] {
+				errTot += weights[i]
+			}
+			tot += weights[i]
+		}
+		e := errTot / tot
+		if p.Verbose {
+			fmt.Printf("Tree %3d: weighted error %.4f\n", round+1, e)
+		}
+
+		// A perfect tree gets the only vote, replacing any trees before
+		// it, and a tree no better than chance is not used
+		if e <= 0 {
+			m.Trees, m.Alphas, m.Errors = nil, nil, nil
+			m.add(tree, 1, 0)
+			break
+		}
+		if e >= 1-1/k {
+			if round == 0 {
+				return nil, errors.New("first tree is no better than chance")
+			}
+			break
+		}
+
+		// Vote of the tree, and new weights of the rows
+		alpha := p.LearningRate * (math.Log((1-e)/e) + math.Log(k-1))
+		m.add(tree, alpha, e)
+		for i := range weights {
+			if wrong[i] {
+				weights[i] *= math.Exp(alpha)
+			}
+		}
+		floats.Scale(1/floats.Sum(weights), weights)
+	}
+	return &m, nil
+}
+
+// Add a tree to the model
+func (m *AdaBoost) add(tree *Node, alpha, e float64) {
+	m.Trees = append(m.Trees, *tree)
+	m.Alphas = append(m.Alphas, alpha)
+	m.Errors = append(m.Errors, e)
+}
+
+// Share of the weighted vote for each class, for each row of a dataframe,
+// one column per class in the order of Classes
+func (m *AdaBoost) PredictProba(df *utils.DataFrame) *mat.Dense {
+	index := map[string]int{}
+	for i, c := range m.Classes {
+		index[c] = i
+	}
+	res := mat.NewDense(df.NRows(), len(m.Classes), nil)
+	cols := columnMap(df)
+	tot := floats.Sum(m.Alphas)
+	for t := range m.Trees {
+		for i := 0; i < df.NRows(); i++ {
+			k := index[leafAt(&m.Trees[t], cols, i).Value]
+			res.Set(i, k, res.At(i, k)+m.Alphas[t]/tot)
+		}
+	}
+	return res
+}
+
+// Predict the class with the most weighted votes for each row of a
+// dataframe
+func (m *AdaBoost) PredictLabels(df *utils.DataFrame) []string {
+	votes := m.PredictProba(df)
+	res := make([]string, df.NRows())
+	for i := range res {
+		res[i] = m.Classes[floats.MaxIdx(votes.RawRowView(i))]
+	}
+	return res
+}
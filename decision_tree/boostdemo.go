@@ -0,0 +1,79 @@
+// Demo of gradient boosting and AdaBoost
+
+package decision_tree
+
+import (
+	"fmt"
+	"mlcode/metrics"
+	"mlcode/utils"
+)
+
+// Predict Titanic survival and fares with gradient boosting, stopping
+// early when the loss on held out rows stops improving
+func GradientBoostDemo() {
+
+	// Hold out 20% of the rows for testing, same survival rate in both
+	df := GetTitanicData("data/titanic.csv")
+	train, test := utils.TrainTestSplit(df, .2, "Survived", 42)
+
+	// Train with up to 500 rounds, holding out another 20% for early
+	// stopping
+	fmt.Println("Training gradient boosting to predict Survived")
+	p := BoostParams{NRounds: 500, Subsample: .8, ValidationFrac: .2, Verbose: true}
+	b, err := GradientBoost(train, "Survived", p)
+	if err != nil {
+		panic(err)
+	}
+	actual := test.GetColumn("Survived").Strings
+	preds := b.PredictLabels(test)
+	metrics.Confusion(actual, preds).Print()
+	fmt.Println("Accuracy =", metrics.Accuracy(actual, preds))
+	b.FeatureImportance().Print()
+
+	// Predict fares, without the survival column
+	fmt.Println("\nTraining gradient boosting to predict Fare")
+	train = train.DropColumns([]string{"Survived"})
+	b, err = GradientBoost(train, "Fare", p)
+	if err != nil {
+		panic(err)
+	}
+	fares := columnFloats(test.GetColumn("Fare"))
+	values := b.PredictValues(test)
+	fmt.Printf("Fare: RMSE = %.3f, R2 = %.3f\n", metrics.RMSE(fares, values), metrics.R2(fares, values))
+	b.FeatureImportance().Print()
+}
+
+// Classify irises and Titanic survival with AdaBoost on decision stumps
+func AdaBoostDemo() {
+
+	// Iris: three classes, so this is SAMME
+	df, err := utils.ReadCSV("data/iris.csv")
+	if err != nil {
+		panic(err)
+	}
+	train, test := utils.TrainTestSplit(df, .2, "variety", 42)
+	m, err := TrainAdaBoost(train, "variety", AdaParams{NRounds: 50})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("Iris: first three stumps, and their votes")
+	for i := 0; i < 3 && i < len(m.Trees); i++ {
+		fmt.Printf("Vote %.3f, weighted error %.3f\n", m.Alphas[i], m.Errors[i])
+		PrintTree(&m.Trees[i], 1)
+	}
+	actual, preds := test.GetColumn("variety").Strings, m.PredictLabels(test)
+	metrics.Confusion(actual, preds).Print()
+	fmt.Println("Accuracy =", metrics.Accuracy(actual, preds))
+
+	// Titanic, two classes
+	df = GetTitanicData("data/titanic.csv")
+	train, test = utils.TrainTestSplit(df, .2, "Survived", 42)
+	m, err = TrainAdaBoost(train, "Survived", AdaParams{NRounds: 200, LearningRate: .5})
+	if err != nil {
+		panic(err)
+	}
+	actual, preds = test.GetColumn("Survived").Strings, m.PredictLabels(test)
+	fmt.Printf("\nTitanic: %d stumps\n", len(m.Trees))
+	metrics.Confusion(actual, preds).Print()
+	fmt.Println("Accuracy =", metrics.Accuracy(actual, preds))
+}
@@ -0,0 +1,449 @@
+// Gradient boosting: a sequence of small trees, each one fitted to the
+// errors of the ones before it, as in HistGradientBoostingClassifier and
+// HistGradientBoostingRegressor in scikit-learn, or LightGBM. The model
+// keeps a score for each row, starting from the same value for all rows.
+// Each round, the gradient and hessian (first and second derivatives) of
+// the loss with respect to the score are worked out for every row, and a
+// tree is fitted to them, with each leaf holding the Newton step for its
+// rows, scaled down by the learning rate. The tree's prediction is then
+// added to the scores. Trees are built from histograms of binned values,
+// see histogram.go, so training is fast even on large datasets.
+//
+// Losses:
+//   - LossSquared: squared error, for a numeric target, the score is the
+//     prediction
+//   - LossLogistic: log loss for two classes, the score is the log odds
+//     of the second class
+//   - LossSoftmax: cross-entropy for any number of classes, with one tree
+//     per class each round, and a score per class
+//
+// Sample usage:
+//
+//	df := GetTitanicData("data/titanic.csv")
+//	b, err := GradientBoost(df, "Survived", BoostParams{NRounds: 200, ValidationFrac: .1})
+//	preds := b.PredictLabels(df)
+//	b.FeatureImportance().Print()
+
+package decision_tree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"mlcode/metrics"
+	"mlcode/utils"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Loss functions for gradient boosting
+const (
+	LossSquared  = "squared"
+	LossLogistic = "logistic"
+	LossSoftmax  = "softmax"
+)
+
+// Settings for gradient boosting, zero values use the defaults
+type BoostParams struct {
+	Loss         string  // LossSquared, LossLogistic or LossSoftmax, default from the target column
+	NRounds      int     // maximum number of boosting rounds, default 100
+	LearningRate float64 // how much of each tree's prediction to add, default .1
+	MaxDepth     int     // maximum depth of each tree, default 3
+	MinLeaf      int     // minimum number of rows in a leaf, default 20
+	MaxBins      int     // maximum number of bins per column, at most 255, default 255
+	L2           float64 // L2 regularization of the leaf values, default 0
+	Subsample    float64 // fraction of rows used for each round, chosen at random, default 1
+
+	// Early stopping: hold out some of the rows, and stop when the loss on
+	// them has not improved for some rounds, keeping the best rounds
+	ValidationFrac float64 // fraction of rows held out, default 0 (no early stopping)
+	EarlyStop      int     // rounds without improvement before stopping, default 10
+	Tol            float64 // smallest change that counts as an improvement, default 1e-7
+
+	Seed    int64 // for subsampling and the held out rows
+	Verbose bool  // whether to show the loss every 10 rounds
+}
+
+// Trained gradient boosting model
+type Booster struct {
+	Loss       string             // loss function
+	Classes    []string           // class labels, in order, for classification
+	Init       []float64          // starting score, one per class for softmax, otherwise one
+	Trees      [][]Node           // trees of each round, one per class for softmax, otherwise one
+	Importance map[string]float64 // total gain of the splits on each column
+	TrainLoss  []float64          // loss on the training rows after each round
+	ValidLoss  []float64          // loss on the held out rows after each round, if any
+}
+
+// Fill in any settings not set with the defaults, and check them
+func (p BoostParams) withDefaults() (BoostParams, error) {
+	if p.Loss != "" && p.Loss != LossSquared && p.Loss != LossLogistic && p.Loss != LossSoftmax {
+		return p, fmt.Errorf("unknown loss %q", p.Loss)
+	}
+	if p.NRounds < 0 || p.LearningRate < 0 || p.MaxDepth < 0 || p.MinLeaf < 0 || p.L2 < 0 || p.EarlyStop < 0 || p.Tol < 0 {
+		return p, errors.New("settings must not be negative")
+	}
+	if p.MaxBins < 0 || p.MaxBins == 1 || p.MaxBins > 255 {
+		return p, fmt.Errorf("MaxBins must be from 2 to 255, got %d", p.MaxBins)
+	}
+	if p.Subsample < 0 || p.Subsample > 1 {
+		return p, fmt.Errorf("Subsample must be from 0 to 1, got %v", p.Subsample)
+	}
+	if p.ValidationFrac < 0 || p.ValidationFrac >= 1 {
+		return p, fmt.Errorf("ValidationFrac must be from 0 to less than 1, got %v", p.ValidationFrac)
+	}
+	p.NRounds = utils.IfThenElse(p.NRounds == 0, 100, p.NRounds)
+	p.LearningRate = utils.IfThenElse(p.LearningRate == 0, .1, p.LearningRate)
+	p.MaxDepth = utils.IfThenElse(p.MaxDepth == 0, 3, p.MaxDepth)
+	p.MinLeaf = utils.IfThenElse(p.MinLeaf == 0, 20, p.MinLeaf)
+	p.MaxBins = utils.IfThenElse(p.MaxBins == 0, 255, p.MaxBins)
+	p.Subsample = utils.IfThenElse(p.Subsample == 0, 1, p.Subsample)
+	p.EarlyStop = utils.IfThenElse(p.EarlyStop == 0, 10, p.EarlyStop)
+	p.Tol = utils.IfThenElse(p.Tol == 0, 1e-7, p.Tol)
+	return p, nil
+}
+
+// Train a gradient boosting model to predict a column of a dataframe. A
+// string column is a classification target, with logistic loss for two
+// classes and softmax for more, otherwise the loss is squared error. If
+// ValidationFrac is set, that fraction of the rows (stratified by class)
+// is held out for early stopping.
+func GradientBoost(df *utils.DataFrame, depv string, p BoostParams) (*Booster, error) {
+	if p.ValidationFrac <= 0 {
+		return GradientBoostValidate(df, nil, depv, p)
+	}
+	col := df.GetColumn(depv)
+	if col == nil {
+		return nil, errors.New("no target column " + depv)
+	}
+	train, valid := utils.TrainTestSplit(df, p.ValidationFrac, utils.IfThenElse(col.Dtype == "string", depv, ""), p.Seed)
+	return GradientBoostValidate(train, valid, depv, p)
+}
+
+// Train a gradient boosting model, with early stopping on the given
+// validation rows if not nil, see GradientBoost
+func GradientBoostValidate(train, valid *utils.DataFrame, depv string, p BoostParams) (*Booster, error) {
+	p, err := p.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	col := train.GetColumn(depv)
+	if col == nil || train.NRows() == 0 {
+		return nil, errors.New("no target column " + depv + ", or no rows")
+	}
+
+	// Work out the loss and the target values: class numbers for
+	// classification
+	b := Booster{Loss: p.Loss}
+	var y, yValid []float64
+	if col.Dtype == "string" {
+		b.Classes = utils.Unique(col.Strings)
+		if len(b.Classes) < 2 {
+			return nil, errors.New("need at least two classes")
+		}
+		if b.Loss == "" {
+			b.Loss = utils.IfThenElse(len(b.Classes) == 2, LossLogistic, LossSoftmax)
+		}
+		if b.Loss == LossSquared || (b.Loss == LossLogistic && len(b.Classes) > 2) {
+			return nil, fmt.Errorf("loss %s does not work with %d classes", b.Loss, len(b.Classes))
+		}
+		y = b.classNumbers(col.Strings)
+		if valid != nil {
+			yValid = b.classNumbers(valid.GetColumn(depv).Strings)
+		}
+	} else {
+		if b.Loss != "" && b.Loss != LossSquared {
+			return nil, fmt.Errorf("loss %s needs a column of string labels", b.Loss)
+		}
+		b.Loss = LossSquared
+		y = columnFloats(col)
+		if valid != nil {
+			yValid = columnFloats(valid.GetColumn(depv))
+		}
+	}
+
+	// Bin the columns, and start with the same scores for all rows
+	features := makeBins(train, depv, p.MaxBins)
+	if len(features) == 0 {
+		return nil, errors.New("no columns to split on")
+	}
+	b.Init = b.initScores(y)
+	scores := b.startScores(train.NRows())
+	var validScores [][]float64
+	if valid != nil {
+		validScores = b.startScores(valid.NRows())
+	}
+
+	// Each round, fit a tree (one per class for softmax) to the gradients
+	// of a sample of the rows
+	rng := rand.New(rand.NewSource(p.Seed))
+	n := train.NRows()
+	gr := grower{p: &p, features: features, g: make([]float64, n), h: make([]float64, n)}
+	trainCols, validCols := columnMap(train), columnMap(valid)
+	bestRound, bestLoss := 0, math.Inf(1)
+	for round := 0; round < p.NRounds; round++ {
+		rows := seq(n)
+		if p.Subsample < 1 {
+			rows = rng.Perm(n)[:int(math.Max(1, p.Subsample*float64(n)))]
+		}
+		probs := b.probs(scores)
+		var trees []Node
+		for k := range scores {
+			b.gradients(k, y, scores, probs, gr.g, gr.h)
+			tree := gr.grow(rows, gr.histogram(rows), 0)
+			trees = append(trees, *tree)
+			addTree(tree, trainCols, scores[k])
+			if valid != nil {
+				addTree(tree, validCols, validScores[k])
+			}
+		}
+		b.Trees = append(b.Trees, trees)
+		b.TrainLoss = append(b.TrainLoss, b.loss(y, scores))
+
+		// Early stopping, keeping the rounds up to the best one
+		if valid != nil {
+			loss := b.loss(yValid, validScores)
+			b.ValidLoss = append(b.ValidLoss, loss)
+			if loss < bestLoss-p.Tol {
+				bestRound, bestLoss = round, loss
+			} else if round-bestRound >= p.EarlyStop {
+				if p.Verbose {
+					fmt.Printf("Stopping after %d rounds, best was %d, loss %.5f\n", round+1, bestRound+1, bestLoss)
+				}
+				b.Trees = b.Trees[:bestRound+1]
+				break
+			}
+		}
+		if p.Verbose && (round+1)%10 == 0 {
+			fmt.Printf("Round %4d: training loss %.5f", round+1, b.TrainLoss[round])
+			if valid != nil {
+				fmt.Printf(", validation loss %.5f", b.ValidLoss[round])
+			}
+			fmt.Println()
+		}
+	}
+
+	// Total gain of the splits on each column, in the rounds kept
+	b.Importance = map[string]float64{}
+	for _, f := range features {
+		b.Importance[f.name] = 0
+	}
+	for _, trees := range b.Trees {
+		for k := range trees {
+			addGains(&trees[k], b.Importance)
+		}
+	}
+	return &b, nil
+}
+
+// Add the gain of each split of a tree to the total for its column
+func addGains(tree *Node, gains map[string]float64) {
+	if !tree.IsLeaf() {
+		gains[tree.SplitVar] -= tree.G
+		addGains(tree.Left, gains)
+		addGains(tree.Right, gains)
+	}
+}
+
+// Class number of each label
+func (b *Booster) classNumbers(labels []string) []float64 {
+	index := map[string]int{}
+	for i, c := range b.Classes {
+		index[c] = i
+	}
+	res := make([]float64, len(labels))
+	for i, l := range labels {
+		res[i] = float64(index[l])
+	}
+	return res
+}
+
+// Best constant scores: the mean for squared error, otherwise the log
+// odds or log of the share of each class
+func (b *Booster) initScores(y []float64) []float64 {
+	if b.Loss == LossSquared {
+		return []float64{mean(y, nil)}
+	}
+	counts := make([]float64, len(b.Classes))
+	for _, c := range y {
+		counts[int(c)]++
+	}
+	for k := range counts {
+		counts[k] = math.Log(math.Max(counts[k], .5) / float64(len(y)))
+	}
+	if b.Loss == LossLogistic {
+		return []float64{counts[1] - counts[0]}
+	}
+	return counts
+}
+
+// Scores of n rows before any trees, one list per class for softmax
+func (b *Booster) startScores(n int) [][]float64 {
+	scores := make([][]float64, len(b.Init))
+	for k, v := range b.Init {
+		scores[k] = make([]float64, n)
+		for i := range scores[k] {
+			scores[k][i] = v
+		}
+	}
+	return scores
+}
+
+// Probabilities from the scores: the probability of the second class for
+// logistic loss, of each class for softmax, nil for squared error
+func (b *Booster) probs(scores [][]float64) [][]float64 {
+	switch b.Loss {
+	case LossLogistic:
+		p := make([]float64, len(scores[0]))
+		for i, s := range scores[0] {
+			p[i] = 1 / (1 + math.Exp(-s))
+		}
+		return [][]float64{p}
+	case LossSoftmax:
+		p := make([][]float64, len(scores))
+		for k := range p {
+			p[k] = make([]float64, len(scores[k]))
+		}
+		for i := range scores[0] {
+			mx := math.Inf(-1)
+			for k := range scores {
+				mx = math.Max(mx, scores[k][i])
+			}
+			var tot float64
+			for k := range scores {
+				p[k][i] = math.Exp(scores[k][i] - mx)
+				tot += p[k][i]
+			}
+			for k := range scores {
+				p[k][i] /= tot
+			}
+		}
+		return p
+	}
+	return nil
+}
+
+// Gradient and hessian of the loss for each row, with respect to the
+// score of class k
+func (b *Booster) gradients(k int, y []float64, scores, probs [][]float64, g, h []float64) {
+	for i := range g {
+		switch b.Loss {
+		case LossSquared:
+			g[i], h[i] = scores[0][i]-y[i], 1
+		case LossLogistic:
+			p := probs[0][i]
+			g[i], h[i] = p-y[i], p*(1-p)
+		case LossSoftmax:
+			p := probs[k][i]
+			g[i], h[i] = p-utils.IfThenElse(int(y[i]) == k, 1.0, 0.0), p*(1-p)
+		}
+	}
+}
+
+// Mean loss of the rows: mean squared error, or log loss (cross-entropy)
+func (b *Booster) loss(y []float64, scores [][]float64) float64 {
+	var tot float64
+	probs := b.probs(scores)
+	for i := range y {
+		switch b.Loss {
+		case LossSquared:
+			tot += (y[i] - scores[0][i]) * (y[i] - scores[0][i])
+		case LossLogistic:
+			tot += softplus(scores[0][i]) - y[i]*scores[0][i]
+		case LossSoftmax:
+			tot -= math.Log(math.Max(probs[int(y[i])][i], 1e-15))
+		}
+	}
+	return tot / float64(len(y))
+}
+
+// Numerically stable log(1 + e^x)
+func softplus(x float64) float64 {
+	if x > 0 {
+		return x + math.Log1p(math.Exp(-x))
+	}
+	return math.Log1p(math.Exp(x))
+}
+
+// Add the prediction of a tree to the score of each row
+func addTree(tree *Node, cols map[string]*utils.Series, scores []float64) {
+	for i := range scores {
+		scores[i] += leafAt(tree, cols, i).Mean
+	}
+}
+
+// Scores of the rows of a dataframe, one list per class for softmax
+func (b *Booster) scores(df *utils.DataFrame) [][]float64 {
+	scores := b.startScores(df.NRows())
+	cols := columnMap(df)
+	for _, trees := range b.Trees {
+		for k := range trees {
+			addTree(&trees[k], cols, scores[k])
+		}
+	}
+	return scores
+}
+
+// Predict a number for each row of a dataframe, for squared error loss
+func (b *Booster) PredictValues(df *utils.DataFrame) []float64 {
+	utils.Assert(b.Loss == LossSquared, "Booster: PredictValues is for regression")
+	return b.scores(df)[0]
+}
+
+// Predict the probability of each class for each row of a dataframe, one
+// column per class in the order of Classes, for classification
+func (b *Booster) PredictProba(df *utils.DataFrame) *mat.Dense {
+	utils.Assert(b.Loss != LossSquared, "Booster: PredictProba is for classification")
+	probs := b.probs(b.scores(df))
+	if b.Loss == LossLogistic {
+		probs = [][]float64{make([]float64, df.NRows()), probs[0]}
+		for i, p := range probs[1] {
+			probs[0][i] = 1 - p
+		}
+	}
+	res := mat.NewDense(df.NRows(), len(probs), nil)
+	for k := range probs {
+		res.SetCol(k, probs[k])
+	}
+	return res
+}
+
+// Predict the most likely class label for each row of a dataframe
+func (b *Booster) PredictLabels(df *utils.DataFrame) []string {
+	probs := b.PredictProba(df)
+	res := make([]string, df.NRows())
+	for i := range res {
+		res[i] = b.Classes[floats.MaxIdx(probs.RawRowView(i))]
+	}
+	return res
+}
+
+// Share of the total gain of all the splits on each column, adding up to
+// 1, as the "gain" feature importance of LightGBM and XGBoost
+func (b *Booster) FeatureImportance() metrics.ImportanceTable {
+	var tot float64
+	for _, g := range b.Importance {
+		tot += g
+	}
+	res := map[string]float64{}
+	for name, g := range b.Importance {
+		res[name] = g / math.Max(tot, math.SmallestNonzeroFloat64)
+	}
+	return metrics.NewImportanceTable(res)
+}
+
+// Number of rounds kept, after any early stopping
+func (b *Booster) NRounds() int {
+	return len(b.Trees)
+}
+
+// Row numbers 0 to n-1
+func seq(n int) []int {
+	res := make([]int, n)
+	for i := range res {
+		res[i] = i
+	}
+	return res
+}
@@ -0,0 +1,233 @@
+// Unit tests for gradient boosting
+
+package decision_tree
+
+import (
+	"math"
+	"mlcode/metrics"
+	"mlcode/utils"
+	"path/filepath"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Test binning of numeric and string columns
+func TestBins(t *testing.T) {
+
+	// Few values: one bin for each, thresholds at the midpoints
+	f := numericBins("x", []float64{3, 1, 2, 2, 5}, 255)
+	if !mat.Equal(mat.NewVecDense(3, f.thresholds), mat.NewVecDense(3, []float64{1.5, 2.5, 4})) {
+		t.Errorf("Wrong thresholds %v", f.thresholds)
+	}
+	if string(f.bins) != string([]uint8{2, 0, 1, 1, 3}) {
+		t.Errorf("Wrong bins %v", f.bins)
+	}
+
+	// Many values: about the same number in each bin
+	values := make([]float64, 1000)
+	for i := range values {
+		values[i] = float64(i * i)
+	}
+	f = numericBins("x", values, 10)
+	counts := make([]int, f.nBins())
+	for _, b := range f.bins {
+		counts[b]++
+	}
+	if f.nBins() != 10 || utils.Min(counts) != 100 || utils.Max(counts) != 100 {
+		t.Errorf("Wrong bin counts %v", counts)
+	}
+
+	// Strings: the less common ones share the last bin
+	f = categoricalBins("s", []string{"b", "a", "c", "b", "a", "b", "d"}, 3)
+	if f.nBins() != 3 || f.cats[0] != "b" || f.cats[1] != "a" || f.bins[2] != 2 || f.bins[6] != 2 {
+		t.Errorf("Wrong categories %v %v", f.cats, f.bins)
+	}
+}
+
+// Test gradient boosting for regression, and classification with two
+// and three classes
+func TestGradientBoost(t *testing.T) {
+
+	// Regression on a step function with noise-free data is learned well
+	// with enough rounds, and the training loss keeps going down
+	X := mat.NewDense(200, 2, nil)
+	Y := mat.NewDense(200, 1, nil)
+	for i := 0; i < 200; i++ {
+		X.Set(i, 0, float64(i%20))
+		X.Set(i, 1, float64(i/20))
+		Y.Set(i, 0, utils.IfThenElse(i%20 < 10, 1.0, 21.0)+X.At(i, 1))
+	}
+	df := utils.FromMatrix(X, nil)
+	*df = append(*df, utils.Series{Name: "y", Dtype: "float64", Floats: mat.Col(nil, 0, Y)})
+	b, err := GradientBoost(df, "y", BoostParams{NRounds: 200, MinLeaf: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Loss != LossSquared || b.TrainLoss[len(b.TrainLoss)-1] > .1 || b.TrainLoss[0] < b.TrainLoss[1] {
+		t.Errorf("Regression: loss %v to %v", b.TrainLoss[0], b.TrainLoss[len(b.TrainLoss)-1])
+	}
+	imp := b.FeatureImportance()
+	if math.Abs(imp.Get("x0")+imp.Get("x1")-1) > 1e-9 || imp[0].Name != "x0" {
+		t.Errorf("Wrong importance %v", imp)
+	}
+
+	// Titanic, two classes, with string columns: better than a single tree
+	// on held out rows, and early stopping keeps the best round
+	titanic := GetTitanicData("../data/titanic.csv")
+	train, test := utils.TrainTestSplit(titanic, .2, "Survived", 42)
+	b, err = GradientBoost(train, "Survived", BoostParams{NRounds: 500, ValidationFrac: .2, Subsample: .8, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc := metrics.Accuracy(test.GetColumn("Survived").Strings, b.PredictLabels(test))
+	if b.Loss != LossLogistic || acc < .8 {
+		t.Errorf("Titanic: %s loss, accuracy %.3f", b.Loss, acc)
+	}
+	best := b.ValidLoss[b.NRounds()-1]
+	if b.NRounds() == 500 || len(b.ValidLoss) != b.NRounds()+10 || utils.Min(b.ValidLoss) != best {
+		t.Errorf("Titanic: early stopping kept %d of %d rounds", b.NRounds(), len(b.ValidLoss))
+	}
+	if imp := b.FeatureImportance(); imp.Get("Sex") < imp.Get("Embarked") {
+		t.Errorf("Titanic: unlikely importance %v", imp)
+	}
+
+	// Iris, three classes, probabilities add up to 1
+	iris, _ := utils.ReadCSV("../data/iris.csv")
+	b, err = GradientBoost(iris, "variety", BoostParams{MinLeaf: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	probs := b.PredictProba(iris)
+	if b.Loss != LossSoftmax || len(b.Trees[0]) != 3 || math.Abs(mat.Sum(probs)-150) > 1e-9 {
+		t.Errorf("Iris: wrong probabilities, %s loss", b.Loss)
+	}
+	if acc := metrics.Accuracy(iris.GetColumn("variety").Strings, b.PredictLabels(iris)); acc < .97 {
+		t.Errorf("Iris: training accuracy %.3f", acc)
+	}
+
+	// Invalid settings
+	for _, p := range []BoostParams{{Loss: "hinge"}, {MaxBins: 256}, {Subsample: 1.5}, {Loss: LossSquared}, {Loss: LossLogistic}} {
+		if _, err := GradientBoost(iris, "variety", p); err == nil {
+			t.Errorf("Accepted %+v", p)
+		}
+	}
+}
+
+// Test the gradient boosting estimators on matrices
+func TestBoostEstimators(t *testing.T) {
+	X := mat.NewDense(8, 2, []float64{1, 5, 2, 3, 3, 8, 4, 1, 6, 2, 7, 9, 8, 4, 9, 6})
+	Y := mat.NewDense(8, 1, []float64{0, 0, 0, 0, 2, 2, 2, 2})
+	m := BoostClassifier{Params: BoostParams{MinLeaf: 1, NRounds: 20}}
+	if err := m.Fit(X, Y); err != nil {
+		t.Fatal(err)
+	}
+	probs := m.PredictProba(X)
+	if !mat.Equal(m.Predict(X), Y) || probs.At(0, 0) < .5 || probs.At(7, 1) < .5 {
+		t.Errorf("Wrong predictions %v", mat.Formatted(m.Predict(X).T()))
+	}
+
+	r := BoostRegressor{Params: BoostParams{MinLeaf: 1, NRounds: 100}}
+	if err := r.Fit(X, Y); err != nil {
+		t.Fatal(err)
+	}
+	if preds := r.Predict(X); math.Abs(preds.At(0, 0)) > .01 || math.Abs(preds.At(7, 0)-2) > .01 {
+		t.Errorf("Wrong values %v", mat.Formatted(preds.T()))
+	}
+
+	// Save and load, but not before training
+	filename := filepath.Join(t.TempDir(), "boost.json")
+	if (&BoostClassifier{}).Save(filename) == nil || (&BoostRegressor{}).Save(filename) == nil {
+		t.Error("Saved a boosting model that has not been trained")
+	}
+	if err := m.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	m2 := BoostClassifier{}
+	if err := m2.Load(filename); err != nil {
+		t.Fatal(err)
+	}
+	if !mat.Equal(m2.PredictProba(X), probs) {
+		t.Error("Classifier did not round-trip")
+	}
+}
+
+// Test AdaBoost on stumps
+func TestAdaBoost(t *testing.T) {
+
+	// Iris: the first stump can only get two of the three classes right,
+	// so its error is 1/3, and its vote is log(2) + log(3 - 1)
+	iris, _ := utils.ReadCSV("../data/iris.csv")
+	m, err := TrainAdaBoost(iris, "variety", AdaParams{NRounds: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(m.Errors[0]-1.0/3) > 1e-9 || math.Abs(m.Alphas[0]-2*math.Log(2)) > 1e-9 || !m.Trees[0].Left.IsLeaf() {
+		t.Errorf("Wrong first stump, error %v, vote %v", m.Errors[0], m.Alphas[0])
+	}
+	if acc := metrics.Accuracy(iris.GetColumn("variety").Strings, m.PredictLabels(iris)); acc < .95 {
+		t.Errorf("Iris: training accuracy %.3f", acc)
+	}
+	if math.Abs(mat.Sum(m.PredictProba(iris))-150) > 1e-9 {
+		t.Error("Iris: votes do not add up to 1")
+	}
+
+	// A stump with no errors is the only one needed
+	X := mat.NewDense(8, 2, []float64{1, 5, 2, 3, 3, 8, 4, 1, 6, 2, 7, 9, 8, 4, 9, 6})
+	Y := mat.NewDense(8, 1, []float64{0, 0, 0, 0, 2, 2, 2, 2})
+	a := AdaClassifier{}
+	if a.Save(filepath.Join(t.TempDir(), "ada.json")) == nil {
+		t.Error("Saved an AdaBoost model that has not been trained")
+	}
+	if err := a.Fit(X, Y); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Model().Trees) != 1 || !mat.Equal(a.Predict(X), Y) || a.PredictProba(X).At(7, 1) != 1 {
+		t.Errorf("Wrong predictions %v", mat.Formatted(a.Predict(X).T()))
+	}
+
+	// A tree of depth 2 with no errors in a later round, after the
+	// reweighting changes its first split, replaces the trees before it
+	df := utils.DataFrame{
+		{Name: "x1", Dtype: "float64", Floats: []float64{2, 3, 3, 2, 0, 3}},
+		{Name: "x2", Dtype: "float64", Floats: []float64{2, 2, 0, 3, 2, 3}},
+		{Name: "y", Dtype: "string", Strings: []string{"a", "a", "a", "a", "b", "b"}}}
+	m, _ = TrainAdaBoost(&df, "y", AdaParams{NRounds: 1, MaxDepth: 2})
+	if m.Errors[0] == 0 {
+		t.Error("First tree should not be perfect")
+	}
+	m, _ = TrainAdaBoost(&df, "y", AdaParams{NRounds: 10, MaxDepth: 2})
+	if len(m.Trees) != 1 || m.Errors[0] != 0 || m.PredictProba(&df).At(4, 1) != 1 {
+		t.Errorf("Perfect tree is not the only vote: %d trees, errors %v", len(m.Trees), m.Errors)
+	}
+	if acc := metrics.Accuracy(df.GetColumn("y").Strings, m.PredictLabels(&df)); acc != 1 {
+		t.Errorf("Perfect tree: training accuracy %.3f", acc)
+	}
+
+	// Titanic: better than the 62% who died
+	titanic := GetTitanicData("../data/titanic.csv")
+	train, test := utils.TrainTestSplit(titanic, .2, "Survived", 42)
+	m, err = TrainAdaBoost(train, "Survived", AdaParams{NRounds: 100, LearningRate: .5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc := metrics.Accuracy(test.GetColumn("Survived").Strings, m.PredictLabels(test)); acc < .75 {
+		t.Errorf("Titanic: accuracy %.3f", acc)
+	}
+
+	// Save and load
+	filename := filepath.Join(t.TempDir(), "ada.gob")
+	if err := SaveAdaBoost(m, filename); err != nil {
+		t.Fatal(err)
+	}
+	m2, err := LoadAdaBoost(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mat.Equal(m2.PredictProba(test), m.PredictProba(test)) {
+		t.Error("AdaBoost did not round-trip")
+	}
+	if _, err := TrainAdaBoost(titanic, "Fare", AdaParams{}); err == nil {
+		t.Error("AdaBoost accepted a numeric target")
+	}
+}
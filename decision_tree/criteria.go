@@ -0,0 +1,255 @@
+// Criteria for choosing the best split in a tree. Each criterion measures
+// the impurity of a node, i.e., how mixed the target values of its rows
+// are, and scores a split, lower is better. Set in TreeParams.Criterion,
+// the default is Gini for classification and MSE for regression trees.
+//
+// For classification, the target values are class numbers, so only
+// whether two values are equal matters:
+//   - Gini: the Gini index, the chance that two rows picked at random
+//     have different labels, as in CART
+//   - Entropy: the entropy of the labels in bits, so the best split has
+//     the highest information gain, as in ID3
+//   - GainRatio: the information gain divided by the entropy of the split
+//     itself, as in C4.5, so that splits into very uneven sides are not
+//     favoured
+//
+// For regression, the target values are numbers:
+//   - MSE: the variance of the values, i.e., the mean squared error of
+//     predicting the mean
+//   - MAE: the mean absolute error of predicting the median, less
+//     sensitive to outliers, and leaves predict the median
+//
+// Sample usage:
+//
+//	tree := DecisionTreeWith(df, "variety", TreeParams{Criterion: Entropy{}})
+
+package decision_tree
+
+import (
+	"math"
+	"mlcode/utils"
+	"sort"
+)
+
+// Criterion for choosing the best split of a tree
+type Criterion interface {
+
+	// Impurity of a node, from the target values of its rows (class
+	// numbers for classification) and their weights (nil for none), zero
+	// if all the values are the same
+	Impurity(y, weights []float64) float64
+
+	// Score of a split of a node into left and right sides, lower is
+	// better
+	Score(left, right, wLeft, wRight []float64) float64
+
+	// Whether the criterion is for regression trees, otherwise for
+	// classification trees
+	Regression() bool
+}
+
+// Criteria that can work from running totals of the target values rather
+// than the values themselves, so all the splits of a sorted column can be
+// scored in one pass, see splitter.go
+type totalsCriterion interface {
+	impurityOf(t *totals) float64
+	scoreOf(left, right *totals) float64
+}
+
+// Gini index, for classification
+type Gini struct{}
+
+func (c Gini) Impurity(y, weights []float64) float64 {
+	return c.impurityOf(classTotals(y, weights, nil))
+}
+
+func (c Gini) Score(left, right, wLeft, wRight []float64) float64 {
+	return c.scoreOf(classTotals(left, wLeft, right), classTotals(right, wRight, left))
+}
+
+func (Gini) Regression() bool { return false }
+
+func (Gini) impurityOf(t *totals) float64 {
+	var gini float64
+	for _, c := range t.classes {
+		p := c / t.n
+		gini += p * (1 - p)
+	}
+	return gini
+}
+
+func (c Gini) scoreOf(left, right *totals) float64 {
+	return weightedImpurity(c, left, right)
+}
+
+// Entropy in bits, for classification
+type Entropy struct{}
+
+func (c Entropy) Impurity(y, weights []float64) float64 {
+	return c.impurityOf(classTotals(y, weights, nil))
+}
+
+func (c Entropy) Score(left, right, wLeft, wRight []float64) float64 {
+	return c.scoreOf(classTotals(left, wLeft, right), classTotals(right, wRight, left))
+}
+
+func (Entropy) Regression() bool { return false }
+
+func (Entropy) impurityOf(t *totals) float64 {
+	var h float64
+	for _, c := range t.classes {
+		if c > 0 {
+			h -= c / t.n * math.Log2(c/t.n)
+		}
+	}
+	return h
+}
+
+func (c Entropy) scoreOf(left, right *totals) float64 {
+	return weightedImpurity(c, left, right)
+}
+
+// Information gain ratio, for classification. The impurity is the
+// entropy, the score of a split is minus the gain ratio, so lower is
+// better.
+type GainRatio struct{}
+
+func (GainRatio) Impurity(y, weights []float64) float64 {
+	return Entropy{}.Impurity(y, weights)
+}
+
+func (c GainRatio) Score(left, right, wLeft, wRight []float64) float64 {
+	return c.scoreOf(classTotals(left, wLeft, right), classTotals(right, wRight, left))
+}
+
+func (GainRatio) Regression() bool { return false }
+
+func (GainRatio) impurityOf(t *totals) float64 {
+	return Entropy{}.impurityOf(t)
+}
+
+func (GainRatio) scoreOf(left, right *totals) float64 {
+
+	// Information gain: entropy of the node, less the weighted average
+	// entropy of the two sides
+	parent := left.clone()
+	parent.merge(right)
+	gain := Entropy{}.impurityOf(parent) - weightedImpurity(Entropy{}, left, right)
+
+	// Split information: the entropy of the sizes of the two sides
+	splitInfo := 0.0
+	for _, n := range []float64{left.n, right.n} {
+		if n > 0 {
+			splitInfo -= n / parent.n * math.Log2(n/parent.n)
+		}
+	}
+	if splitInfo == 0 {
+		return 0
+	}
+	return -gain / splitInfo
+}
+
+// Mean squared error (variance), for regression
+type MSE struct{}
+
+func (MSE) Impurity(y, weights []float64) float64 {
+	return variance(y, weights)
+}
+
+func (c MSE) Score(left, right, wLeft, wRight []float64) float64 {
+	return averageImpurity(c, left, right, wLeft, wRight)
+}
+
+func (MSE) Regression() bool { return true }
+
+func (MSE) impurityOf(t *totals) float64 {
+	if t.n == 0 {
+		return 0
+	}
+	return t.m2 / t.n
+}
+
+func (c MSE) scoreOf(left, right *totals) float64 {
+	return weightedImpurity(c, left, right)
+}
+
+// Mean absolute error from the median, for regression
+type MAE struct{}
+
+func (MAE) Impurity(y, weights []float64) float64 {
+	if utils.AllSame(y) {
+		return 0
+	}
+	m := median(y, weights)
+	var tot float64
+	for i, v := range y {
+		tot += utils.Weight(weights, i) * math.Abs(v-m)
+	}
+	n := sumWeights(len(y), weights)
+	if n == 0 {
+		return 0
+	}
+	return tot / n
+}
+
+func (c MAE) Score(left, right, wLeft, wRight []float64) float64 {
+	return averageImpurity(c, left, right, wLeft, wRight)
+}
+
+func (MAE) Regression() bool { return true }
+
+// Average impurity of the two sides of a split, weighted by the total
+// weight (or number of rows) on each side
+func averageImpurity(c Criterion, left, right, wLeft, wRight []float64) float64 {
+	nl, nr := sumWeights(len(left), wLeft), sumWeights(len(right), wRight)
+	if nl+nr == 0 {
+		return 0
+	}
+	return (c.Impurity(left, wLeft)*nl + c.Impurity(right, wRight)*nr) / (nl + nr)
+}
+
+// Same, from the totals of each side
+func weightedImpurity(c totalsCriterion, left, right *totals) float64 {
+	if left.n+right.n == 0 {
+		return 0
+	}
+	return (c.impurityOf(left)*left.n + c.impurityOf(right)*right.n) / (left.n + right.n)
+}
+
+// Total weight of each class, in order of class number, and the total of
+// all the weights. The classes are those in y and also, so the totals of
+// the two sides of a split line up, those in other.
+func classTotals(y, weights, other []float64) *totals {
+	classes := utils.Unique(append(append([]float64{}, y...), other...))
+	index := map[float64]int{}
+	for i, c := range classes {
+		index[c] = i
+	}
+	t := totals{classes: make([]float64, len(classes))}
+	for i, c := range y {
+		t.add(float64(index[c]), utils.Weight(weights, i))
+	}
+	return &t
+}
+
+// Weighted median of a list of numbers, i.e., the smallest value such
+// that at least half of the total weight is at or below it
+func median(y, weights []float64) float64 {
+	if len(y) == 0 {
+		return 0
+	}
+	order := make([]int, len(y))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return y[order[a]] < y[order[b]] })
+	half := sumWeights(len(y), weights) / 2
+	var cum float64
+	for _, i := range order {
+		cum += utils.Weight(weights, i)
+		if cum >= half {
+			return y[i]
+		}
+	}
+	return y[order[len(order)-1]]
+}
@@ -1,10 +1,14 @@
-// Simple classification decision tree
+// Simple decision tree, for classification (string labels) or regression
+// (numeric target, see regression_tree.go)
 
 package decision_tree
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"mlcode/utils"
+	"sort"
 )
 
 // Node in a decision tree
@@ -12,9 +16,23 @@ type Node struct {
 	SplitVar    string  // column name
 	SplitNum    float64 // number to split at
 	SplitCat    string  // or string to split on
-	G           float64 // gini index at this point
+	MissingLeft bool    // whether rows missing a value of SplitVar go left, see splitter.go
+	G           float64 // score of the split, e.g., the impurity after the split, see criteria.go
 	Left, Right *Node   // left and right nodes for decision
-	Value       string  // terminal value if a leaf
+	Value       string  // terminal value if a leaf of a classification tree
+	Mean        float64 // terminal value if a leaf of a regression tree
+
+	// Training rows at the node, kept for all nodes so that any split can
+	// be pruned back to a leaf (see pruning.go), which then predicts Value
+	// or Mean, and the probability of each label (see PredictProba)
+	N        float64            // total weight (number) of the rows
+	Impurity float64            // impurity of the rows, using the split criterion
+	Counts   map[string]float64 // total weight of the rows with each label, classification only
+}
+
+// Is the node a leaf, i.e., has no branches?
+func (n *Node) IsLeaf() bool {
+	return n.Left == nil
 }
 
 // Parameters for learning, default values may be changed
@@ -23,249 +41,320 @@ var MaxDepth = 3    // Maximum depth for a tree
 var MinLeaf = 20    // Minimum size of a leaf
 var Verbose = false // whether to show progress messages
 
-// Create decision tree, recursively, returns top-level node
-func DecisionTree(df *utils.DataFrame, depv string, level int) *Node {
+// Parameters for learning one tree, so that trees with different settings
+// can be trained at the same time. Zero values are replaced by the
+// package-level defaults above.
+type TreeParams struct {
+	MaxDepth int  // Maximum depth for a tree
+	MinLeaf  int  // Minimum size of a leaf
+	Verbose  bool // whether to show progress messages
+
+	// How to choose the best split, see criteria.go, default Gini for
+	// classification and MSE for regression
+	Criterion Criterion
+
+	// Optional weights, so that some rows count more than others in the
+	// split criterion and the leaf values, see weights.go
+	WeightCol   string // numeric column with the weight of each row, not used for splits
+	ClassWeight string // utils.ClassWeightBalanced gives each class the same total weight
+
+	// Cost-complexity pruning after training, see pruning.go, default 0
+	// (no pruning)
+	CCPAlpha float64
+
+	// Random choices, mainly for random forests, see splitter.go
+	MaxFeatures      MaxFeatures // columns to try for each split, default all
+	RandomThresholds bool        // one random split value for each column, as in ExtraTrees
+	Seed             int64       // for the random choices
+}
 
-	// Terminate with a leaf node it:
-	// 1. too few rows left
-	// 2. tree too deep
-	// 3. no more variation
-	dcol := df.GetColumn(depv)
-	if df.NRows() < MinLeaf || level >= MaxDepth || giniIndex(dcol.Strings) == 0 {
-		return &Node{Value: utils.MostCommon(dcol.Strings)}
-	}
+// Rules for MaxFeatures
+const (
+	FeaturesSqrt = "sqrt" // square root of the number of columns
+	FeaturesLog2 = "log2" // log2 of the number of columns
+)
 
-	// Define all possible splits, based on each attribute
-	// and find the one that produces the lowest Gini Index
-	var bestCol string       // best column to split on
-	var bestSplitNum float64 // value if numeric split
-	var bestSplitCat string  // value if categorical split
-	var bestGini float64 = 1
-	var bestLeft, bestRight *utils.DataFrame
-	for _, c := range *df {
-
-		// Skip the dependent variable
-		if c.Name == depv {
-			continue
-		}
+// How many of the columns to try for each split, as max_features in
+// scikit-learn. Set at most one of the fields, the zero value means all
+// the columns. At least one column is always tried, and columns that
+// cannot be split (e.g., all the same value) do not count.
+type MaxFeatures struct {
+	Count    int     // number of columns, at most all of them
+	Fraction float64 // or fraction of the columns, more than 0 and at most 1
+	Rule     string  // or FeaturesSqrt or FeaturesLog2
+}
 
-		// If the column is numeric, test splits at midpints between
-		// all values
-		if c.Dtype == "float64" || c.Dtype == "int64" {
-			var splits []float64
-			if c.Dtype == "float64" {
-				splits = midPoints(c.Floats)
-			} else if c.Dtype == "int64" {
-				splits = midPoints(c.Ints)
-			}
-			for _, split := range splits {
-				left, right := splitNumeric(*df, c.Name, split)
-				leftLabels := left.GetColumn(depv).Strings
-				rightLabels := right.GetColumn(depv).Strings
-				if len(leftLabels) == 0 || len(rightLabels) == 0 {
-					continue
-				}
-				G := giniCombined(leftLabels, rightLabels)
-				if G < bestGini {
-					bestGini = G
-					bestCol = c.Name
-					bestSplitNum = split
-					bestSplitCat = ""
-					bestLeft = left
-					bestRight = right
-				}
-			}
-		} else if c.Dtype == "string" { // split on categorical variable
-			splits := utils.Unique(c.Strings) // all possible values
-			for _, split := range splits {
-				left, right := splitCategorical(*df, c.Name, split)
-				leftLabels := left.GetColumn(depv).Strings
-				rightLabels := right.GetColumn(depv).Strings
-				if len(leftLabels) == 0 || len(rightLabels) == 0 {
-					continue
-				}
-				G := giniCombined(leftLabels, rightLabels)
-				if G < bestGini {
-					bestGini = G
-					bestCol = c.Name
-					bestSplitNum = 0 // n/a
-					bestSplitCat = split
-					bestLeft = left
-					bestRight = right
-				}
-			}
-		} else {
-			fmt.Println("Warning: column ignored, type", c.Dtype)
-		}
+// Check that at most one setting is used, and it is valid
+func (m MaxFeatures) check() error {
+	set := 0
+	for _, b := range []bool{m.Count != 0, m.Fraction != 0, m.Rule != ""} {
+		set += utils.IfThenElse(b, 1, 0)
+	}
+	switch {
+	case set > 1:
+		return errors.New("MaxFeatures: set only one of Count, Fraction and Rule")
+	case m.Count < 0:
+		return errors.New("MaxFeatures: Count must not be negative")
+	case m.Fraction < 0 || m.Fraction > 1:
+		return errors.New("MaxFeatures: Fraction must be between 0 and 1")
+	case m.Rule != "" && m.Rule != FeaturesSqrt && m.Rule != FeaturesLog2:
+		return errors.New("MaxFeatures: unknown rule " + m.Rule)
 	}
+	return nil
+}
 
-	// If there was no meaningful split found, return a terminal node
-	if len(bestCol) == 0 {
-		return &Node{Value: utils.MostCommon(dcol.Strings)}
+// Number of columns to try, out of n
+func (m MaxFeatures) count(n int) int {
+	k := n
+	switch {
+	case m.Count > 0:
+		k = m.Count
+	case m.Fraction > 0:
+		k = int(m.Fraction * float64(n))
+	case m.Rule == FeaturesSqrt:
+		k = int(math.Sqrt(float64(n)))
+	case m.Rule == FeaturesLog2:
+		k = int(math.Log2(float64(n)))
 	}
+	return utils.Max([]int{1, utils.Min([]int{k, n})})
+}
 
-	// Show the best split found
-	if Verbose {
-		fmt.Printf("Depth %2d: n = %d, best split on %s at ", level, df.NRows(), bestCol)
-		if len(bestSplitCat) > 0 {
-			fmt.Printf("\"%s\"", bestSplitCat)
-		} else {
-			fmt.Print(bestSplitNum)
+// Get tree parameters from the package-level defaults
+func DefaultParams() TreeParams {
+	return TreeParams{MaxDepth: MaxDepth, MinLeaf: MinLeaf, Verbose: Verbose}
+}
+
+// Fill in any parameters not set with the package-level defaults, and
+// the default criterion for the type of tree. Panics if the criterion is
+// for the wrong type of tree, or CCPAlpha or MaxFeatures is invalid.
+func (p TreeParams) withDefaults(regression bool) TreeParams {
+	if p.MaxDepth <= 0 {
+		p.MaxDepth = MaxDepth
+	}
+	if p.MinLeaf <= 0 {
+		p.MinLeaf = MinLeaf
+	}
+	if p.Criterion == nil {
+		p.Criterion = Gini{}
+		if regression {
+			p.Criterion = MSE{}
 		}
-		fmt.Println(" => Gini", bestGini)
 	}
+	if err := p.check(regression); err != nil {
+		panic("DecisionTree: " + err.Error())
+	}
+	return p
+}
 
-	// Using the best split found, recursively do left and right sides
-	n := Node{SplitVar: bestCol, SplitNum: bestSplitNum, SplitCat: bestSplitCat, G: bestGini}
-	n.Left = DecisionTree(bestLeft, depv, level+1)
-	n.Right = DecisionTree(bestRight, depv, level+1)
-	return &n
+// Check that the criterion (if set) is for the right type of tree, and
+// the pruning and MaxFeatures settings are valid
+func (p TreeParams) check(regression bool) error {
+	if p.Criterion != nil && p.Criterion.Regression() != regression {
+		return fmt.Errorf("criterion %T is for the other type of tree", p.Criterion)
+	}
+	if p.CCPAlpha < 0 {
+		return fmt.Errorf("CCPAlpha %g must not be negative", p.CCPAlpha)
+	}
+	return p.MaxFeatures.check()
 }
 
-// Split a dataframe on a numeric (float) column, into two dataframes,
-// at the given split value
-func splitNumeric(df utils.DataFrame, colName string, split float64) (*utils.DataFrame, *utils.DataFrame) {
+// Create decision tree, recursively, returns top-level node. Uses the
+// package-level parameters.
+func DecisionTree(df *utils.DataFrame, depv string, level int) *Node {
+	p := DefaultParams().withDefaults(false)
+	return buildTree(df, level, &p, newClassification(df, depv, &p))
+}
 
-	// Make two empty dataframes with same columns
-	left := df.CopyStructure()
-	right := df.CopyStructure()
+// Create decision tree using the given parameters, returns top-level node.
+// Panics if the weights or criterion are invalid.
+func DecisionTreeWith(df *utils.DataFrame, depv string, p TreeParams) *Node {
+	p = p.withDefaults(false)
+	df, p = applyWeights(df, depv, p)
+	return buildTree(df, 0, &p, newClassification(df, depv, &p))
+}
 
-	// Copy rows to the appropriate dataframe, based on splits
-	splitCol := df.GetColumn(colName) // the column to split on
-	for i := 0; i < df.NRows(); i++ {
+// What a tree predicts: the target values of the rows, for the criterion
+// to measure the impurity, and how to make a leaf from some of the rows
+type target interface {
+	values() []float64     // target value of each row
+	leaf(rows []int) *Node // terminal node for the given row numbers
+	skip(col string) bool  // columns not to split on
+}
 
-		// Determine if this row is less than cut-off, depends on type
-		var splitLeft bool
-		if splitCol.Dtype == "float64" {
-			splitLeft = splitCol.Floats[i] < split
-		} else if splitCol.Dtype == "int64" {
-			splitLeft = float64(splitCol.Ints[i]) < split
-		} else {
-			panic("splitNumeric: invalid data type " + splitCol.Dtype)
-		}
+// Classification tree, predicting the most common label
+type classification struct {
+	labels  []string    // label of each row
+	weights []float64   // weight of each row, nil for none
+	depv    string      // column with the string labels
+	p       *TreeParams // for the weights
+}
 
-		// Copy to left or right
-		if splitLeft {
-			left.CopyRow(&df, i)
-		} else {
-			right.CopyRow(&df, i)
-		}
+// Set up a classification tree
+func newClassification(df *utils.DataFrame, depv string, p *TreeParams) classification {
+	return classification{labels: df.GetColumn(depv).Strings, weights: weightsOf(df, p), depv: depv, p: p}
+}
+
+// Class numbers, in order of the labels
+func (c classification) values() []float64 {
+	classes := map[string]float64{}
+	for i, l := range utils.Unique(c.labels) {
+		classes[l] = float64(i)
 	}
+	res := make([]float64, len(c.labels))
+	for i, l := range c.labels {
+		res[i] = classes[l]
+	}
+	return res
+}
+
+func (c classification) leaf(rows []int) *Node {
+	labels := make([]string, len(rows))
+	counts := map[string]float64{}
+	for i, r := range rows {
+		labels[i] = c.labels[r]
+		counts[labels[i]] += utils.Weight(c.weights, r)
+	}
+	return &Node{Value: mostCommon(labels, gather(c.weights, rows)), Counts: counts}
+}
+
+func (c classification) skip(col string) bool {
+	return col == c.depv || col == c.p.WeightCol
+}
 
-	// Return the left and right splits
-	return left, right
+// Predict from a decision tree, return predicted label
+func Predict(tree *Node, row *utils.DataFrame) string {
+	leaf := findLeaf(tree, row)
+	if leaf == nil {
+		return "error"
+	}
+	return leaf.Value
 }
 
-// Split a dataframe on a categorical (string) column, into two dataframes,
-// left for equal, right for not equal
-func splitCategorical(df utils.DataFrame, colName string, split string) (*utils.DataFrame, *utils.DataFrame) {
+// Predict from a decision tree, the probability of each label, as its
+// share of the training rows of the leaf. Nil if the row has a column type
+// that cannot be used.
+func PredictProba(tree *Node, row *utils.DataFrame) map[string]float64 {
+	leaf := findLeaf(tree, row)
+	if leaf == nil {
+		return nil
+	}
+	return leafProba(leaf)
+}
 
-	// Make two empty dataframes with same columns
-	left := df.CopyStructure()
-	right := df.CopyStructure()
+// Probability of each label at a leaf. A leaf without class counts, e.g.,
+// from a tree saved before they were kept, gives its label probability 1.
+func leafProba(leaf *Node) map[string]float64 {
+	var tot float64
+	for _, n := range leaf.Counts {
+		tot += n
+	}
+	if tot <= 0 {
+		return map[string]float64{leaf.Value: 1}
+	}
+	res := map[string]float64{}
+	for label, n := range leaf.Counts {
+		res[label] = n / tot
+	}
+	return res
+}
 
-	// Copy rows to the appropriate dataframe, based on splits
-	splitCol := df.GetColumn(colName) // the column to split on
-	for i := 0; i < df.NRows(); i++ {
-		if splitCol.Strings[i] == split {
-			left.CopyRow(&df, i)
-		} else {
-			right.CopyRow(&df, i)
+// Label with the highest probability, the first in sorted order if there
+// is a tie, "error" if none
+func mostLikely(probs map[string]float64) string {
+	labels := make([]string, 0, len(probs))
+	for label := range probs {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	best := "error"
+	for _, label := range labels {
+		if best == "error" || probs[label] > probs[best] {
+			best = label
 		}
 	}
+	return best
+}
 
-	return left, right
+// Find the leaf of a decision tree for a row, nil if the row has a
+// column type that cannot be used
+func findLeaf(tree *Node, row *utils.DataFrame) *Node {
+	return leafAt(tree, columnMap(row), 0)
 }
 
-// Predict from a decision tree, return predicted label
-func Predict(tree *Node, row *utils.DataFrame) string {
+// Columns of a dataframe by name, nil if no dataframe
+func columnMap(df *utils.DataFrame) map[string]*utils.Series {
+	if df == nil {
+		return nil
+	}
+	cols := map[string]*utils.Series{}
+	for i := range *df {
+		cols[(*df)[i].Name] = &(*df)[i]
+	}
+	return cols
+}
+
+// Find the leaf of a decision tree for row i of the given columns, nil if
+// the row has a column type that cannot be used
+func leafAt(tree *Node, cols map[string]*utils.Series, i int) *Node {
 
 	// Terminal node is the prediction
-	if len(tree.Value) > 0 {
-		return tree.Value
+	if tree.IsLeaf() {
+		return tree
 	}
 
-	// Otherwise evaluate the split
-	col := row.GetColumn(tree.SplitVar)
-	var predLeft bool
-	if col.Dtype == "string" {
-		val := col.Strings[0]
-		predLeft = val == tree.SplitCat
-	} else if col.Dtype == "float64" {
-		val := col.Floats[0]
-		predLeft = val < tree.SplitNum
-	} else if col.Dtype == "int64" {
-		val := float64(col.Ints[0])
-		predLeft = val < tree.SplitNum
-	} else {
-		fmt.Println("TODO: Skipping prediction on", col.Dtype)
-		return "error"
+	// Otherwise evaluate the split, and proceed to left or right branch
+	left, ok := goesLeft(tree, cols[tree.SplitVar], i)
+	if !ok {
+		return nil
 	}
-
-	// Proceed to left or right branch
-	if predLeft {
-		return Predict(tree.Left, row)
+	if left {
+		return leafAt(tree.Left, cols, i)
 	} else {
-		return Predict(tree.Right, row)
+		return leafAt(tree.Right, cols, i)
 	}
 
 }
 
+// Whether row i of the split column goes to the left branch of a node, not
+// ok if the column type cannot be used. Missing values go the way learned
+// in training.
+func goesLeft(tree *Node, col *utils.Series, i int) (left, ok bool) {
+	if col.Dtype == "string" {
+		val := col.Strings[i]
+		if val == tree.SplitCat {
+			return true, true
+		}
+		return col.IsMissing(i) && tree.MissingLeft, true
+	} else if col.IsMissing(i) {
+		return tree.MissingLeft, true
+	} else if col.Dtype == "float64" {
+		val := col.Floats[i]
+		return val < tree.SplitNum, true
+	} else if col.Dtype == "int64" {
+		val := float64(col.Ints[i])
+		return val < tree.SplitNum, true
+	}
+	fmt.Println("TODO: Skipping prediction on", col.Dtype)
+	return false, false
+}
+
 // Print decision tree
 func PrintTree(tree *Node, level int) {
 	for i := 0; i < level; i++ {
 		fmt.Print("  ")
 	}
-	if len(tree.Value) > 0 {
+	if tree.IsLeaf() && len(tree.Value) > 0 {
 		fmt.Println("-->", tree.Value)
+	} else if tree.IsLeaf() {
+		fmt.Printf("--> %.4g\n", tree.Mean)
 	} else {
 		if len(tree.SplitCat) > 0 {
-			fmt.Printf("%s == \"%s\"\n", tree.SplitVar, tree.SplitCat)
+			fmt.Printf("%s == \"%s\"", tree.SplitVar, tree.SplitCat)
 		} else {
-			fmt.Printf("%s < %.2f\n", tree.SplitVar, tree.SplitNum)
+			fmt.Printf("%s < %.2f", tree.SplitVar, tree.SplitNum)
 		}
+		fmt.Println(utils.IfThenElse(tree.MissingLeft, " or missing", ""))
 		PrintTree(tree.Left, level+1)
 		PrintTree(tree.Right, level+1)
 	}
 }
-
-// Calculate the weighted average Gini Index for two lists of labels
-func giniCombined(left, right []string) float64 {
-	gl := giniIndex(left)
-	gr := giniIndex(right)
-	nl := float64(len(left))
-	nr := float64(len(right))
-	return gl*nl/(nl+nr) + gr*nr/(nl+nr)
-}
-
-// Calculate the Gini Index of one list of labels
-func giniIndex(labels []string) float64 {
-
-	// Get the count for each label
-	classCount := map[string]int{}
-	for _, l := range labels {
-		classCount[l]++
-	}
-
-	// Calculate the Gini index
-	var gini float64
-	n := float64(len(labels))
-	for _, c := range classCount { // each label found
-		p := float64(c) / n
-		gini += p * (1 - p)
-	}
-	return gini
-}
-
-// For a list of numbers (integer or float), return a list that is the
-// midpoints between each consecutive pair; result is always list of floats,
-// even if you pass it a list of ints, since mid-points need to be floats.
-func midPoints[T float64 | int64](nums []T) []float64 {
-	nums = utils.Unique(nums) // remove duplicates, sorted
-	res := []float64{}
-	for i := 1; i < len(nums); i++ {
-		mid := float64(nums[i-1]) + float64(nums[i]-nums[i-1])/2.0
-		res = append(res, mid)
-	}
-	return res
-}
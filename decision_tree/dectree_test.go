@@ -4,22 +4,566 @@ package decision_tree
 
 import (
 	"math"
+	"mlcode/metrics"
+	"mlcode/utils"
+	"path/filepath"
+	"reflect"
 	"testing"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
 )
 
 func TestGini(t *testing.T) {
 
-	left := []string{"red", "red", "red", "red", "red", "blue"}
-	right := []string{"blue", "blue", "blue", "blue"}
+	// Labels as class numbers, red = 0 and blue = 1
+	left := []float64{0, 0, 0, 0, 0, 1}
+	right := []float64{1, 1, 1, 1}
 
-	if math.Abs(giniIndex(left)-.27778) > .0001 {
-		t.Errorf("Left %f instead of .278", giniIndex(left))
+	g := Gini{}
+	if math.Abs(g.Impurity(left, nil)-.27778) > .0001 {
+		t.Errorf("Left %f instead of .278", g.Impurity(left, nil))
 	}
-	if giniIndex(right) != 0 {
-		t.Errorf("Left %f instead of 0", giniIndex(right))
+	if g.Impurity(right, nil) != 0 {
+		t.Errorf("Left %f instead of 0", g.Impurity(right, nil))
 	}
-	comb := giniCombined(left, right)
+	comb := g.Score(left, right, nil, nil)
 	if math.Abs(comb-.16667) > .0001 {
 		t.Errorf("Combined %f instead of .167", comb)
 	}
 }
+
+// Test the other split criteria
+func TestCriteria(t *testing.T) {
+	left := []float64{0, 0, 0, 0, 0, 1}
+	right := []float64{1, 1, 1, 1}
+
+	// Entropy of 5:1 is .650 bits, the gain of the split of 5:5 is
+	// 1 - .6 * .650 = .610, and the split information of 6:4 is .971
+	e := Entropy{}
+	if math.Abs(e.Impurity(left, nil)-.650) > .001 || e.Impurity(right, nil) != 0 {
+		t.Errorf("Wrong entropy %f", e.Impurity(left, nil))
+	}
+	if ratio := -(GainRatio{}).Score(left, right, nil, nil); math.Abs(ratio-.610/.971) > .001 {
+		t.Errorf("Wrong gain ratio %f", ratio)
+	}
+
+	// Regression: MSE is the variance, MAE the mean distance from the
+	// median, which is less affected by the outlier
+	y := []float64{1, 2, 3, 4, 100}
+	if mse := (MSE{}).Impurity(y, nil); math.Abs(mse-1522) > .01 {
+		t.Errorf("Wrong MSE %f", mse)
+	}
+	if mae := (MAE{}).Impurity(y, nil); math.Abs(mae-20.2) > 1e-9 || median(y, nil) != 3 {
+		t.Errorf("Wrong MAE %f", mae)
+	}
+	if median(y, []float64{1, 1, 1, 1, 10}) != 100 {
+		t.Error("Wrong weighted median")
+	}
+
+	// All classification criteria should give a good tree on Iris
+	df, _ := utils.ReadCSV("../data/iris.csv")
+	for _, c := range []Criterion{Gini{}, Entropy{}, GainRatio{}} {
+		tree := DecisionTreeWith(df, "variety", TreeParams{MaxDepth: 3, MinLeaf: 2, Criterion: c})
+		var correct int
+		for i := 0; i < df.NRows(); i++ {
+			if Predict(tree, df.GetRow(i)) == df.GetColumn("variety").Strings[i] {
+				correct++
+			}
+		}
+		if correct < 140 {
+			t.Errorf("%T: only %d of 150 correct", c, correct)
+		}
+	}
+
+	// MAE leaves predict the median
+	X := mat.NewDense(5, 1, []float64{1, 2, 3, 4, 5})
+	Y := mat.NewDense(5, 1, y)
+	m := TreeRegressor{Params: TreeParams{MinLeaf: 10, Criterion: MAE{}}}
+	m.Fit(X, Y)
+	if m.Tree().Mean != 3 {
+		t.Errorf("MAE leaf predicted %v instead of 3", m.Tree().Mean)
+	}
+
+	// Criterion for the wrong type of tree
+	if (&TreeRegressor{Params: TreeParams{Criterion: Entropy{}}}).Fit(X, Y) == nil {
+		t.Error("Regression tree accepted entropy")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Classification tree accepted MSE")
+		}
+	}()
+	DecisionTreeWith(df, "variety", TreeParams{Criterion: MSE{}})
+}
+
+// Test the tree and forest classifiers on matrices
+func TestTreeClassifier(t *testing.T) {
+
+	// Two numeric features, class depends on the first one
+	X := mat.NewDense(8, 2, []float64{1, 5, 2, 3, 3, 8, 4, 1, 6, 2, 7, 9, 8, 4, 9, 6})
+	Y := mat.NewDense(8, 1, []float64{0, 0, 0, 0, 2, 2, 2, 2})
+	models := []utils.Classifier{
+		&TreeClassifier{Params: TreeParams{MaxDepth: 3, MinLeaf: 1}},
+		&ForestClassifier{NTrees: 25, Params: TreeParams{MaxDepth: 3, MinLeaf: 1}},
+	}
+	for _, m := range models {
+		filename := filepath.Join(t.TempDir(), "model.gob")
+		if err := m.(interface{ Save(string) error }).Save(filename); err == nil {
+			t.Errorf("%T: saved a model that has not been trained", m)
+		}
+		if err := m.Fit(X, Y); err != nil {
+			t.Fatal(err)
+		}
+		preds := m.Predict(X)
+		probs := m.PredictProba(X)
+		if !mat.Equal(preds, Y) || utils.MatCols(probs) != 2 {
+			t.Errorf("%T: wrong predictions %v", m, mat.Formatted(preds.T()))
+		}
+		for i := 0; i < 8; i++ {
+			if math.Abs(floats.Sum(probs.RawRowView(i))-1) > 1e-9 {
+				t.Errorf("%T: probabilities in row %d do not add up to 1", m, i)
+			}
+		}
+	}
+}
+
+// Test saving and loading a tree
+func TestSaveTree(t *testing.T) {
+	df, err := utils.ReadCSV("../data/iris.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := DecisionTreeWith(df, "variety", TreeParams{MaxDepth: 4, MinLeaf: 2})
+	for _, name := range []string{"tree.json", "tree.gob"} {
+		filename := filepath.Join(t.TempDir(), name)
+		if err := SaveTree(tree, filename); err != nil {
+			t.Fatal(err)
+		}
+		tree2, err := LoadTree(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(tree, tree2) {
+			t.Errorf("%s: tree did not round-trip", name)
+		}
+		if _, err := LoadForest(filename); err == nil {
+			t.Errorf("%s: loaded tree as a forest", name)
+		}
+	}
+}
+
+// Test weighted Gini index, and class weights in a tree
+func TestWeightedTree(t *testing.T) {
+
+	// A weight of 2 is the same as repeating the row
+	labels := []float64{0, 0, 1}
+	g := Gini{}
+	if math.Abs(g.Impurity(labels, []float64{1, 1, 2})-g.Impurity(append(labels, 1), nil)) > 1e-12 {
+		t.Error("Weighted Gini differs from repeated rows")
+	}
+
+	// The right side of the best split has more of class 0, unless the
+	// classes are balanced
+	X := mat.NewDense(10, 1, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	Y := mat.NewDense(10, 1, []float64{0, 0, 0, 0, 0, 1, 0, 1, 0, 0})
+	for _, cw := range []string{"", utils.ClassWeightBalanced} {
+		m := TreeClassifier{Params: TreeParams{MaxDepth: 1, MinLeaf: 1, ClassWeight: cw}}
+		if err := m.Fit(X, Y); err != nil {
+			t.Fatal(err)
+		}
+		expect := utils.IfThenElse(cw == "", 0.0, 1.0)
+		if pred := m.Predict(X).At(5, 0); pred != expect {
+			t.Errorf("Class weight %q: predicted %v instead of %v", cw, pred, expect)
+		}
+	}
+
+	// Sample weights change the best split: a heavy row of class 0 at 9
+	// means it is better to split off just the last row
+	Y = mat.NewDense(10, 1, []float64{0, 0, 0, 0, 0, 0, 0, 1, 0, 1})
+	m := TreeClassifier{Params: TreeParams{MaxDepth: 1, MinLeaf: 1}}
+	weights := []float64{1, 1, 1, 1, 1, 1, 1, 1, 10, 1}
+	for _, w := range [][]float64{nil, weights} {
+		if err := m.FitWeighted(X, Y, w); err != nil {
+			t.Fatal(err)
+		}
+		expect := utils.IfThenElse(w == nil, 7.5, 9.5)
+		if m.Tree().SplitNum != expect {
+			t.Errorf("Split at %v instead of %v", m.Tree().SplitNum, expect)
+		}
+	}
+	if m.FitWeighted(X, Y, weights[:5]) == nil {
+		t.Error("Accepted wrong number of weights")
+	}
+}
+
+// Test regression trees and forests
+func TestRegressionTree(t *testing.T) {
+
+	// Variance, with and without weights
+	if math.Abs(variance([]float64{1, 2, 3, 4}, nil)-1.25) > 1e-12 {
+		t.Error("Wrong variance")
+	}
+	if math.Abs(variance([]float64{1, 3}, []float64{3, 1})-.75) > 1e-12 {
+		t.Error("Wrong weighted variance")
+	}
+
+	// A step function is learned exactly, leaves predict the mean
+	X := mat.NewDense(8, 2, []float64{1, 5, 2, 3, 3, 8, 4, 1, 6, 2, 7, 9, 8, 4, 9, 6})
+	Y := mat.NewDense(8, 1, []float64{1, 1, 1, 1, 10, 10, 12, 12})
+	m := TreeRegressor{Params: TreeParams{MaxDepth: 3, MinLeaf: 1}}
+	if err := m.Fit(X, Y); err != nil {
+		t.Fatal(err)
+	}
+	PrintTree(m.Tree(), 0)
+	if !mat.Equal(m.Predict(X), Y) {
+		t.Errorf("Wrong predictions %v", mat.Formatted(m.Predict(X).T()))
+	}
+	m = TreeRegressor{Params: TreeParams{MaxDepth: 1, MinLeaf: 1}}
+	m.Fit(X, Y)
+	if m.Tree().SplitNum != 5 || m.Tree().Right.Mean != 11 {
+		t.Error("Wrong first split")
+	}
+
+	// A forest averages the trees, so predictions are between the
+	// smallest and largest values
+	f := ForestRegressor{NTrees: 20, Params: TreeParams{MaxDepth: 3, MinLeaf: 1}}
+	if err := f.Fit(X, Y); err != nil {
+		t.Fatal(err)
+	}
+	preds := f.Predict(X)
+	if mat.Min(preds) < 1 || mat.Max(preds) > 12 || preds.At(0, 0) > preds.At(7, 0) {
+		t.Errorf("Wrong forest predictions %v", mat.Formatted(preds.T()))
+	}
+
+	// Save and load, but not before training
+	filename := filepath.Join(t.TempDir(), "forest.json")
+	if (&TreeRegressor{}).Save(filename) == nil || (&ForestRegressor{}).Save(filename) == nil {
+		t.Error("Saved a regressor that has not been trained")
+	}
+	if err := f.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	f2 := ForestRegressor{}
+	if err := f2.Load(filename); err != nil {
+		t.Fatal(err)
+	}
+	if !mat.Equal(f2.Predict(X), preds) {
+		t.Error("Forest regressor did not round-trip")
+	}
+
+	// A single tree is not loaded from a file with no trees or many trees
+	for _, trees := range []Forest{nil, {Node{}, Node{}}} {
+		if err := utils.SaveModel(filename, "TreeRegressor", treeVersion, classifierData{Trees: trees}); err != nil {
+			t.Fatal(err)
+		}
+		if (&TreeRegressor{}).Load(filename) == nil {
+			t.Errorf("Loaded a tree regressor from %d trees", len(trees))
+		}
+	}
+
+	// Works on dataframes with an integer target
+	df, _ := utils.ReadCSV("../data/pizza_3_vars.txt")
+	tree := RegressionTree(df, "Pizzas", TreeParams{MaxDepth: 2, MinLeaf: 1})
+	if p := PredictValue(tree, df.GetRow(0)); p < 20 || p > 60 {
+		t.Errorf("Unlikely prediction %v", p)
+	}
+	if (&TreeRegressor{Params: TreeParams{ClassWeight: "balanced"}}).Fit(X, Y) == nil {
+		t.Error("Regressor accepted class weights")
+	}
+}
+
+// Test cost-complexity and reduced-error pruning
+func TestPruning(t *testing.T) {
+
+	// Labels a, a, b, a: the root split at 2.5 has Gini .375 -> .25, then
+	// the right side 1:1 is split into pure leaves. Making the right side a
+	// leaf costs .25 for one leaf, making the root a leaf costs .375 for
+	// two leaves, so the root is the weakest link, at alpha .1875.
+	df := utils.FromMatrix(mat.NewDense(4, 1, []float64{1, 2, 3, 4}), nil)
+	*df = append(*df, utils.Series{Name: "y", Dtype: "string", Strings: []string{"a", "a", "b", "a"}})
+	tree := DecisionTreeWith(df, "y", TreeParams{MaxDepth: 5, MinLeaf: 1})
+	alphas, impurities := CostComplexityPath(tree)
+	if len(alphas) != 2 || math.Abs(alphas[1]-.1875) > 1e-12 || impurities[0] != 0 || math.Abs(impurities[1]-.375) > 1e-12 {
+		t.Errorf("Wrong path %v %v", alphas, impurities)
+	}
+	PrintTree(Prune(tree, .18), 0)
+	if countLeaves(Prune(tree, .18)) != 3 || !Prune(tree, .19).IsLeaf() || Prune(tree, .19).Value != "a" {
+		t.Error("Wrong pruning of small tree")
+	}
+	if countLeaves(tree) != 3 {
+		t.Error("Pruning changed the original tree")
+	}
+
+	// Titanic: along the path, alpha and impurity go up, and the tree gets
+	// smaller, down to just the root
+	titanic := GetTitanicData("../data/titanic.csv")
+	train, valid := utils.TrainTestSplit(titanic, .25, "Survived", 42)
+	tree = DecisionTreeWith(train, "Survived", TreeParams{MaxDepth: 20, MinLeaf: 1})
+	alphas, impurities = CostComplexityPath(tree)
+	leaves := countLeaves(tree) + 1
+	for i := range alphas {
+		pruned := Prune(tree, alphas[i])
+		if i > 0 && (alphas[i] <= alphas[i-1] || impurities[i] < impurities[i-1] || countLeaves(pruned) >= leaves) {
+			t.Fatalf("Path not in order at step %d: alpha %v, impurity %v", i, alphas[i], impurities[i])
+		}
+		leaves = countLeaves(pruned)
+	}
+	if leaves != 1 || math.Abs(impurities[len(impurities)-1]-tree.Impurity) > 1e-12 {
+		t.Errorf("Path ends with %d leaves, impurity %v", leaves, impurities[len(impurities)-1])
+	}
+
+	// Pruning during training gives the same tree
+	k := len(alphas) / 2
+	if !reflect.DeepEqual(Prune(tree, alphas[k]), DecisionTreeWith(train, "Survived", TreeParams{MaxDepth: 20, MinLeaf: 1, CCPAlpha: alphas[k]})) {
+		t.Error("CCPAlpha differs from Prune")
+	}
+	if (&TreeClassifier{Params: TreeParams{CCPAlpha: -1}}).Fit(mat.NewDense(1, 1, nil), mat.NewDense(1, 1, nil)) == nil {
+		t.Error("Accepted negative CCPAlpha")
+	}
+
+	// Reduced-error pruning makes no more errors on the held out rows
+	errors := func(tree *Node) int {
+		var n int
+		for i := 0; i < valid.NRows(); i++ {
+			if Predict(tree, valid.GetRow(i)) != valid.GetColumn("Survived").Strings[i] {
+				n++
+			}
+		}
+		return n
+	}
+	pruned := PruneReducedError(tree, valid, "Survived")
+	t.Logf("Reduced-error pruning: %d leaves to %d, errors %d to %d", countLeaves(tree), countLeaves(pruned),
+		errors(tree), errors(pruned))
+	if countLeaves(pruned) >= countLeaves(tree) || errors(pruned) > errors(tree) {
+		t.Error("Reduced-error pruning did not help")
+	}
+
+	// Regression: the root's impurity is the variance, and pruning
+	// against the training rows keeps all but the useless splits
+	pizza, _ := utils.ReadCSV("../data/pizza_3_vars.txt")
+	tree = RegressionTree(pizza, "Pizzas", TreeParams{MaxDepth: 20, MinLeaf: 1})
+	y := columnFloats(pizza.GetColumn("Pizzas"))
+	_, impurities = CostComplexityPath(tree)
+	if math.Abs(impurities[len(impurities)-1]-variance(y, nil)) > 1e-9 || impurities[0] > 1e-9 {
+		t.Errorf("Wrong regression path impurities %v", impurities)
+	}
+	pruned = PruneReducedError(tree, pizza, "Pizzas")
+	for i := range y {
+		if PredictValue(pruned, pizza.GetRow(i)) != y[i] {
+			t.Fatalf("Row %d: predicted %v instead of %v", i, PredictValue(pruned, pizza.GetRow(i)), y[i])
+		}
+	}
+}
+
+// Test learning a direction for missing values at each split
+func TestMissingValues(t *testing.T) {
+
+	// The rows missing x are all "a", like the rows with small x, so they
+	// should go left, and a tree of depth 1 is then perfect
+	M := utils.MISSING_FLOAT
+	df := utils.DataFrame{
+		{Name: "x", Dtype: "float64", Floats: []float64{1, M, 2, 3, M, 4, 5, M, 6, M}},
+		{Name: "s", Dtype: "string", Strings: []string{"u", "", "u", "v", "", "v", "v", "", "v", ""}},
+		{Name: "y", Dtype: "string", Strings: []string{"a", "a", "a", "a", "a", "b", "b", "a", "b", "a"}},
+	}
+	tree := DecisionTreeWith(&df, "y", TreeParams{MaxDepth: 1, MinLeaf: 1})
+	PrintTree(tree, 0)
+	if tree.SplitVar != "x" || tree.SplitNum != 3.5 || !tree.MissingLeft || tree.G != 0 {
+		t.Errorf("Wrong split on %s at %v, missing left %v", tree.SplitVar, tree.SplitNum, tree.MissingLeft)
+	}
+	if p := Predict(tree, df.GetRow(1)); p != "a" {
+		t.Errorf("Row with missing x predicted %q", p)
+	}
+
+	// Same for a string column, where "" is missing: "u" or missing go left
+	tree = DecisionTreeWith(df.DropColumns([]string{"x"}), "y", TreeParams{MaxDepth: 1, MinLeaf: 1})
+	if tree.SplitCat != "u" || !tree.MissingLeft || tree.Left.Value != "a" || tree.Right.Value != "b" {
+		t.Errorf("Wrong split on %s == %q, missing left %v", tree.SplitVar, tree.SplitCat, tree.MissingLeft)
+	}
+
+	// NaN in a matrix is missing too, and the same split is found for
+	// regression
+	X := mat.NewDense(10, 1, df[0].Floats)
+	Y := mat.NewDense(10, 1, []float64{0, 0, 0, 0, 0, 1, 1, 0, 1, 0})
+	for i := 0; i < 10; i++ {
+		if X.At(i, 0) == M {
+			X.Set(i, 0, math.NaN())
+		}
+	}
+	m := TreeRegressor{Params: TreeParams{MaxDepth: 1, MinLeaf: 1}}
+	if err := m.Fit(X, Y); err != nil {
+		t.Fatal(err)
+	}
+	if !m.Tree().MissingLeft || !mat.Equal(m.Predict(X), Y) {
+		t.Errorf("Wrong predictions %v", mat.Formatted(m.Predict(X).T()))
+	}
+
+	// Titanic: missing ages are left as they are
+	titanic := GetTitanicData("../data/titanic.csv")
+	age := titanic.GetColumn("Age")
+	var missing int
+	for i := range age.Floats {
+		if age.IsMissing(i) {
+			missing++
+		}
+	}
+	if missing != 177 || utils.MISSING_FLOAT != math.MaxFloat64 {
+		t.Errorf("%d missing ages", missing)
+	}
+}
+
+// Test class probabilities of trees, and soft voting in forests
+func TestPredictProba(t *testing.T) {
+
+	// Iris, one split: setosa on one side, the other two 50:50
+	iris, _ := utils.ReadCSV("../data/iris.csv")
+	tree := DecisionTreeWith(iris, "variety", TreeParams{MaxDepth: 1, MinLeaf: 1})
+	p0, p100 := PredictProba(tree, iris.GetRow(0)), PredictProba(tree, iris.GetRow(100))
+	if len(p0) != 1 || p0["Setosa"] != 1 || len(p100) != 2 || p100["Versicolor"] != .5 || p100["Virginica"] != .5 {
+		t.Errorf("Wrong probabilities %v %v", p0, p100)
+	}
+
+	// Matrices: the right leaf has one row of class 0 and three of class 1
+	X := mat.NewDense(6, 1, []float64{1, 2, 3, 4, 5, 6})
+	Y := mat.NewDense(6, 1, []float64{0, 0, 1, 0, 1, 1})
+	m := TreeClassifier{Params: TreeParams{MaxDepth: 1, MinLeaf: 1}}
+	if err := m.Fit(X, Y); err != nil {
+		t.Fatal(err)
+	}
+	if probs := m.PredictProba(X); probs.At(0, 0) != 1 || probs.At(5, 0) != .25 || probs.At(5, 1) != .75 {
+		t.Errorf("Wrong probabilities %v", mat.Formatted(probs))
+	}
+
+	// Two trees are 51:49 for "a", one is sure of "b": a hard vote would
+	// say "a", the average probability says "b". A leaf without counts
+	// is sure of its label.
+	forest := Forest{
+		{Value: "a", Counts: map[string]float64{"a": 51, "b": 49}},
+		{Value: "a", Counts: map[string]float64{"a": 51, "b": 49}},
+		{Value: "b"},
+	}
+	probs := RandomForestPredictProba(&forest, nil)
+	if math.Abs(probs["a"]-.34) > 1e-12 || math.Abs(probs["b"]-.66) > 1e-12 || RandomForestPredict(&forest, nil) != "b" {
+		t.Errorf("Wrong forest probabilities %v", probs)
+	}
+
+	// Titanic: the probabilities of a forest rank the rows well
+	titanic := GetTitanicData("../data/titanic.csv")
+	train, test := utils.TrainTestSplit(titanic, .2, "Survived", 42)
+	rf := RandomForestWith(train, "Survived", 50, TreeParams{MaxDepth: 6, MinLeaf: 2})
+	scores := make([]float64, test.NRows())
+	for i := range scores {
+		scores[i] = RandomForestPredictProba(rf, test.GetRow(i))["Yes"]
+	}
+	if auc := metrics.ROCAUC(test.GetColumn("Survived").Strings, scores, "Yes"); auc < .8 {
+		t.Errorf("Titanic: forest AUC %.3f", auc)
+	}
+}
+
+// Test impurity decrease feature importance
+func TestFeatureImportance(t *testing.T) {
+
+	// Labels a, a, b, a: the root split takes Gini .375 to .25, and the
+	// split of the right side takes .25 to 0, both on x, so x has all the
+	// importance
+	df := utils.FromMatrix(mat.NewDense(4, 2, []float64{1, 0, 2, 0, 3, 0, 4, 0}), nil)
+	*df = append(*df, utils.Series{Name: "y", Dtype: "string", Strings: []string{"a", "a", "b", "a"}})
+	tree := DecisionTreeWith(df, "y", TreeParams{MaxDepth: 5, MinLeaf: 1})
+	imp := FeatureImportance(tree)
+	if len(imp) != 1 || imp.Get("x0") != 1 {
+		t.Errorf("Wrong importance %v", imp)
+	}
+
+	// Titanic: importance adds up to 1, and sex matters most
+	titanic := GetTitanicData("../data/titanic.csv")
+	tree = DecisionTreeWith(titanic, "Survived", TreeParams{MaxDepth: 5, MinLeaf: 5})
+	forest := RandomForestWith(titanic, "Survived", 20, TreeParams{MaxDepth: 5, MinLeaf: 5})
+	for _, imp := range []metrics.ImportanceTable{FeatureImportance(tree), ForestImportance(forest)} {
+		var tot float64
+		for _, f := range imp {
+			tot += f.Importance
+		}
+		if math.Abs(tot-1) > 1e-9 || imp[0].Name != "Sex" {
+			t.Errorf("Unlikely importance %v", imp)
+		}
+	}
+	if ForestImportance(forest)[0].Std == 0 {
+		t.Error("Trees of the forest all have the same importance")
+	}
+}
+
+// Test random choices of columns (MaxFeatures) and split values
+// (ExtraTrees), and reproducible forests
+func TestRandomForestParams(t *testing.T) {
+	counts := map[MaxFeatures]int{
+		{}: 30, {Rule: FeaturesSqrt}: 5, {Rule: FeaturesLog2}: 4,
+		{Fraction: .5}: 15, {Fraction: .01}: 1, {Count: 7}: 7, {Count: 50}: 30,
+	}
+	for m, expect := range counts {
+		if n := m.count(30); n != expect {
+			t.Errorf("%+v: %d columns instead of %d", m, n, expect)
+		}
+	}
+	X := mat.NewDense(1, 1, nil)
+	for _, m := range []MaxFeatures{{Count: 1, Rule: FeaturesSqrt}, {Fraction: 2}, {Count: -1}, {Rule: "cube"}} {
+		if (&TreeClassifier{Params: TreeParams{MaxFeatures: m}}).Fit(X, X) == nil {
+			t.Errorf("Accepted %+v", m)
+		}
+	}
+
+	// One column per split: the same tree for the same seed, and the
+	// first split depends on the seed
+	titanic := GetTitanicData("../data/titanic.csv")
+	train, test := utils.TrainTestSplit(titanic, .2, "Survived", 42)
+	p := TreeParams{MaxDepth: 3, MinLeaf: 5, MaxFeatures: MaxFeatures{Count: 1}}
+	roots := map[string]bool{}
+	for seed := int64(0); seed < 10; seed++ {
+		p.Seed = seed
+		tree := DecisionTreeWith(train, "Survived", p)
+		if !reflect.DeepEqual(tree, DecisionTreeWith(train, "Survived", p)) {
+			t.Fatal("Different trees for the same seed")
+		}
+		roots[tree.SplitVar] = true
+	}
+	if len(roots) < 3 {
+		t.Errorf("First split only on %v", roots)
+	}
+
+	// Forests: the same for the same seed, and good on held out rows.
+	// Extra trees see all the rows (so the roots have the same impurity),
+	// and split at random values.
+	accuracy := func(forest *Forest) float64 {
+		var preds []string
+		for i := 0; i < test.NRows(); i++ {
+			preds = append(preds, RandomForestPredict(forest, test.GetRow(i)))
+		}
+		return metrics.Accuracy(test.GetColumn("Survived").Strings, preds)
+	}
+	tp := TreeParams{MaxDepth: 8, MinLeaf: 2, MaxFeatures: MaxFeatures{Rule: FeaturesSqrt}}
+	for _, extra := range []bool{false, true} {
+		fp := ForestParams{NTrees: 50, Tree: tp, ExtraTrees: extra, Seed: 7}
+		forest := TrainForest(train, "Survived", fp)
+		if !reflect.DeepEqual(forest, TrainForest(train, "Survived", fp)) {
+			t.Errorf("Extra trees %v: different forests for the same seed", extra)
+		}
+		if acc := accuracy(forest); acc < .78 {
+			t.Errorf("Extra trees %v: accuracy %.3f", extra, acc)
+		}
+		sameRows := (*forest)[0].Impurity == (*forest)[1].Impurity && (*forest)[1].Impurity == (*forest)[2].Impurity
+		if sameRows != extra || (extra && (*forest)[0].SplitNum == (*forest)[1].SplitNum) {
+			t.Errorf("Extra trees %v: wrong rows or split values", extra)
+		}
+	}
+
+	// Regression with extra trees on a step function
+	X = mat.NewDense(8, 2, []float64{1, 5, 2, 3, 3, 8, 4, 1, 6, 2, 7, 9, 8, 4, 9, 6})
+	Y := mat.NewDense(8, 1, []float64{1, 1, 1, 1, 10, 10, 12, 12})
+	r := ForestRegressor{NTrees: 50, Params: TreeParams{MaxDepth: 5, MinLeaf: 1}, ExtraTrees: true}
+	if err := r.Fit(X, Y); err != nil {
+		t.Fatal(err)
+	}
+	if preds := r.Predict(X); math.Abs(preds.At(0, 0)-1) > 1 || math.Abs(preds.At(7, 0)-12) > 1 {
+		t.Errorf("Wrong extra trees predictions %v", mat.Formatted(preds.T()))
+	}
+}
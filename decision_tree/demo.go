@@ -1,9 +1,10 @@
-// Two demos of the decision tree
+// Demos of the decision tree, and of pruning it
 
 package decision_tree
 
 import (
 	"fmt"
+	"mlcode/metrics"
 	"mlcode/utils"
 )
 
@@ -22,19 +23,15 @@ func DecisionTreeDemo() {
 	PrintTree(tree, 0)
 
 	// Make predictions
-	correct := 0
+	preds := []string{}
 	for i := 0; i < df.NRows(); i++ {
-		row := df.GetRow(i)
-		pred := Predict(tree, row)
-		actual := row.GetColumn("variety").Strings[0]
-		if pred == actual {
-			correct++
-		}
+		preds = append(preds, Predict(tree, df.GetRow(i)))
 	}
 
 	// Report accuracy
-	acc := float64(correct) / float64(df.NRows()) * 100
-	fmt.Println(correct, "of", df.NRows(), "correct =", acc, "%")
+	actual := df.GetColumn("variety").Strings
+	metrics.Confusion(actual, preds).Print()
+	fmt.Println("Accuracy =", metrics.Accuracy(actual, preds))
 }
 
 // Another demo, using the Titanic data set (predict 1/0, other variables
@@ -60,17 +57,64 @@ func DecisionTreeDemo2() {
 	}
 
 	// Make predictions
-	correct := 0
+	preds := []string{}
 	for i := 0; i < df.NRows(); i++ {
-		row := df.GetRow(i)
-		pred := Predict(tree, row)
-		actual := row.GetColumn("Survived").Strings[0]
-		if pred == actual {
-			correct++
-		}
+		preds = append(preds, Predict(tree, df.GetRow(i)))
 	}
 
 	// Report accuracy
-	acc := float64(correct) / float64(df.NRows()) * 100
-	fmt.Println(correct, "of", df.NRows(), "correct =", acc, "%")
+	actual := df.GetColumn("Survived").Strings
+	metrics.Confusion(actual, preds).Print()
+	fmt.Println("Accuracy =", metrics.Accuracy(actual, preds))
+}
+
+// Demo of pruning a decision tree, using the Titanic data set: grow a large
+// tree, then prune it by cost-complexity pruning (choosing alpha on held
+// out rows) and by reduced-error pruning, and compare on test rows
+func PruningDemo() {
+
+	// Training, validation and test rows
+	df := GetTitanicData("data/titanic.csv")
+	if !df.Check() {
+		return
+	}
+	train, test := utils.TrainTestSplit(df, .2, "Survived", 42)
+	train, valid := utils.TrainTestSplit(train, .25, "Survived", 42)
+
+	// Accuracy of a tree on some rows
+	accuracy := func(tree *Node, df *utils.DataFrame) float64 {
+		preds := []string{}
+		for i := 0; i < df.NRows(); i++ {
+			preds = append(preds, Predict(tree, df.GetRow(i)))
+		}
+		return metrics.Accuracy(df.GetColumn("Survived").Strings, preds)
+	}
+
+	// A tree that fits the training rows too well
+	tree := DecisionTreeWith(train, "Survived", TreeParams{MaxDepth: 20, MinLeaf: 1})
+	fmt.Printf("Full tree: %d leaves, accuracy %.3f train, %.3f test\n",
+		countLeaves(tree), accuracy(tree, train), accuracy(tree, test))
+
+	// Try each alpha on the pruning path, keep the best on validation rows
+	alphas, impurities := CostComplexityPath(tree)
+	best, bestAcc := 0.0, 0.0
+	fmt.Println("   alpha  impurity  leaves  validation")
+	for i, alpha := range alphas {
+		pruned := Prune(tree, alpha)
+		acc := accuracy(pruned, valid)
+		if i%5 == 0 || i == len(alphas)-1 {
+			fmt.Printf("%8.5f  %8.4f  %6d  %10.3f\n", alpha, impurities[i], countLeaves(pruned), acc)
+		}
+		if acc > bestAcc {
+			best, bestAcc = alpha, acc
+		}
+	}
+	pruned := Prune(tree, best)
+	fmt.Printf("Cost-complexity pruning, alpha %.5f: %d leaves, accuracy %.3f test\n",
+		best, countLeaves(pruned), accuracy(pruned, test))
+
+	// Reduced-error pruning against the same validation rows
+	pruned = PruneReducedError(tree, valid, "Survived")
+	fmt.Printf("Reduced-error pruning: %d leaves, accuracy %.3f test\n",
+		countLeaves(pruned), accuracy(pruned, test))
 }
@@ -0,0 +1,392 @@
+// Decision tree, random forest, gradient boosting and AdaBoost classifiers
+// and regressors on matrices,
+// for the utils.Estimator interface. Each column of X becomes a numeric
+// column of a dataframe, and Y becomes a string column of class labels
+// for classifiers, or a numeric column for regressors.
+
+package decision_tree
+
+import (
+	"errors"
+	"mlcode/utils"
+	"strconv"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Name of the label column in dataframes created from matrices
+const labelCol = "label"
+
+// Decision tree classifier
+type TreeClassifier struct {
+	Params  TreeParams // parameters for training, zero values use defaults
+	tree    *Node
+	classes []float64
+}
+
+// Train the decision tree
+func (m *TreeClassifier) Fit(X, Y *mat.Dense) error {
+	return m.FitWeighted(X, Y, nil)
+}
+
+// Train the decision tree with a weight for each row (nil for none), which
+// is combined with Params.ClassWeight
+func (m *TreeClassifier) FitWeighted(X, Y *mat.Dense, weights []float64) error {
+	df, p, err := weightedDataFrame(X, Y, weights, m.Params)
+	if err != nil {
+		return err
+	}
+	m.classes = utils.Classes(Y)
+	m.tree = DecisionTreeWith(df, labelCol, p)
+	return nil
+}
+
+// Predict class label for each row
+func (m *TreeClassifier) Predict(X *mat.Dense) *mat.Dense {
+	return predictRows(X, func(row *utils.DataFrame) string {
+		return Predict(m.tree, row)
+	})
+}
+
+// Predict class probabilities, the share of each class in the leaf
+func (m *TreeClassifier) PredictProba(X *mat.Dense) *mat.Dense {
+	return predictProbaRows(X, m.classes, func(row *utils.DataFrame) map[string]float64 {
+		return PredictProba(m.tree, row)
+	})
+}
+
+// The trained tree
+func (m *TreeClassifier) Tree() *Node {
+	return m.tree
+}
+
+// Random forest classifier
+type ForestClassifier struct {
+	NTrees     int        // number of trees, default 100
+	Params     TreeParams // parameters for training each tree, zero values use defaults
+	ExtraTrees bool       // random split values, and no samples of rows, see ForestParams
+	Seed       int64      // for the random choices
+	forest     *Forest
+	classes    []float64
+}
+
+// Train the random forest
+func (m *ForestClassifier) Fit(X, Y *mat.Dense) error {
+	return m.FitWeighted(X, Y, nil)
+}
+
+// Train the random forest with a weight for each row (nil for none), which
+// is combined with Params.ClassWeight
+func (m *ForestClassifier) FitWeighted(X, Y *mat.Dense, weights []float64) error {
+	df, p, err := weightedDataFrame(X, Y, weights, m.Params)
+	if err != nil {
+		return err
+	}
+	m.classes = utils.Classes(Y)
+	m.forest = TrainForest(df, labelCol, ForestParams{NTrees: m.NTrees, Tree: p, ExtraTrees: m.ExtraTrees, Seed: m.Seed})
+	return nil
+}
+
+// Predict class label for each row, the one with the highest probability
+func (m *ForestClassifier) Predict(X *mat.Dense) *mat.Dense {
+	return predictRows(X, func(row *utils.DataFrame) string {
+		return RandomForestPredict(m.forest, row)
+	})
+}
+
+// Predict class probabilities, the average of those from each tree
+func (m *ForestClassifier) PredictProba(X *mat.Dense) *mat.Dense {
+	return predictProbaRows(X, m.classes, func(row *utils.DataFrame) map[string]float64 {
+		return RandomForestPredictProba(m.forest, row)
+	})
+}
+
+// Regression tree
+type TreeRegressor struct {
+	Params TreeParams // parameters for training, zero values use defaults
+	tree   *Node
+}
+
+// Train the regression tree
+func (m *TreeRegressor) Fit(X, Y *mat.Dense) error {
+	return m.FitWeighted(X, Y, nil)
+}
+
+// Train the regression tree with a weight for each row (nil for none)
+func (m *TreeRegressor) FitWeighted(X, Y *mat.Dense, weights []float64) error {
+	df, p, err := regressionDataFrame(X, Y, weights, m.Params)
+	if err != nil {
+		return err
+	}
+	m.tree = RegressionTree(df, labelCol, p)
+	return nil
+}
+
+// Predict the target value for each row
+func (m *TreeRegressor) Predict(X *mat.Dense) *mat.Dense {
+	return predictValues(X, func(row *utils.DataFrame) float64 {
+		return PredictValue(m.tree, row)
+	})
+}
+
+// The trained tree
+func (m *TreeRegressor) Tree() *Node {
+	return m.tree
+}
+
+// Random forest regressor
+type ForestRegressor struct {
+	NTrees     int        // number of trees, default 100
+	Params     TreeParams // parameters for training each tree, zero values use defaults
+	ExtraTrees bool       // random split values, and no samples of rows, see ForestParams
+	Seed       int64      // for the random choices
+	forest     *Forest
+}
+
+// Train the random forest
+func (m *ForestRegressor) Fit(X, Y *mat.Dense) error {
+	return m.FitWeighted(X, Y, nil)
+}
+
+// Train the random forest with a weight for each row (nil for none)
+func (m *ForestRegressor) FitWeighted(X, Y *mat.Dense, weights []float64) error {
+	df, p, err := regressionDataFrame(X, Y, weights, m.Params)
+	if err != nil {
+		return err
+	}
+	m.forest = TrainForestRegressor(df, labelCol, ForestParams{NTrees: m.NTrees, Tree: p, ExtraTrees: m.ExtraTrees, Seed: m.Seed})
+	return nil
+}
+
+// Predict the target value for each row, the average over the trees
+func (m *ForestRegressor) Predict(X *mat.Dense) *mat.Dense {
+	return predictValues(X, func(row *utils.DataFrame) float64 {
+		return RandomForestPredictValue(m.forest, row)
+	})
+}
+
+// Gradient boosting classifier, see boosting.go
+type BoostClassifier struct {
+	Params  BoostParams // settings for training, zero values use defaults
+	booster *Booster
+	classes []float64
+}
+
+// Train the gradient boosting model
+func (m *BoostClassifier) Fit(X, Y *mat.Dense) error {
+	if err := utils.CheckXY(X, Y); err != nil {
+		return err
+	}
+	b, err := GradientBoost(matrixToDataFrame(X, Y), labelCol, m.Params)
+	if err != nil {
+		return err
+	}
+	m.booster, m.classes = b, utils.Classes(Y)
+	return nil
+}
+
+// Predict the most likely class for each row
+func (m *BoostClassifier) Predict(X *mat.Dense) *mat.Dense {
+	labels := m.booster.PredictLabels(utils.FromMatrix(X, nil))
+	res := mat.NewDense(len(labels), 1, nil)
+	for i, l := range labels {
+		res.Set(i, 0, m.classes[classIndex(l, m.classes)])
+	}
+	return res
+}
+
+// Predict the probability of each class, one column per class in order
+// of the numeric class labels
+func (m *BoostClassifier) PredictProba(X *mat.Dense) *mat.Dense {
+	probs := m.booster.PredictProba(utils.FromMatrix(X, nil))
+	nr, _ := X.Dims()
+	res := mat.NewDense(nr, len(m.classes), nil)
+	for k, l := range m.booster.Classes {
+		res.SetCol(classIndex(l, m.classes), mat.Col(nil, k, probs))
+	}
+	return res
+}
+
+// Trained model, nil before training
+func (m *BoostClassifier) Booster() *Booster {
+	return m.booster
+}
+
+// Gradient boosting regressor, see boosting.go
+type BoostRegressor struct {
+	Params  BoostParams // settings for training, zero values use defaults
+	booster *Booster
+}
+
+// Train the gradient boosting model
+func (m *BoostRegressor) Fit(X, Y *mat.Dense) error {
+	df, _, err := regressionDataFrame(X, Y, nil, TreeParams{})
+	if err != nil {
+		return err
+	}
+	m.booster, err = GradientBoost(df, labelCol, m.Params)
+	return err
+}
+
+// Predict the target value for each row
+func (m *BoostRegressor) Predict(X *mat.Dense) *mat.Dense {
+	preds := m.booster.PredictValues(utils.FromMatrix(X, nil))
+	return mat.NewDense(len(preds), 1, preds)
+}
+
+// Trained model, nil before training
+func (m *BoostRegressor) Booster() *Booster {
+	return m.booster
+}
+
+// AdaBoost classifier, see adaboost.go
+type AdaClassifier struct {
+	Params  AdaParams // settings for training, zero values use defaults
+	model   *AdaBoost
+	classes []float64
+}
+
+// Train the AdaBoost model
+func (m *AdaClassifier) Fit(X, Y *mat.Dense) error {
+	if err := utils.CheckXY(X, Y); err != nil {
+		return err
+	}
+	model, err := TrainAdaBoost(matrixToDataFrame(X, Y), labelCol, m.Params)
+	if err != nil {
+		return err
+	}
+	m.model, m.classes = model, utils.Classes(Y)
+	return nil
+}
+
+// Predict the class with the most weighted votes for each row
+func (m *AdaClassifier) Predict(X *mat.Dense) *mat.Dense {
+	labels := m.model.PredictLabels(utils.FromMatrix(X, nil))
+	res := mat.NewDense(len(labels), 1, nil)
+	for i, l := range labels {
+		res.Set(i, 0, m.classes[classIndex(l, m.classes)])
+	}
+	return res
+}
+
+// Share of the weighted vote for each class, one column per class in
+// order of the numeric class labels
+func (m *AdaClassifier) PredictProba(X *mat.Dense) *mat.Dense {
+	votes := m.model.PredictProba(utils.FromMatrix(X, nil))
+	nr, _ := X.Dims()
+	res := mat.NewDense(nr, len(m.classes), nil)
+	for k, l := range m.model.Classes {
+		res.SetCol(classIndex(l, m.classes), mat.Col(nil, k, votes))
+	}
+	return res
+}
+
+// Trained model, nil before training
+func (m *AdaClassifier) Model() *AdaBoost {
+	return m.model
+}
+
+// Check the data and weights for a regressor, and convert to a dataframe
+// with Y as a numeric column, and the weights (if any) as another column
+func regressionDataFrame(X, Y *mat.Dense, weights []float64, p TreeParams) (*utils.DataFrame, TreeParams, error) {
+	if err := utils.CheckXY(X, Y); err != nil {
+		return nil, p, err
+	}
+	if p.ClassWeight != "" {
+		return nil, p, errors.New("class weights are only for classifiers")
+	}
+	if err := p.check(true); err != nil {
+		return nil, p, err
+	}
+	if _, err := utils.SampleWeights(mat.Col(nil, 0, Y), weights, ""); err != nil {
+		return nil, p, err
+	}
+	df := utils.FromMatrix(X, nil)
+	*df = append(*df, utils.Series{Name: labelCol, Dtype: "float64", Floats: mat.Col(nil, 0, Y)})
+	if weights != nil {
+		*df = append(*df, utils.Series{Name: weightCol, Dtype: "float64", Floats: weights})
+		p.WeightCol = weightCol
+	}
+	return df, p, nil
+}
+
+// Convert X and Y matrices to a dataframe, with Y as string labels
+func matrixToDataFrame(X, Y *mat.Dense) *utils.DataFrame {
+	df := utils.FromMatrix(X, nil)
+	nr, _ := Y.Dims()
+	labels := utils.Series{Name: labelCol, Dtype: "string", Strings: make([]string, nr)}
+	for i := 0; i < nr; i++ {
+		labels.Strings[i] = strconv.FormatFloat(Y.At(i, 0), 'g', -1, 64)
+	}
+	*df = append(*df, labels)
+	return df
+}
+
+// Check the data and weights, and convert to a dataframe, with the sample
+// weights (if any) as an extra column used by the tree parameters
+func weightedDataFrame(X, Y *mat.Dense, weights []float64, p TreeParams) (*utils.DataFrame, TreeParams, error) {
+	if err := utils.CheckXY(X, Y); err != nil {
+		return nil, p, err
+	}
+	if err := p.check(false); err != nil {
+		return nil, p, err
+	}
+	if _, err := utils.SampleWeights(mat.Col(nil, 0, Y), weights, p.ClassWeight); err != nil {
+		return nil, p, err
+	}
+	df := matrixToDataFrame(X, Y)
+	if weights != nil {
+		*df = append(*df, utils.Series{Name: weightCol, Dtype: "float64", Floats: weights})
+		p.WeightCol = weightCol
+	}
+	return df, p, nil
+}
+
+// Make a prediction for each row of a matrix, converting the string
+// labels back to numbers
+func predictRows(X *mat.Dense, predict func(row *utils.DataFrame) string) *mat.Dense {
+	nr, _ := X.Dims()
+	df := utils.FromMatrix(X, nil)
+	res := mat.NewDense(nr, 1, nil)
+	for i := 0; i < nr; i++ {
+		v, _ := strconv.ParseFloat(predict(df.GetRow(i)), 64)
+		res.Set(i, 0, v)
+	}
+	return res
+}
+
+// Predict class probabilities for each row of a matrix, one column for
+// each class, from the probabilities of the string labels
+func predictProbaRows(X *mat.Dense, classes []float64, proba func(row *utils.DataFrame) map[string]float64) *mat.Dense {
+	nr, _ := X.Dims()
+	df := utils.FromMatrix(X, nil)
+	res := mat.NewDense(nr, len(classes), nil)
+	for i := 0; i < nr; i++ {
+		for label, p := range proba(df.GetRow(i)) {
+			res.Set(i, classIndex(label, classes), p)
+		}
+	}
+	return res
+}
+
+// Make a numeric prediction for each row of a matrix
+func predictValues(X *mat.Dense, predict func(row *utils.DataFrame) float64) *mat.Dense {
+	nr, _ := X.Dims()
+	df := utils.FromMatrix(X, nil)
+	res := mat.NewDense(nr, 1, nil)
+	for i := 0; i < nr; i++ {
+		res.Set(i, 0, predict(df.GetRow(i)))
+	}
+	return res
+}
+
+// Position of a string label in the list of numeric class labels
+func classIndex(label string, classes []float64) int {
+	v, _ := strconv.ParseFloat(label, 64)
+	for i, c := range classes {
+		if c == v {
+			return i
+		}
+	}
+	panic("classIndex: unknown class " + label)
+}
@@ -0,0 +1,258 @@
+// Trees for histogram-based gradient boosting, see boosting.go. Before
+// training, the values of each column are put into a small number of bins
+// (at most 255), so a tree only has to consider a split between each pair
+// of bins, rather than each pair of values. For each node, a histogram
+// holds the totals of the gradients and hessians of the rows in each bin,
+// and one sweep through the bins then scores every split. Only the
+// histogram of the smaller child of each split is built from its rows, the
+// other is the parent's histogram less the smaller one. This is the
+// approach of LightGBM, and HistGradientBoostingRegressor in scikit-learn.
+
+package decision_tree
+
+import (
+	"fmt"
+	"mlcode/utils"
+	"sort"
+)
+
+// Smallest total hessian of each side of a split, as in scikit-learn
+const minHessian = 1e-3
+
+// Column put into bins. Numeric values go into bin b if they are below
+// thresholds[b] (and not below thresholds[b-1]), so a split after bin b
+// is the same as a split at thresholds[b] on the original values. String
+// values each have their own bin, up to the maximum, after which the less
+// common ones share a last bin, which is never split on.
+type binFeature struct {
+	name       string
+	thresholds []float64 // numeric: upper limit of each bin but the last
+	cats       []string  // categorical: category of each bin
+	other      bool      // categorical: whether the last bin is shared
+	bins       []uint8   // bin of each row
+}
+
+// Number of bins of a column
+func (f *binFeature) nBins() int {
+	if f.cats != nil {
+		return len(f.cats) + utils.IfThenElse(f.other, 1, 0)
+	}
+	return len(f.thresholds) + 1
+}
+
+// Put the values of a numeric column into at most maxBins bins, each
+// with about the same number of rows. If there are no more unique values
+// than bins, each value has its own bin.
+func numericBins(name string, values []float64, maxBins int) binFeature {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	unique := utils.Unique(sorted)
+	f := binFeature{name: name}
+	if len(unique) <= maxBins {
+		for i := 1; i < len(unique); i++ {
+			f.thresholds = append(f.thresholds, unique[i-1]+(unique[i]-unique[i-1])/2)
+		}
+	} else {
+		n := len(sorted)
+		for b := 1; b < maxBins; b++ {
+			lo, hi := sorted[b*n/maxBins-1], sorted[b*n/maxBins]
+			if t := lo + (hi-lo)/2; lo < hi && (f.thresholds == nil || t > f.thresholds[len(f.thresholds)-1]) {
+				f.thresholds = append(f.thresholds, t)
+			}
+		}
+	}
+	f.bins = make([]uint8, len(values))
+	for i, v := range values {
+		f.bins[i] = uint8(sort.Search(len(f.thresholds), func(j int) bool { return f.thresholds[j] > v }))
+	}
+	return f
+}
+
+// Put the values of a string column into bins, one for each of the
+// maxBins-1 most common values, and one for all the rest
+func categoricalBins(name string, values []string, maxBins int) binFeature {
+	counts := map[string]int{}
+	for _, v := range values {
+		counts[v]++
+	}
+	f := binFeature{name: name, cats: utils.Unique(values)}
+	if len(f.cats) > maxBins {
+		sort.SliceStable(f.cats, func(i, j int) bool { return counts[f.cats[i]] > counts[f.cats[j]] })
+		f.cats = f.cats[:maxBins-1]
+		f.other = true
+	}
+	index := map[string]int{}
+	for b, c := range f.cats {
+		index[c] = b
+	}
+	f.bins = make([]uint8, len(values))
+	for i, v := range values {
+		b, ok := index[v]
+		if !ok {
+			b = len(f.cats)
+		}
+		f.bins[i] = uint8(b)
+	}
+	return f
+}
+
+// Put each usable column of a dataframe into bins
+func makeBins(df *utils.DataFrame, depv string, maxBins int) []binFeature {
+	var features []binFeature
+	for _, c := range *df {
+		switch {
+		case c.Name == depv:
+		case c.Dtype == "float64" || c.Dtype == "int64":
+			features = append(features, numericBins(c.Name, columnFloats(&c), maxBins))
+		case c.Dtype == "string":
+			features = append(features, categoricalBins(c.Name, c.Strings, maxBins))
+		default:
+			fmt.Println("Warning: column ignored, type", c.Dtype)
+		}
+	}
+	return features
+}
+
+// Totals of the gradients and hessians of the rows in one bin
+type binStats struct {
+	g, h float64
+	n    int
+}
+
+// Histogram of a node: totals for each bin of each column
+type histogram [][]binStats
+
+// Builds one tree from the gradients and hessians of the rows
+type grower struct {
+	p        *BoostParams
+	features []binFeature
+	g, h     []float64 // gradient and hessian of the loss for each row
+}
+
+// Histogram of the given rows
+func (gr *grower) histogram(rows []int) histogram {
+	hist := make(histogram, len(gr.features))
+	for f := range gr.features {
+		hist[f] = make([]binStats, gr.features[f].nBins())
+		bins := gr.features[f].bins
+		for _, r := range rows {
+			s := &hist[f][bins[r]]
+			s.g += gr.g[r]
+			s.h += gr.h[r]
+			s.n++
+		}
+	}
+	return hist
+}
+
+// Histogram of the parent less that of one child, i.e., of the other child
+func (hist histogram) minus(child histogram) histogram {
+	res := make(histogram, len(hist))
+	for f := range hist {
+		res[f] = make([]binStats, len(hist[f]))
+		for b, s := range hist[f] {
+			res[f][b] = binStats{g: s.g - child[f][b].g, h: s.h - child[f][b].h, n: s.n - child[f][b].n}
+		}
+	}
+	return res
+}
+
+// How much a leaf for gradient total g and hessian total h reduces the
+// loss (times 2), as in XGBoost
+func (gr *grower) leafScore(g, h float64) float64 {
+	return g * g / (h + gr.p.L2)
+}
+
+// Grow a tree for the given rows and their histogram, recursively.
+// Leaves hold the change in the score of their rows, already scaled by
+// the learning rate, in Mean. For splits, G is minus the gain, so lower is
+// better, as for other trees.
+func (gr *grower) grow(rows []int, hist histogram, depth int) *Node {
+
+	// Totals of the node, from any column of the histogram
+	var G, H float64
+	for _, s := range hist[0] {
+		G += s.g
+		H += s.h
+	}
+	if depth >= gr.p.MaxDepth || len(rows) < 2*gr.p.MinLeaf {
+		return gr.leaf(G, H)
+	}
+
+	// Find the split with the highest gain, sweeping through the bins of
+	// each column
+	parent := gr.leafScore(G, H)
+	var bestGain float64
+	bestF, bestB := -1, 0
+	for f := range gr.features {
+		var gl, hl float64
+		var nl int
+		feature := &gr.features[f]
+		for b, s := range hist[f] {
+
+			// Numeric: left side is all the bins up to this one,
+			// categorical: left side is just this bin
+			if feature.cats != nil {
+				if b == len(feature.cats) {
+					break
+				}
+				gl, hl, nl = s.g, s.h, s.n
+			} else {
+				if b == len(hist[f])-1 {
+					break
+				}
+				gl, hl, nl = gl+s.g, hl+s.h, nl+s.n
+			}
+			if nl < gr.p.MinLeaf || len(rows)-nl < gr.p.MinLeaf || hl < minHessian || H-hl < minHessian {
+				continue
+			}
+			gain := gr.leafScore(gl, hl) + gr.leafScore(G-gl, H-hl) - parent
+			if gain > bestGain {
+				bestGain, bestF, bestB = gain, f, b
+			}
+		}
+	}
+	if bestF < 0 {
+		return gr.leaf(G, H)
+	}
+
+	// Apply the split
+	feature := &gr.features[bestF]
+	n := Node{SplitVar: feature.name, G: -bestGain}
+	if feature.cats != nil {
+		n.SplitCat = feature.cats[bestB]
+	} else {
+		n.SplitNum = feature.thresholds[bestB]
+	}
+	var left, right []int
+	for _, r := range rows {
+		b := int(feature.bins[r])
+		if b == bestB || (feature.cats == nil && b < bestB) {
+			left = append(left, r)
+		} else {
+			right = append(right, r)
+		}
+	}
+
+	// Histograms of the two sides, building the smaller one
+	var histLeft, histRight histogram
+	if len(left) < len(right) {
+		histLeft = gr.histogram(left)
+		histRight = hist.minus(histLeft)
+	} else {
+		histRight = gr.histogram(right)
+		histLeft = hist.minus(histRight)
+	}
+	n.Left = gr.grow(left, histLeft, depth+1)
+	n.Right = gr.grow(right, histRight, depth+1)
+	return &n
+}
+
+// Leaf with the value that minimizes the loss, by a Newton step, scaled
+// by the learning rate
+func (gr *grower) leaf(g, h float64) *Node {
+	if h+gr.p.L2 <= 0 {
+		return &Node{}
+	}
+	return &Node{Mean: -gr.p.LearningRate * g / (h + gr.p.L2)}
+}
@@ -0,0 +1,229 @@
+// Saving and loading trained trees and forests, as JSON if the file name
+// ends in .json, otherwise in binary gob format
+
+package decision_tree
+
+import (
+	"errors"
+	"mlcode/utils"
+)
+
+// Version of the saved data of the models in this package, which are all
+// made of trees, to be increased when Node or the data of a model changes
+// in an incompatible way. It continues the numbers of the file format
+// version once shared by all models, so that older tree files are rejected:
+//
+//	1: first version
+//	4: number of rows and impurity of each node
+//	5: direction of missing values at each split
+//	6: class counts of each node
+const treeVersion = 6
+
+// Save a decision tree to a file
+func SaveTree(tree *Node, filename string) error {
+	return utils.SaveModel(filename, "DecisionTree", treeVersion, tree)
+}
+
+// Load a decision tree from a file
+func LoadTree(filename string) (*Node, error) {
+	var tree Node
+	if err := utils.LoadModel(filename, "DecisionTree", treeVersion, &tree); err != nil {
+		return nil, err
+	}
+	return &tree, nil
+}
+
+// Save a random forest to a file
+func SaveForest(forest *Forest, filename string) error {
+	return utils.SaveModel(filename, "RandomForest", treeVersion, forest)
+}
+
+// Load a random forest from a file
+func LoadForest(filename string) (*Forest, error) {
+	var forest Forest
+	if err := utils.LoadModel(filename, "RandomForest", treeVersion, &forest); err != nil {
+		return nil, err
+	}
+	return &forest, nil
+}
+
+// Save a gradient boosting model to a file
+func SaveBooster(b *Booster, filename string) error {
+	return utils.SaveModel(filename, "GradientBoosting", treeVersion, b)
+}
+
+// Load a gradient boosting model from a file
+func LoadBooster(filename string) (*Booster, error) {
+	var b Booster
+	if err := utils.LoadModel(filename, "GradientBoosting", treeVersion, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// Save an AdaBoost model to a file
+func SaveAdaBoost(m *AdaBoost, filename string) error {
+	return utils.SaveModel(filename, "AdaBoost", treeVersion, m)
+}
+
+// Load an AdaBoost model from a file
+func LoadAdaBoost(filename string) (*AdaBoost, error) {
+	var m AdaBoost
+	if err := utils.LoadModel(filename, "AdaBoost", treeVersion, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Saved data for the classifiers and regressors on matrices (no classes
+// for regressors)
+type classifierData struct {
+	Trees   Forest
+	Classes []float64
+}
+
+// Save the trained tree classifier to a file
+func (m *TreeClassifier) Save(filename string) error {
+	if m.tree == nil {
+		return errors.New("model has not been trained")
+	}
+	return utils.SaveModel(filename, "TreeClassifier", treeVersion, classifierData{Trees: Forest{*m.tree}, Classes: m.classes})
+}
+
+// Load a trained tree classifier from a file
+func (m *TreeClassifier) Load(filename string) error {
+	var d classifierData
+	if err := utils.LoadModel(filename, "TreeClassifier", treeVersion, &d); err != nil {
+		return err
+	}
+	if len(d.Trees) != 1 {
+		return errors.New("TreeClassifier.Load: the file must contain exactly one tree")
+	}
+	m.tree, m.classes = &d.Trees[0], d.Classes
+	return nil
+}
+
+// Save the trained forest classifier to a file
+func (m *ForestClassifier) Save(filename string) error {
+	if m.forest == nil {
+		return errors.New("model has not been trained")
+	}
+	return utils.SaveModel(filename, "ForestClassifier", treeVersion, classifierData{Trees: *m.forest, Classes: m.classes})
+}
+
+// Load a trained forest classifier from a file
+func (m *ForestClassifier) Load(filename string) error {
+	var d classifierData
+	if err := utils.LoadModel(filename, "ForestClassifier", treeVersion, &d); err != nil {
+		return err
+	}
+	m.forest, m.classes = &d.Trees, d.Classes
+	m.NTrees = len(d.Trees)
+	return nil
+}
+
+// Save the trained regression tree to a file
+func (m *TreeRegressor) Save(filename string) error {
+	if m.tree == nil {
+		return errors.New("model has not been trained")
+	}
+	return utils.SaveModel(filename, "TreeRegressor", treeVersion, classifierData{Trees: Forest{*m.tree}})
+}
+
+// Load a trained regression tree from a file
+func (m *TreeRegressor) Load(filename string) error {
+	var d classifierData
+	if err := utils.LoadModel(filename, "TreeRegressor", treeVersion, &d); err != nil {
+		return err
+	}
+	if len(d.Trees) != 1 {
+		return errors.New("TreeRegressor.Load: the file must contain exactly one tree")
+	}
+	m.tree = &d.Trees[0]
+	return nil
+}
+
+// Save the trained forest regressor to a file
+func (m *ForestRegressor) Save(filename string) error {
+	if m.forest == nil {
+		return errors.New("model has not been trained")
+	}
+	return utils.SaveModel(filename, "ForestRegressor", treeVersion, classifierData{Trees: *m.forest})
+}
+
+// Load a trained forest regressor from a file
+func (m *ForestRegressor) Load(filename string) error {
+	var d classifierData
+	if err := utils.LoadModel(filename, "ForestRegressor", treeVersion, &d); err != nil {
+		return err
+	}
+	m.forest = &d.Trees
+	m.NTrees = len(d.Trees)
+	return nil
+}
+
+// Saved data for the gradient boosting classifier
+type boostData struct {
+	Booster *Booster
+	Classes []float64
+}
+
+// Save the trained gradient boosting classifier to a file
+func (m *BoostClassifier) Save(filename string) error {
+	if m.booster == nil {
+		return errors.New("model has not been trained")
+	}
+	return utils.SaveModel(filename, "BoostClassifier", treeVersion, boostData{Booster: m.booster, Classes: m.classes})
+}
+
+// Load a trained gradient boosting classifier from a file
+func (m *BoostClassifier) Load(filename string) error {
+	var d boostData
+	if err := utils.LoadModel(filename, "BoostClassifier", treeVersion, &d); err != nil {
+		return err
+	}
+	m.booster, m.classes = d.Booster, d.Classes
+	return nil
+}
+
+// Save the trained gradient boosting regressor to a file
+func (m *BoostRegressor) Save(filename string) error {
+	if m.booster == nil {
+		return errors.New("model has not been trained")
+	}
+	return utils.SaveModel(filename, "BoostRegressor", treeVersion, m.booster)
+}
+
+// Load a trained gradient boosting regressor from a file
+func (m *BoostRegressor) Load(filename string) error {
+	var b Booster
+	if err := utils.LoadModel(filename, "BoostRegressor", treeVersion, &b); err != nil {
+		return err
+	}
+	m.booster = &b
+	return nil
+}
+
+// Saved data for the AdaBoost classifier
+type adaData struct {
+	Model   *AdaBoost
+	Classes []float64
+}
+
+// Save the trained AdaBoost classifier to a file
+func (m *AdaClassifier) Save(filename string) error {
+	if m.model == nil {
+		return errors.New("model has not been trained")
+	}
+	return utils.SaveModel(filename, "AdaClassifier", treeVersion, adaData{Model: m.model, Classes: m.classes})
+}
+
+// Load a trained AdaBoost classifier from a file
+func (m *AdaClassifier) Load(filename string) error {
+	var d adaData
+	if err := utils.LoadModel(filename, "AdaClassifier", treeVersion, &d); err != nil {
+		return err
+	}
+	m.model, m.classes = d.Model, d.Classes
+	return nil
+}
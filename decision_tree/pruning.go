@@ -0,0 +1,207 @@
+// Pruning of decision trees after training. Rather than guessing MaxDepth
+// and MinLeaf, a tree can be grown large, and then splits that do not help
+// enough are cut back to leaves. Every node of a trained tree keeps the
+// number of rows it had, their impurity, and its prediction, so any split
+// can be replaced by a leaf. Two ways to choose which:
+//
+// Minimal cost-complexity pruning (Breiman et al., 1984), as ccp_alpha in
+// scikit-learn, chooses the subtree that minimizes
+//
+//	total impurity of the leaves + alpha * number of leaves
+//
+// where the impurity of each leaf is weighted by its share of the rows.
+// The higher alpha, the smaller the tree. As alpha goes up, the tree loses
+// its "weakest links" in turn: the splits with the smallest increase in
+// impurity per leaf removed. CostComplexityPath gives the values of alpha
+// at which this happens, which can then be tried on held out data, or set
+// as CCPAlpha in TreeParams.
+//
+// Reduced-error pruning (Quinlan, 1987) uses held out data directly:
+// going up from the bottom, each split is replaced by a leaf if the leaf
+// makes no more errors on the held out rows than the subtree does.
+//
+// Sample usage:
+//
+//	tree := DecisionTreeWith(train, "Survived", TreeParams{MaxDepth: 20, MinLeaf: 1})
+//	alphas, _ := CostComplexityPath(tree)
+//	pruned := Prune(tree, alphas[len(alphas)/2])
+//	pruned2 := PruneReducedError(tree, valid, "Survived")
+
+package decision_tree
+
+import (
+	"math"
+	"mlcode/utils"
+)
+
+// Values of alpha at which cost-complexity pruning makes the tree smaller,
+// starting with 0 for the whole tree less any splits that do not reduce
+// the impurity, and the total impurity of the leaves of the pruned tree
+// for each, ending with just the root, as cost_complexity_pruning_path in
+// scikit-learn. Prune(tree, alphas[i]) gives the i'th tree.
+func CostComplexityPath(tree *Node) (alphas, impurities []float64) {
+	t := copyTree(tree)
+	total := nodeTotal(t)
+	risk, _ := collapse(t, total, 0)
+	alphas, impurities = []float64{0}, []float64{risk}
+	for !t.IsLeaf() {
+		_, _, alpha := weakestLink(t, total)
+		risk, _ = collapse(t, total, alpha)
+		alphas = append(alphas, alpha)
+		impurities = append(impurities, risk)
+	}
+	return alphas, impurities
+}
+
+// Prune a tree by minimal cost-complexity pruning with the given alpha,
+// returns a new tree. Panics if the tree has no node totals, e.g., trees
+// from gradient boosting.
+func Prune(tree *Node, alpha float64) *Node {
+	t := copyTree(tree)
+	total := nodeTotal(t)
+	for !t.IsLeaf() {
+		_, _, weakest := weakestLink(t, total)
+		if weakest > alpha {
+			break
+		}
+		collapse(t, total, weakest)
+	}
+	return t
+}
+
+// Total weight of the training rows of a tree, panics if not known
+func nodeTotal(tree *Node) float64 {
+	if tree.N <= 0 {
+		panic("Prune: tree has no node totals, so cannot be pruned")
+	}
+	return tree.N
+}
+
+// Impurity of a node if it were a leaf, weighted by its share of the rows
+func nodeRisk(n *Node, total float64) float64 {
+	return n.Impurity * n.N / total
+}
+
+// Increase in the total impurity of the leaves per leaf removed, if a
+// subtree with the given impurity and number of leaves were made a leaf
+func linkStrength(n *Node, total, risk float64, leaves int) float64 {
+	return math.Max(0, (nodeRisk(n, total)-risk)/float64(leaves-1))
+}
+
+// Total impurity and number of the leaves of a subtree, and the strength
+// of its weakest link, +Inf if it has no splits
+func weakestLink(n *Node, total float64) (risk float64, leaves int, weakest float64) {
+	if n.IsLeaf() {
+		return nodeRisk(n, total), 1, math.Inf(1)
+	}
+	rl, ll, wl := weakestLink(n.Left, total)
+	rr, lr, wr := weakestLink(n.Right, total)
+	risk, leaves = rl+rr, ll+lr
+	return risk, leaves, math.Min(linkStrength(n, total, risk, leaves), math.Min(wl, wr))
+}
+
+// Make a leaf of every split in a subtree whose link strength is no more
+// than alpha, going up from the bottom, returns the total impurity and
+// number of the leaves left
+func collapse(n *Node, total, alpha float64) (risk float64, leaves int) {
+	if n.IsLeaf() {
+		return nodeRisk(n, total), 1
+	}
+	rl, ll := collapse(n.Left, total, alpha)
+	rr, lr := collapse(n.Right, total, alpha)
+	risk, leaves = rl+rr, ll+lr
+	if linkStrength(n, total, risk, leaves) <= alpha {
+		makeLeaf(n)
+		return nodeRisk(n, total), 1
+	}
+	return risk, leaves
+}
+
+// Remove the split of a node, which then predicts its own Value or Mean
+func makeLeaf(n *Node) {
+	n.SplitVar, n.SplitNum, n.SplitCat, n.G = "", 0, "", 0
+	n.Left, n.Right = nil, nil
+}
+
+// Number of leaves of a tree
+func countLeaves(tree *Node) int {
+	if tree.IsLeaf() {
+		return 1
+	}
+	return countLeaves(tree.Left) + countLeaves(tree.Right)
+}
+
+// Copy of a tree, so that pruning leaves the original as it was
+func copyTree(tree *Node) *Node {
+	n := *tree
+	if !tree.IsLeaf() {
+		n.Left, n.Right = copyTree(tree.Left), copyTree(tree.Right)
+	}
+	return &n
+}
+
+// Prune a tree by reduced-error pruning, using held out rows of a
+// dataframe with the same columns as the training data, returns a new
+// tree. Errors are wrong labels for classification trees (string target)
+// and squared errors for regression trees. Splits that no held out rows
+// reach are pruned too.
+func PruneReducedError(tree *Node, valid *utils.DataFrame, depv string) *Node {
+	col := valid.GetColumn(depv)
+	if col == nil {
+		panic("PruneReducedError: no column " + depv)
+	}
+	var errOf func(n *Node, i int) float64
+	switch col.Dtype {
+	case "string":
+		errOf = func(n *Node, i int) float64 {
+			return utils.IfThenElse(n.Value != col.Strings[i], 1.0, 0.0)
+		}
+	case "float64", "int64":
+		y := columnFloats(col)
+		errOf = func(n *Node, i int) float64 {
+			return (n.Mean - y[i]) * (n.Mean - y[i])
+		}
+	default:
+		panic("PruneReducedError: cannot use target of type " + col.Dtype)
+	}
+	rows := make([]int, valid.NRows())
+	for i := range rows {
+		rows[i] = i
+	}
+	pruned, _ := reducedError(tree, columnMap(valid), rows, errOf)
+	return pruned
+}
+
+// Reduced-error pruning of a subtree, for the held out rows that reach
+// it, returns the pruned copy and its total error on those rows
+func reducedError(n *Node, cols map[string]*utils.Series, rows []int,
+	errOf func(n *Node, i int) float64) (*Node, float64) {
+
+	// Error if this node were a leaf
+	var leafErr float64
+	for _, i := range rows {
+		leafErr += errOf(n, i)
+	}
+	res := *n
+	if n.IsLeaf() {
+		return &res, leafErr
+	}
+
+	// Prune both sides first, then see if the leaf is as good
+	var left, right []int
+	for _, i := range rows {
+		if l, _ := goesLeft(n, cols[n.SplitVar], i); l {
+			left = append(left, i)
+		} else {
+			right = append(right, i)
+		}
+	}
+	var errLeft, errRight float64
+	res.Left, errLeft = reducedError(n.Left, cols, left, errOf)
+	res.Right, errRight = reducedError(n.Right, cols, right, errOf)
+	if leafErr <= errLeft+errRight {
+		makeLeaf(&res)
+		return &res, leafErr
+	}
+	return &res, errLeft + errRight
+}
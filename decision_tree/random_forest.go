@@ -10,6 +10,20 @@ import (
 // A random forest is just a list of trained decision trees
 type Forest []Node
 
+// Settings for a random forest, zero values use the defaults
+type ForestParams struct {
+	NTrees int        // number of trees, default 100
+	Tree   TreeParams // for each tree, e.g., MaxFeatures, zero values use defaults
+
+	// Extremely randomized trees (Geurts et al., 2006), as ExtraTreesClassifier
+	// in scikit-learn: one random split value for each column (sets
+	// Tree.RandomThresholds), and each tree uses all the rows rather than
+	// a sample
+	ExtraTrees bool
+
+	Seed int64 // for the samples of rows, and the random choices in each tree
+}
+
 // Create/train a random forest, single threaded
 func RandomForest(df *utils.DataFrame, depv string, nTrees int) *Forest {
 	trees := Forest{}
@@ -23,51 +37,103 @@ func RandomForest(df *utils.DataFrame, depv string, nTrees int) *Forest {
 
 // Create/train a random forest, with concurrency
 func RandomForest2(df *utils.DataFrame, depv string, nTrees int) *Forest {
+	return RandomForestWith(df, depv, nTrees, DefaultParams())
+}
 
-	// Initialize an empty forest
-	trees := Forest{}
+// Create/train a random forest with concurrency, using the given
+// parameters for each tree, with a different random seed on each run
+func RandomForestWith(df *utils.DataFrame, depv string, nTrees int, p TreeParams) *Forest {
+	return TrainForest(df, depv, ForestParams{NTrees: nTrees, Tree: p, Seed: rand.Int63()})
+}
 
-	// Create channel
-	ch := make(chan *Node)
+// Create/train a random forest of classification trees with concurrency,
+// using the given settings, the same forest for the same seed. Panics if
+// the tree parameters are invalid.
+func TrainForest(df *utils.DataFrame, depv string, fp ForestParams) *Forest {
+	fp.Tree = fp.Tree.withDefaults(false)
+	return randomForest(df, depv, fp, DecisionTreeWith)
+}
+
+// Create/train a random forest with concurrency, using the given function
+// to build each tree (classification or regression)
+func randomForest(df *utils.DataFrame, depv string, fp ForestParams,
+	build func(df *utils.DataFrame, depv string, p TreeParams) *Node) *Forest {
+	fp.NTrees = utils.IfThenElse(fp.NTrees <= 0, 100, fp.NTrees)
+	fp.Tree.RandomThresholds = fp.Tree.RandomThresholds || fp.ExtraTrees
+
+	// Create channel, and a seed for each tree, so the result does not
+	// depend on the order the trees finish in
+	ch := make(chan indexedTree)
+	rng := rand.New(rand.NewSource(fp.Seed))
 
 	// Launch all the trees in background
-	for i := 0; i < nTrees; i++ {
-		go createTree(df, depv, ch)
+	for i := 0; i < fp.NTrees; i++ {
+		p := fp.Tree
+		p.Seed = rng.Int63()
+		go createTree(i, df, depv, p, !fp.ExtraTrees, build, ch)
 	}
 
 	// Collect all the trees into a list
-	for i := 0; i < nTrees; i++ {
+	trees := make(Forest, fp.NTrees)
+	for i := 0; i < fp.NTrees; i++ {
 		t := <-ch
-		trees = append(trees, *t)
+		trees[t.i] = *t.tree
 	}
 
 	// Return list of trees
 	return &trees
 }
 
-// Create a tree, for concurrent random forest creation
-func createTree(df *utils.DataFrame, depv string, ch chan *Node) {
-	sample := SampleWithReplacement(df)
-	tree := DecisionTree(sample, depv, 0)
-	ch <- tree
+// Tree number i of a forest
+type indexedTree struct {
+	i    int
+	tree *Node
+}
+
+// Create a tree, for concurrent random forest creation, from a sample of
+// the rows with replacement if bootstrap, otherwise all of them
+func createTree(i int, df *utils.DataFrame, depv string, p TreeParams, bootstrap bool,
+	build func(df *utils.DataFrame, depv string, p TreeParams) *Node, ch chan indexedTree) {
+	sample := df
+	if bootstrap {
+		sample = sampleRows(df, rand.New(rand.NewSource(p.Seed)).Intn)
+	}
+	tree := build(sample, depv, p)
+	ch <- indexedTree{i, tree}
 }
 
-// Predict with a random forest
+// Predict with a random forest, the label with the highest probability,
+// see RandomForestPredictProba
 func RandomForestPredict(forest *Forest, row *utils.DataFrame) string {
+	return mostLikely(RandomForestPredictProba(forest, row))
+}
 
-	// Make a prediction with each tree
-	preds := []string{}
-	for _, tree := range *forest {
-		pred1 := Predict(&tree, row)
-		preds = append(preds, pred1)
+// Predict the probability of each label with a random forest, as the
+// average of the probabilities from each tree (soft voting, as in
+// scikit-learn), rather than the share of the trees voting for the label
+func RandomForestPredictProba(forest *Forest, row *utils.DataFrame) map[string]float64 {
+	res := map[string]float64{}
+	cols := columnMap(row)
+	for i := range *forest {
+		leaf := leafAt(&(*forest)[i], cols, 0)
+		if leaf == nil {
+			return nil
+		}
+		for label, p := range leafProba(leaf) {
+			res[label] += p / float64(len(*forest))
+		}
 	}
-
-	// Use the most common prediction as the prediction for the model
-	return utils.MostCommon(preds)
+	return res
 }
 
 // Sample a dataframe with replacement, resulting in same number of rows
 func SampleWithReplacement(df *utils.DataFrame) *utils.DataFrame {
+	return sampleRows(df, rand.Intn)
+}
+
+// Sample a dataframe with replacement, using the given function for
+// random row numbers
+func sampleRows(df *utils.DataFrame, intn func(n int) int) *utils.DataFrame {
 
 	// Start with an empty dataframe, same structure
 	df2 := df.CopyStructure()
@@ -75,7 +141,7 @@ func SampleWithReplacement(df *utils.DataFrame) *utils.DataFrame {
 	// Keep sampling random rows until the new dataframe is same size
 	nrows := df.NRows()
 	for n := 0; n < nrows; n++ {
-		i := rand.Intn(nrows)
+		i := intn(nrows)
 		df2.CopyRow(df, i)
 	}
 
@@ -0,0 +1,45 @@
+// Demo of regression trees and random forest regression
+
+package decision_tree
+
+import (
+	"fmt"
+	"mlcode/metrics"
+	"mlcode/utils"
+)
+
+// Predict the number of pizzas with a regression tree, and the fare paid
+// by Titanic passengers with a random forest of regression trees
+func RegressionTreeDemo() {
+
+	// Read the pizza data set, all columns are integers
+	df, err := utils.ReadCSV("data/pizza_3_vars.txt")
+	if err != nil {
+		panic(err)
+	}
+
+	// Create a regression tree and show it
+	tree := RegressionTree(df, "Pizzas", TreeParams{MaxDepth: 3, MinLeaf: 5})
+	PrintTree(tree, 0)
+
+	// Make predictions and report the error
+	actual := columnFloats(df.GetColumn("Pizzas"))
+	preds := []float64{}
+	for i := 0; i < df.NRows(); i++ {
+		preds = append(preds, PredictValue(tree, df.GetRow(i)))
+	}
+	fmt.Printf("Pizzas: RMSE = %.3f, R2 = %.3f\n", metrics.RMSE(actual, preds), metrics.R2(actual, preds))
+
+	// Predict Titanic fares from the other columns, holding out 20% of
+	// the rows for testing
+	df = GetTitanicData("data/titanic.csv")
+	train, test := utils.TrainTestSplit(df, .2, "", 42)
+	fmt.Println("\nTraining random forest to predict Fare")
+	forest := RandomForestRegressor(train, "Fare", 200, TreeParams{MaxDepth: 5, MinLeaf: 5})
+	actual = columnFloats(test.GetColumn("Fare"))
+	preds = []float64{}
+	for i := 0; i < test.NRows(); i++ {
+		preds = append(preds, RandomForestPredictValue(forest, test.GetRow(i)))
+	}
+	fmt.Printf("Fare: RMSE = %.3f, R2 = %.3f\n", metrics.RMSE(actual, preds), metrics.R2(actual, preds))
+}
@@ -0,0 +1,135 @@
+// Regression trees, which predict a number rather than a label, like
+// DecisionTreeRegressor in scikit-learn. By default splits are chosen to
+// reduce the variance of the target (i.e., the mean squared error of
+// predicting the mean), and each leaf predicts the mean of its rows, or
+// the median with the MAE criterion. The target column
+// must be int64 or float64.
+//
+// Sample usage:
+//
+//	df, _ := utils.ReadCSV("data/pizza_3_vars.txt")
+//	tree := RegressionTree(df, "Pizzas", TreeParams{MaxDepth: 3, MinLeaf: 5})
+//	PrintTree(tree, 0)
+//	pred := PredictValue(tree, df.GetRow(0))
+
+package decision_tree
+
+import (
+	"math"
+	"math/rand"
+	"mlcode/utils"
+)
+
+// Create a regression tree to predict a numeric column, using the given
+// parameters, returns top-level node. Panics if the column is not numeric
+// or has missing values, or the weights or criterion are invalid.
+func RegressionTree(df *utils.DataFrame, depv string, p TreeParams) *Node {
+	col := df.GetColumn(depv)
+	if col == nil || (col.Dtype != "float64" && col.Dtype != "int64") {
+		panic("RegressionTree: target " + depv + " must be a numeric column")
+	}
+	for i := 0; i < df.NRows(); i++ {
+		if col.IsMissing(i) {
+			panic("RegressionTree: target " + depv + " has missing values")
+		}
+	}
+	p = p.withDefaults(true)
+	df, p = applyWeights(df, depv, p)
+	return buildTree(df, 0, &p, newRegression(df, depv, &p))
+}
+
+// Regression tree, predicting the mean (or median) of the target
+type regression struct {
+	y       []float64   // target value of each row
+	weights []float64   // weight of each row, nil for none
+	depv    string      // column with the numeric target
+	p       *TreeParams // for the weights and criterion
+}
+
+// Set up a regression tree
+func newRegression(df *utils.DataFrame, depv string, p *TreeParams) regression {
+	return regression{y: columnFloats(df.GetColumn(depv)), weights: weightsOf(df, p), depv: depv, p: p}
+}
+
+func (r regression) values() []float64 {
+	return r.y
+}
+
+func (r regression) leaf(rows []int) *Node {
+	y, w := gather(r.y, rows), gather(r.weights, rows)
+	if _, ok := r.p.Criterion.(MAE); ok {
+		return &Node{Mean: median(y, w)}
+	}
+	return &Node{Mean: mean(y, w)}
+}
+
+func (r regression) skip(col string) bool {
+	return col == r.depv || col == r.p.WeightCol
+}
+
+// Predict a number from a regression tree, NaN if the row has a column
+// type that cannot be used
+func PredictValue(tree *Node, row *utils.DataFrame) float64 {
+	leaf := findLeaf(tree, row)
+	if leaf == nil {
+		return math.NaN()
+	}
+	return leaf.Mean
+}
+
+// Create/train a random forest of regression trees with concurrency, each
+// tree trained on a sample of the rows with replacement, with a different
+// random seed on each run
+func RandomForestRegressor(df *utils.DataFrame, depv string, nTrees int, p TreeParams) *Forest {
+	return TrainForestRegressor(df, depv, ForestParams{NTrees: nTrees, Tree: p, Seed: rand.Int63()})
+}
+
+// Create/train a random forest of regression trees with concurrency, using
+// the given settings, the same forest for the same seed. Panics if the
+// tree parameters are invalid.
+func TrainForestRegressor(df *utils.DataFrame, depv string, fp ForestParams) *Forest {
+	fp.Tree = fp.Tree.withDefaults(true)
+	return randomForest(df, depv, fp, RegressionTree)
+}
+
+// Predict a number with a random forest of regression trees, the average
+// of the predictions of all the trees
+func RandomForestPredictValue(forest *Forest, row *utils.DataFrame) float64 {
+	var tot float64
+	for i := range *forest {
+		tot += PredictValue(&(*forest)[i], row)
+	}
+	return tot / float64(len(*forest))
+}
+
+// Weighted mean of a list of numbers, weights may be nil
+func mean(y, weights []float64) float64 {
+	var tot float64
+	for i, v := range y {
+		tot += utils.Weight(weights, i) * v
+	}
+	n := sumWeights(len(y), weights)
+	if n == 0 {
+		return 0
+	}
+	return tot / n
+}
+
+// Weighted variance of a list of numbers, i.e., the mean squared
+// difference from the mean. Exactly zero if all the numbers are the same,
+// so the tree stops splitting.
+func variance(y, weights []float64) float64 {
+	if utils.AllSame(y) {
+		return 0
+	}
+	m := mean(y, weights)
+	var tot float64
+	for i, v := range y {
+		tot += utils.Weight(weights, i) * (v - m) * (v - m)
+	}
+	n := sumWeights(len(y), weights)
+	if n == 0 {
+		return 0
+	}
+	return tot / n
+}
@@ -4,6 +4,7 @@ package decision_tree
 
 import (
 	"fmt"
+	"mlcode/metrics"
 	"mlcode/utils"
 )
 
@@ -19,47 +20,73 @@ func RandomForestDemo() {
 	MaxDepth = 5
 	MinLeaf = 1
 
-	// Train lots of trees, sampling data with replacement
+	// Hold out 20% of the rows for testing, same survival rate in both
+	train, test := utils.TrainTestSplit(df, .2, "Survived", 42)
+
+	// Train lots of trees on the training rows, sampling data with
+	// replacement
 	nTrees := 2000
 	fmt.Println("Training", nTrees, "decision trees")
-	forest := RandomForest2(df, "Survived", nTrees)
+	forest := RandomForest2(train, "Survived", nTrees)
 
-	// Make predictions, by predicting for each tree, then using most common value
+	// Make predictions on the test set, by averaging the probabilities
+	// from each tree, then using the most likely value
 	fmt.Println("Making predictions")
-	correct := 0
-	for i := 0; i < df.NRows(); i++ {
-
-		// Get this row in the original data set
-		row := df.GetRow(i)
-
-		// Predict using random forest
-		pred := RandomForestPredict(forest, row)
+	preds := []string{}
+	scores := []float64{}
+	for i := 0; i < test.NRows(); i++ {
+		probs := RandomForestPredictProba(forest, test.GetRow(i))
+		preds = append(preds, mostLikely(probs))
+		scores = append(scores, probs["Yes"])
+	}
 
-		// Compare to actuals
-		actual := row.GetColumn("Survived").Strings[0]
-		if pred == actual {
-			correct++
+	// Report accuracy, and how well the probabilities rank the rows
+	actual := test.GetColumn("Survived").Strings
+	metrics.Confusion(actual, preds).Print()
+	fmt.Println("Accuracy =", metrics.Accuracy(actual, preds))
+	fmt.Println("ROC AUC =", metrics.ROCAUC(actual, scores, "Yes"))
+
+	// Which columns matter: impurity decrease in the training rows, and
+	// the drop in accuracy on the test rows when a column is shuffled
+	fmt.Println("\nMean decrease in impurity:")
+	ForestImportance(forest).Print()
+	fmt.Println("\nPermutation importance (accuracy):")
+	accuracy := func(df *utils.DataFrame) float64 {
+		preds := []string{}
+		for i := 0; i < df.NRows(); i++ {
+			preds = append(preds, RandomForestPredict(forest, df.GetRow(i)))
 		}
+		return metrics.Accuracy(df.GetColumn("Survived").Strings, preds)
+	}
+	metrics.PermutationImportance(test, "Survived", accuracy, 5, 42).Print()
+
+	// Forests with random columns for each split, and extremely randomized
+	// trees, which are less alike than trees that only differ in their rows
+	tp := TreeParams{MaxDepth: 8, MinLeaf: 2, MaxFeatures: MaxFeatures{Rule: FeaturesSqrt}}
+	for _, extra := range []bool{false, true} {
+		forest := TrainForest(train, "Survived", ForestParams{NTrees: 500, Tree: tp, ExtraTrees: extra, Seed: 42})
+		preds, scores := []string{}, []float64{}
+		for i := 0; i < test.NRows(); i++ {
+			probs := RandomForestPredictProba(forest, test.GetRow(i))
+			preds = append(preds, mostLikely(probs))
+			scores = append(scores, probs["Yes"])
+		}
+		fmt.Printf("\nMaxFeatures sqrt, extra trees %v: accuracy = %.4f, ROC AUC = %.4f\n",
+			extra, metrics.Accuracy(actual, preds), metrics.ROCAUC(actual, scores, "Yes"))
 	}
-
-	// Report accuracy
-	acc := float64(correct) / float64(df.NRows()) * 100
-	fmt.Println(correct, "of", df.NRows(), "correct =", acc, "%")
 }
 
 // Read and prepare the Titanic data set
 func GetTitanicData(filename string) *utils.DataFrame {
 
-	// Read Titanic data set from CSV file
-	utils.MISSING_INT = -1
-	utils.MISSING_FLOAT = -1.0
+	// Read Titanic data set from CSV file. Missing values, e.g., of Age,
+	// are left as they are, since the trees handle them.
 	df, err := utils.ReadCSV(filename)
 	if err != nil {
 		panic(err)
 	}
 
 	// Remove some columns we don't need for the model
-	// TODO: Drop rows with missing values
 	df = df.DropColumns([]string{"PassengerId", "Name", "Ticket"})
 
 	// Turn "Survived" column into a string
@@ -0,0 +1,269 @@
+// Check the sweep-line split search against the original implementation,
+// which copies the rows of every possible split into two new dataframes,
+// and compare their speed:
+//
+//	go test ./decision_tree -bench Tree -run XXX
+
+package decision_tree
+
+import (
+	"fmt"
+	"math"
+	"mlcode/utils"
+	"testing"
+)
+
+// The original tree building, kept here to check the new one
+type oldTree struct {
+	depv    string
+	p       *TreeParams
+	classes map[string]float64 // class numbers, nil for regression
+}
+
+func newOldTree(df *utils.DataFrame, depv string, p *TreeParams) oldTree {
+	o := oldTree{depv: depv, p: p}
+	if !p.Criterion.Regression() {
+		o.classes = map[string]float64{}
+		for i, l := range utils.Unique(df.GetColumn(depv).Strings) {
+			o.classes[l] = float64(i)
+		}
+	}
+	return o
+}
+
+func (o oldTree) values(df *utils.DataFrame) []float64 {
+	if o.classes == nil {
+		return columnFloats(df.GetColumn(o.depv))
+	}
+	labels := df.GetColumn(o.depv).Strings
+	res := make([]float64, len(labels))
+	for i, l := range labels {
+		res[i] = o.classes[l]
+	}
+	return res
+}
+
+func (o oldTree) leaf(df *utils.DataFrame) *Node {
+	w := weightsOf(df, o.p)
+	if o.classes != nil {
+		return &Node{Value: mostCommon(df.GetColumn(o.depv).Strings, w)}
+	}
+	if _, ok := o.p.Criterion.(MAE); ok {
+		return &Node{Mean: median(o.values(df), w)}
+	}
+	return &Node{Mean: mean(o.values(df), w)}
+}
+
+func (o oldTree) build(df *utils.DataFrame, level int) *Node {
+	p := o.p
+	if df.NRows() < p.MinLeaf || level >= p.MaxDepth ||
+		p.Criterion.Impurity(o.values(df), weightsOf(df, p)) == 0 {
+		return o.leaf(df)
+	}
+
+	// Try every split of every column
+	best := Node{G: math.Inf(1)}
+	var bestLeft, bestRight *utils.DataFrame
+	for _, c := range *df {
+		if c.Name == o.depv || c.Name == p.WeightCol {
+			continue
+		}
+		var splits []float64
+		var cats []string
+		if c.Dtype == "float64" {
+			splits = midPoints(c.Floats)
+		} else if c.Dtype == "int64" {
+			splits = midPoints(c.Ints)
+		} else {
+			cats = utils.Unique(c.Strings)
+		}
+		try := func(left, right *utils.DataFrame, split float64, cat string) {
+			if left.NRows() == 0 || right.NRows() == 0 {
+				return
+			}
+			G := p.Criterion.Score(o.values(left), o.values(right), weightsOf(left, p), weightsOf(right, p))
+			if G < best.G {
+				best = Node{SplitVar: c.Name, SplitNum: split, SplitCat: cat, G: G}
+				bestLeft, bestRight = left, right
+			}
+		}
+		for _, split := range splits {
+			left, right := splitNumeric(*df, c.Name, split)
+			try(left, right, split, "")
+		}
+		for _, cat := range cats {
+			left, right := splitCategorical(*df, c.Name, cat)
+			try(left, right, 0, cat)
+		}
+	}
+	if bestLeft == nil {
+		return o.leaf(df)
+	}
+	best.Left = o.build(bestLeft, level+1)
+	best.Right = o.build(bestRight, level+1)
+	return &best
+}
+
+// Split a dataframe on a numeric column, into two dataframes, at the given
+// split value
+func splitNumeric(df utils.DataFrame, colName string, split float64) (*utils.DataFrame, *utils.DataFrame) {
+	left := df.CopyStructure()
+	right := df.CopyStructure()
+	splitCol := df.GetColumn(colName)
+	for i := 0; i < df.NRows(); i++ {
+		var splitLeft bool
+		if splitCol.Dtype == "float64" {
+			splitLeft = splitCol.Floats[i] < split
+		} else {
+			splitLeft = float64(splitCol.Ints[i]) < split
+		}
+		if splitLeft {
+			left.CopyRow(&df, i)
+		} else {
+			right.CopyRow(&df, i)
+		}
+	}
+	return left, right
+}
+
+// Split a dataframe on a categorical column, into two dataframes, left for
+// equal, right for not equal
+func splitCategorical(df utils.DataFrame, colName string, split string) (*utils.DataFrame, *utils.DataFrame) {
+	left := df.CopyStructure()
+	right := df.CopyStructure()
+	splitCol := df.GetColumn(colName)
+	for i := 0; i < df.NRows(); i++ {
+		if splitCol.Strings[i] == split {
+			left.CopyRow(&df, i)
+		} else {
+			right.CopyRow(&df, i)
+		}
+	}
+	return left, right
+}
+
+// Midpoints between each consecutive pair of unique values
+func midPoints[T float64 | int64](nums []T) []float64 {
+	nums = utils.Unique(nums)
+	res := []float64{}
+	for i := 1; i < len(nums); i++ {
+		mid := float64(nums[i-1]) + float64(nums[i]-nums[i-1])/2.0
+		res = append(res, mid)
+	}
+	return res
+}
+
+// Breast cancer data, without the id column
+func getBreastCancerData() *utils.DataFrame {
+	df, err := utils.ReadCSV("../data/breastcancer.csv")
+	if err != nil {
+		panic(err)
+	}
+	return df.DropColumns([]string{"id"})
+}
+
+// Copy of a dataframe with missing values filled in, with -1 or "none",
+// since the old way has no missing values
+func fillMissing(df *utils.DataFrame) *utils.DataFrame {
+	res := df.CopyStructure()
+	for i := 0; i < df.NRows(); i++ {
+		res.CopyRow(df, i)
+	}
+	for c := range *res {
+		col := &(*res)[c]
+		for i := 0; i < res.NRows(); i++ {
+			if col.IsMissing(i) {
+				switch col.Dtype {
+				case "int64":
+					col.Ints[i] = -1
+				case "float64":
+					col.Floats[i] = -1
+				case "string":
+					col.Strings[i] = "none"
+				}
+			}
+		}
+	}
+	return res
+}
+
+// Whether two trees have the same splits and leaves, allowing for rounding
+// in the scores
+func sameTree(a, b *Node) bool {
+	if a.IsLeaf() || b.IsLeaf() {
+		return a.IsLeaf() && b.IsLeaf() && a.Value == b.Value && math.Abs(a.Mean-b.Mean) < 1e-9
+	}
+	return a.SplitVar == b.SplitVar && a.SplitNum == b.SplitNum && a.SplitCat == b.SplitCat &&
+		math.Abs(a.G-b.G) < 1e-9 && sameTree(a.Left, b.Left) && sameTree(a.Right, b.Right)
+}
+
+// The new split search should find exactly the same trees as the old one
+func TestSplitEquivalence(t *testing.T) {
+	titanic := fillMissing(GetTitanicData("../data/titanic.csv"))
+
+	// The old way is slow on this one, so just use some of the rows
+	all := getBreastCancerData()
+	cancer := all.CopyStructure()
+	for i := 0; i < 150; i++ {
+		cancer.CopyRow(all, i)
+	}
+	iris, _ := utils.ReadCSV("../data/iris.csv")
+	pizza, _ := utils.ReadCSV("../data/pizza_3_vars.txt")
+	tests := []struct {
+		df         *utils.DataFrame
+		depv       string
+		p          TreeParams
+		regression bool
+	}{
+		{titanic, "Survived", TreeParams{MaxDepth: 5, MinLeaf: 5}, false},
+		{titanic, "Survived", TreeParams{MaxDepth: 4, MinLeaf: 10, Criterion: GainRatio{}}, false},
+		{titanic, "Survived", TreeParams{MaxDepth: 3, MinLeaf: 5, ClassWeight: "balanced"}, false},
+		{cancer, "diagnosis", TreeParams{MaxDepth: 5, MinLeaf: 2, Criterion: Entropy{}}, false},
+		{iris, "variety", TreeParams{MaxDepth: 6, MinLeaf: 1}, false},
+		{titanic, "Fare", TreeParams{MaxDepth: 4, MinLeaf: 5}, true},
+		{titanic, "Age", TreeParams{MaxDepth: 3, MinLeaf: 20, Criterion: MAE{}}, true},
+		{pizza, "Pizzas", TreeParams{MaxDepth: 3, MinLeaf: 1}, true},
+	}
+	for _, test := range tests {
+		name := fmt.Sprintf("%s %T", test.depv, test.p.Criterion)
+		p := test.p.withDefaults(test.regression)
+		df, p := applyWeights(test.df, test.depv, p)
+		old := newOldTree(df, test.depv, &p).build(df, 0)
+		var tree *Node
+		if test.regression {
+			tree = RegressionTree(test.df, test.depv, test.p)
+		} else {
+			tree = DecisionTreeWith(test.df, test.depv, test.p)
+		}
+		if !sameTree(old, tree) {
+			t.Errorf("%s: trees differ", name)
+			PrintTree(old, 0)
+			PrintTree(tree, 0)
+		}
+	}
+}
+
+// Time building a tree the new way and the old way
+func BenchmarkTree(b *testing.B) {
+	data := []struct {
+		name, depv string
+		df         *utils.DataFrame
+	}{
+		{"titanic", "Survived", GetTitanicData("../data/titanic.csv")},
+		{"breastcancer", "diagnosis", getBreastCancerData()},
+	}
+	for _, d := range data {
+		p := TreeParams{MaxDepth: 5, MinLeaf: 5}
+		b.Run(d.name+"/sweep", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				DecisionTreeWith(d.df, d.depv, p)
+			}
+		})
+		b.Run(d.name+"/copy", func(b *testing.B) {
+			p := p.withDefaults(false)
+			for i := 0; i < b.N; i++ {
+				newOldTree(d.df, d.depv, &p).build(d.df, 0)
+			}
+		})
+	}
+}
@@ -0,0 +1,564 @@
+// Finding the best split of each node of a tree, without copying any data.
+// The rows of the training data are numbered, and each numeric column is
+// sorted once, at the start, into a list of row numbers in order of value.
+// The rows of a node are then a range of each of these lists, so to score
+// all the possible splits of a column, a sweep goes through the rows in
+// order, moving one row at a time from the right side to the left side
+// and updating running totals, e.g., the weight of each class. Scoring each
+// split then takes time proportional to the number of classes rather than
+// the number of rows. Only the best split is applied, by partitioning the
+// lists of row numbers in place, so they stay sorted. This is the same
+// approach as scikit-learn's BestSplitter, and is many times faster than
+// copying the rows of each possible split into new dataframes, see
+// BenchmarkTree in split_test.go.
+//
+// For random forests, MaxFeatures makes each split consider only some of
+// the columns, chosen at random, and RandomThresholds (extremely randomized
+// trees) tries just one random split value for each column, rather than
+// sweeping through all of them.
+//
+// Missing values (see utils.Series.IsMissing) are left out of the sweep, and
+// each split is scored twice, with the rows missing a value on the left and
+// then on the right, as in XGBoost. The better way becomes the default
+// direction of the split (Node.MissingLeft), which is also used for rows
+// with missing values when predicting.
+
+package decision_tree
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"mlcode/utils"
+	"sort"
+)
+
+// Running totals of the target values of a set of rows, so the criteria
+// can work out the impurity without going through the rows. For
+// regression, these are the mean and the sum of squared differences from
+// the mean, updated one row at a time as in Welford's algorithm, which is
+// exactly zero if all the values are the same.
+type totals struct {
+	n       float64   // total weight, or number of rows
+	classes []float64 // total weight of each class number, for classification
+	mean    float64   // weighted mean, for regression
+	m2      float64   // weighted sum of squared differences from the mean
+}
+
+// Add a row with target value y and weight w. For classification only, a
+// negative weight removes the row.
+func (t *totals) add(y, w float64) {
+	t.n += w
+	if t.classes != nil {
+		t.classes[int(y)] += w
+	} else if w != 0 {
+		delta := y - t.mean
+		t.mean += delta * (w / t.n)
+		t.m2 += w * delta * (y - t.mean)
+	}
+}
+
+// Add the totals of another set of rows
+func (t *totals) merge(o *totals) {
+	n := t.n + o.n
+	for i, v := range o.classes {
+		t.classes[i] += v
+	}
+	if t.classes == nil && n != 0 {
+		delta := o.mean - t.mean
+		t.mean += delta * (o.n / n)
+		t.m2 += o.m2 + delta*delta*t.n*o.n/n
+	}
+	t.n = n
+}
+
+// Copy of the totals
+func (t *totals) clone() *totals {
+	res := *t
+	if t.classes != nil {
+		res.classes = append([]float64{}, t.classes...)
+	}
+	return &res
+}
+
+// Column that can be split on, numbered from 0 by row
+type feature struct {
+	name     string
+	nums     []float64 // values of a numeric column
+	ints     []int64   // original values if an int64 column, for the midpoints
+	cats     []int     // category number of each row of a string column, -1 if missing
+	catNames []string  // the categories, sorted
+	sorted   []int     // row numbers in order of value, missing last, numeric columns only
+	missing  []bool    // whether the value of each row is missing, nil if none are
+}
+
+// Is the value of row r missing?
+func (f *feature) isMissing(r int) bool {
+	return f.missing != nil && f.missing[r]
+}
+
+// Split value halfway between the values of rows i and j, worked out the
+// same way for int64 columns as for the original values
+func (f *feature) midPoint(i, j int) float64 {
+	if f.ints != nil {
+		return float64(f.ints[i]) + float64(f.ints[j]-f.ints[i])/2.0
+	}
+	return f.nums[i] + (f.nums[j]-f.nums[i])/2.0
+}
+
+// State for building one tree
+type splitter struct {
+	p        *TreeParams
+	t        target
+	y        []float64 // target value of each row
+	w        []float64 // weight of each row, nil for none
+	nClasses int       // number of classes, 0 for regression
+	features []feature
+	rows     []int    // row numbers in original order, for the leaves
+	goLeft   []bool   // for each row, whether it goes left at the split being applied
+	buf      []int    // space for partitioning the row numbers
+	after    []totals // space for the totals of the rows after each row
+
+	// Random choices of columns and split values, see TreeParams
+	maxFeatures int        // number of columns that can be split to try for each split
+	rng         *rand.Rand // nil if no random choices
+}
+
+// Best split found for a node
+type split struct {
+	G           float64 // score, lower is better
+	feature     *feature
+	num         float64 // value if numeric split
+	cat         int     // category number if categorical split
+	missingLeft bool    // whether rows with missing values go left
+}
+
+// Create decision tree from the rows of a dataframe, recursively, returns
+// top-level node
+func buildTree(df *utils.DataFrame, level int, p *TreeParams, t target) *Node {
+	s := newSplitter(df, p, t)
+	tree := s.build(0, len(s.rows), level)
+	if p.CCPAlpha > 0 {
+		tree = Prune(tree, p.CCPAlpha)
+	}
+	return tree
+}
+
+// Number the rows and sort each numeric column
+func newSplitter(df *utils.DataFrame, p *TreeParams, t target) *splitter {
+	n := df.NRows()
+	s := splitter{p: p, t: t, y: t.values(), w: weightsOf(df, p)}
+	if !p.Criterion.Regression() && n > 0 {
+		s.nClasses = int(utils.Max(s.y)) + 1
+	}
+	s.rows = make([]int, n)
+	for i := range s.rows {
+		s.rows[i] = i
+	}
+	s.goLeft = make([]bool, n)
+	s.buf = make([]int, n)
+	s.after = make([]totals, n+1)
+
+	// Set up the columns to split on
+	for _, c := range *df {
+		if t.skip(c.Name) {
+			continue
+		}
+		f := feature{name: c.Name}
+		for i := 0; i < n; i++ {
+			if c.IsMissing(i) {
+				if f.missing == nil {
+					f.missing = make([]bool, n)
+				}
+				f.missing[i] = true
+			}
+		}
+		switch c.Dtype {
+		case "float64":
+			f.nums = c.Floats
+		case "int64":
+			f.ints = c.Ints
+			f.nums = columnFloats(&c)
+		case "string":
+			codes := map[string]int{}
+			for _, name := range utils.Unique(c.Strings) {
+				if name != "" {
+					codes[name] = len(f.catNames)
+					f.catNames = append(f.catNames, name)
+				}
+			}
+			f.cats = make([]int, n)
+			for i, v := range c.Strings {
+				f.cats[i] = utils.IfThenElse(v == "", -1, codes[v])
+			}
+		default:
+			fmt.Println("Warning: column ignored, type", c.Dtype)
+			continue
+		}
+		if f.nums != nil {
+			f.sorted = append([]int{}, s.rows...)
+			sort.SliceStable(f.sorted, func(a, b int) bool {
+				ra, rb := f.sorted[a], f.sorted[b]
+				return !f.isMissing(ra) && (f.isMissing(rb) || f.nums[ra] < f.nums[rb])
+			})
+		}
+		s.features = append(s.features, f)
+	}
+	s.maxFeatures = p.MaxFeatures.count(len(s.features))
+	if s.maxFeatures < len(s.features) || p.RandomThresholds {
+		s.rng = rand.New(rand.NewSource(p.Seed))
+	}
+	return &s
+}
+
+// Create the node for rows start to end of the lists, recursively
+func (s *splitter) build(start, end, level int) *Node {
+
+	// Every node starts as a leaf, with the totals needed for pruning.
+	// Terminate with the leaf node if:
+	// 1. too few rows left
+	// 2. tree too deep
+	// 3. no more variation
+	rows := s.rows[start:end]
+	n := s.t.leaf(rows)
+	y, w := gather(s.y, rows), gather(s.w, rows)
+	n.N, n.Impurity = sumWeights(len(rows), w), s.p.Criterion.Impurity(y, w)
+	if len(rows) < s.p.MinLeaf || level >= s.p.MaxDepth || n.Impurity == 0 {
+		return n
+	}
+
+	// Find the split with the lowest score, e.g., Gini index, if any
+	best := s.bestSplit(start, end)
+	if best.feature == nil {
+		return n
+	}
+	n.SplitVar, n.G, n.MissingLeft = best.feature.name, best.G, best.missingLeft
+	if best.feature.cats != nil {
+		n.SplitCat = best.feature.catNames[best.cat]
+	} else {
+		n.SplitNum = best.num
+	}
+
+	// Show the best split found
+	if s.p.Verbose {
+		fmt.Printf("Depth %2d: n = %d, best split on %s at ", level, len(rows), n.SplitVar)
+		if len(n.SplitCat) > 0 {
+			fmt.Printf("\"%s\"", n.SplitCat)
+		} else {
+			fmt.Print(n.SplitNum)
+		}
+		fmt.Println(utils.IfThenElse(n.MissingLeft, " or missing", ""), "=> impurity", best.G)
+	}
+
+	// Using the best split found, recursively do left and right sides
+	mid := s.partition(start, end, best)
+	n.Left = s.build(start, mid, level+1)
+	n.Right = s.build(mid, end, level+1)
+	return n
+}
+
+// Find the best split of rows start to end, by sweeping through each
+// column. Ties go to the first column tried, and the lowest value.
+func (s *splitter) bestSplit(start, end int) split {
+	best := split{G: math.Inf(1)}
+	node := s.newTotals()
+	for _, r := range s.rows[start:end] {
+		node.add(s.y[r], utils.Weight(s.w, r))
+	}
+
+	// With MaxFeatures, go through the columns in random order, until
+	// enough columns that can be split have been tried, as in scikit-learn
+	order := make([]int, len(s.features))
+	for i := range order {
+		order[i] = i
+	}
+	if s.maxFeatures < len(order) {
+		order = s.rng.Perm(len(order))
+	}
+	tried := 0
+	for _, fi := range order {
+		f := &s.features[fi]
+		var ok bool
+		switch {
+		case f.cats != nil:
+			ok = s.categoricalSplit(f, start, end, &best)
+		case s.p.RandomThresholds:
+			ok = s.randomSplit(f, start, end, &best)
+		default:
+			ok = s.numericSplit(f, start, end, node, &best)
+		}
+		if ok {
+			tried++
+			if tried == s.maxFeatures {
+				break
+			}
+		}
+	}
+	return best
+}
+
+// Best split of a string column, updating best if better. Returns whether
+// the column can be split at all, i.e., has more than one value. The left
+// side is the rows equal to each category, right side the rows of the
+// categories before and after it, so that with two categories, both ways
+// round give exactly the same score. With RandomThresholds, only one
+// category, chosen at random, is tried.
+func (s *splitter) categoricalSplit(f *feature, start, end int, best *split) bool {
+	missing, nMissing := s.missingTotals(f, start, end)
+	nc := len(f.catNames)
+	cats := make([]*totals, nc)
+	counts := make([]int, nc)
+	for c := range cats {
+		cats[c] = s.newTotals()
+	}
+	for _, r := range s.rows[start:end] {
+		if c := f.cats[r]; c >= 0 {
+			cats[c].add(s.y[r], utils.Weight(s.w, r))
+			counts[c]++
+		}
+	}
+	var valid []int
+	for c := range cats {
+		if counts[c] > 0 && counts[c] < end-start {
+			valid = append(valid, c)
+		}
+	}
+	if len(valid) == 0 {
+		return false
+	}
+	if s.p.RandomThresholds {
+		c := valid[s.rng.Intn(len(valid))]
+		valid = []int{c}
+	}
+	after := make([]*totals, nc+1)
+	after[nc] = s.newTotals()
+	for c := nc - 1; c >= 0; c-- {
+		after[c] = after[c+1].clone()
+		after[c].merge(cats[c])
+	}
+	before := s.newTotals()
+	for c, left := range cats {
+		if len(valid) > 0 && valid[0] == c {
+			valid = valid[1:]
+			right := before.clone()
+			right.merge(after[c+1])
+			G, missingLeft := s.scoreSplit(left, right, missing, counts[c] < end-start-nMissing,
+				f, func(r int) bool { return f.cats[r] == c }, start, end)
+			if G < best.G {
+				*best = split{G: G, feature: f, cat: c, missingLeft: missingLeft}
+			}
+		}
+		before.merge(left)
+	}
+	return true
+}
+
+// Best split of a numeric column, updating best if better. Returns whether
+// the column can be split at all. Sweeps through the rows in order, testing
+// splits at the midpoints between consecutive values. For regression, the
+// right side totals come from sweeping the other way first, since rows
+// cannot be taken away from them exactly. Rows with missing values are at
+// the end, and left out.
+func (s *splitter) numericSplit(f *feature, start, end int, node *totals, best *split) bool {
+	missing, nMissing := s.missingTotals(f, start, end)
+	rows := f.sorted[start : end-nMissing]
+	left, right := s.newTotals(), node.clone()
+	if missing != nil {
+		right = s.newTotals()
+		for _, r := range rows {
+			right.add(s.y[r], utils.Weight(s.w, r))
+		}
+	}
+	if s.nClasses == 0 {
+		s.after[len(rows)] = totals{}
+		for i := len(rows) - 1; i > 0; i-- {
+			s.after[i] = s.after[i+1]
+			s.after[i].add(s.y[rows[i]], utils.Weight(s.w, rows[i]))
+		}
+	}
+	ok := false
+	for i := 0; i < len(rows)-1; i++ {
+		r := rows[i]
+		left.add(s.y[r], utils.Weight(s.w, r))
+		if s.nClasses == 0 {
+			right = &s.after[i+1]
+		} else {
+			right.add(s.y[r], -utils.Weight(s.w, r))
+		}
+		a, b := f.nums[r], f.nums[rows[i+1]]
+		if a == b {
+			continue
+		}
+		ok = true
+
+		// No number strictly between a and b, so the split would be
+		// the same as the one before
+		num := f.midPoint(r, rows[i+1])
+		if num <= a {
+			continue
+		}
+		G, missingLeft := s.scoreSplit(left, right, missing, true,
+			f, func(r int) bool { return f.nums[r] < num }, start, end)
+		if G < best.G {
+			*best = split{G: G, feature: f, num: num, missingLeft: missingLeft}
+		}
+	}
+	return ok
+}
+
+// Split of a numeric column at a random value between its smallest and
+// largest values, as in extremely randomized trees (Geurts et al., 2006),
+// updating best if better. Returns whether the column can be split at all.
+func (s *splitter) randomSplit(f *feature, start, end int, best *split) bool {
+	missing, nMissing := s.missingTotals(f, start, end)
+	rows := f.sorted[start : end-nMissing]
+	if len(rows) < 2 {
+		return false
+	}
+	lo, hi := f.nums[rows[0]], f.nums[rows[len(rows)-1]]
+	if lo == hi {
+		return false
+	}
+	num := lo + s.rng.Float64()*(hi-lo)
+	if num <= lo {
+		return true
+	}
+	left, right := s.newTotals(), s.newTotals()
+	for _, r := range rows {
+		if f.nums[r] < num {
+			left.add(s.y[r], utils.Weight(s.w, r))
+		} else {
+			right.add(s.y[r], utils.Weight(s.w, r))
+		}
+	}
+	G, missingLeft := s.scoreSplit(left, right, missing, true,
+		f, func(r int) bool { return f.nums[r] < num }, start, end)
+	if G < best.G {
+		*best = split{G: G, feature: f, num: num, missingLeft: missingLeft}
+	}
+	return true
+}
+
+// Empty totals
+func (s *splitter) newTotals() *totals {
+	t := totals{}
+	if s.nClasses > 0 {
+		t.classes = make([]float64, s.nClasses)
+	}
+	return &t
+}
+
+// Totals and number of rows start to end with a missing value of a
+// column, nil if there are none
+func (s *splitter) missingTotals(f *feature, start, end int) (*totals, int) {
+	if f.missing == nil {
+		return nil, 0
+	}
+	t, n := s.newTotals(), 0
+	for _, r := range s.rows[start:end] {
+		if f.missing[r] {
+			t.add(s.y[r], utils.Weight(s.w, r))
+			n++
+		}
+	}
+	if n == 0 {
+		return nil, 0
+	}
+	return t, n
+}
+
+// Score of a split of the rows that have a value, with the rows missing a
+// value (totals missing, nil if none) added to the side that gives the
+// lower score, and whether that is the left side. They only go left if
+// canLeft, i.e., the right side is not empty without them.
+func (s *splitter) scoreSplit(left, right, missing *totals, canLeft bool, f *feature,
+	isLeft func(r int) bool, start, end int) (float64, bool) {
+	if missing == nil {
+		return s.score(left, right, isLeft, start, end), false
+	}
+	withMissing := right.clone()
+	withMissing.merge(missing)
+	G := s.score(left, withMissing, func(r int) bool { return !f.missing[r] && isLeft(r) }, start, end)
+	if canLeft {
+		withMissing = left.clone()
+		withMissing.merge(missing)
+		GLeft := s.score(withMissing, right, func(r int) bool { return f.missing[r] || isLeft(r) }, start, end)
+		if GLeft < G {
+			return GLeft, true
+		}
+	}
+	return G, false
+}
+
+// Score of a split, from the totals of each side if the criterion can use
+// them, otherwise from the values of the rows on each side
+func (s *splitter) score(left, right *totals, isLeft func(r int) bool, start, end int) float64 {
+	if c, ok := s.p.Criterion.(totalsCriterion); ok {
+		return c.scoreOf(left, right)
+	}
+	var yl, yr, wl, wr []float64
+	for _, r := range s.rows[start:end] {
+		if isLeft(r) {
+			yl = append(yl, s.y[r])
+			wl = append(wl, utils.Weight(s.w, r))
+		} else {
+			yr = append(yr, s.y[r])
+			wr = append(wr, utils.Weight(s.w, r))
+		}
+	}
+	if s.w == nil {
+		wl, wr = nil, nil
+	}
+	return s.p.Criterion.Score(yl, yr, wl, wr)
+}
+
+// Apply a split to rows start to end of each list of row numbers, moving
+// the rows that go left to the start, and keeping the order otherwise.
+// Returns where the right side starts.
+func (s *splitter) partition(start, end int, best split) int {
+	f := best.feature
+	for _, r := range s.rows[start:end] {
+		if f.isMissing(r) {
+			s.goLeft[r] = best.missingLeft
+		} else if f.cats != nil {
+			s.goLeft[r] = f.cats[r] == best.cat
+		} else {
+			s.goLeft[r] = f.nums[r] < best.num
+		}
+	}
+	mid := s.partitionRows(s.rows, start, end)
+	for i := range s.features {
+		if s.features[i].sorted != nil {
+			s.partitionRows(s.features[i].sorted, start, end)
+		}
+	}
+	return mid
+}
+
+// Stable partition of rows start to end of a list of row numbers
+func (s *splitter) partitionRows(rows []int, start, end int) int {
+	nLeft, nRight := 0, 0
+	for _, r := range rows[start:end] {
+		if s.goLeft[r] {
+			rows[start+nLeft] = r
+			nLeft++
+		} else {
+			s.buf[nRight] = r
+			nRight++
+		}
+	}
+	copy(rows[start+nLeft:end], s.buf[:nRight])
+	return start + nLeft
+}
+
+// Values of the given rows, nil if there are no values (i.e., no weights)
+func gather(values []float64, rows []int) []float64 {
+	if values == nil {
+		return nil
+	}
+	res := make([]float64, len(rows))
+	for i, r := range rows {
+		res[i] = values[r]
+	}
+	return res
+}
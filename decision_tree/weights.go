@@ -0,0 +1,113 @@
+// Sample weights and class weights for decision trees. The weight of each
+// row is kept in a column of the dataframe, so that it stays with the row
+// when the data is split (or sampled for a random forest). The criterion
+// then uses the total weight of each class instead of the count, and
+// leaves predict the class with the highest total weight.
+
+package decision_tree
+
+import (
+	"mlcode/utils"
+)
+
+// Name of the column of combined sample and class weights, added to a
+// copy of the data when training with weights
+const weightCol = "_weight"
+
+// If there are sample or class weights, return a copy of the dataframe
+// with the combined weight of each row in a new column, and parameters
+// that use this column. Otherwise the dataframe and parameters are
+// returned unchanged. For a random forest, class weights are worked out
+// for each sample of the data. Panics if the weights are invalid.
+func applyWeights(df *utils.DataFrame, depv string, p TreeParams) (*utils.DataFrame, TreeParams) {
+	if p.WeightCol == "" && p.ClassWeight == "" {
+		return df, p
+	}
+
+	// Get the sample weights, if any
+	var weights []float64
+	if p.WeightCol != "" {
+		col := df.GetColumn(p.WeightCol)
+		if col == nil {
+			panic("DecisionTree: no weight column " + p.WeightCol)
+		}
+		weights = columnFloats(col)
+	}
+
+	// Combine with the class weights, which only make sense for labels
+	labels := df.GetColumn(depv).Strings
+	if df.GetColumn(depv).Dtype != "string" {
+		if p.ClassWeight != "" {
+			panic("DecisionTree: class weights need a column of string labels")
+		}
+		labels = make([]string, df.NRows())
+	}
+	s, err := utils.SampleWeights(labels, weights, p.ClassWeight)
+	if err != nil {
+		panic("DecisionTree: " + err.Error())
+	}
+
+	// Copy the dataframe, replacing the original weights with the combined
+	// weights
+	df2 := utils.DataFrame{}
+	for _, c := range *df {
+		if c.Name != p.WeightCol {
+			df2 = append(df2, c)
+		}
+	}
+	df2 = append(df2, utils.Series{Name: weightCol, Dtype: "float64", Floats: s})
+	p.WeightCol = weightCol
+	return &df2, p
+}
+
+// Weights of the rows of a dataframe, nil if there is no weight column
+func weightsOf(df *utils.DataFrame, p *TreeParams) []float64 {
+	if p.WeightCol == "" {
+		return nil
+	}
+	return columnFloats(df.GetColumn(p.WeightCol))
+}
+
+// Values of a numeric column as floats
+func columnFloats(col *utils.Series) []float64 {
+	switch col.Dtype {
+	case "float64":
+		return col.Floats
+	case "int64":
+		res := make([]float64, len(col.Ints))
+		for i, v := range col.Ints {
+			res[i] = float64(v)
+		}
+		return res
+	}
+	panic("DecisionTree: column " + col.Name + " is not numeric")
+}
+
+// Total of the weights for n rows, n if there are no weights
+func sumWeights(n int, weights []float64) float64 {
+	if weights == nil {
+		return float64(n)
+	}
+	var tot float64
+	for _, w := range weights {
+		tot += w
+	}
+	return tot
+}
+
+// Label with the highest total weight, or the most common label if there
+// are no weights
+func mostCommon(labels []string, weights []float64) string {
+	if weights == nil {
+		return utils.MostCommon(labels)
+	}
+	totals := map[string]float64{}
+	var best string
+	for i, l := range labels {
+		totals[l] += weights[i]
+		if i == 0 || totals[l] > totals[best] {
+			best = l
+		}
+	}
+	return best
+}
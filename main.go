@@ -11,6 +11,7 @@ import (
 	"mlcode/neural_net"
 	"mlcode/regression"
 	"mlcode/svm"
+	"mlcode/tuning"
 	"os"
 )
 
@@ -35,16 +36,31 @@ func main() {
 	} else if arg == "dectree" {
 		fmt.Println("Running decision tree demo (titanic)")
 		decision_tree.DecisionTreeDemo2()
+	} else if arg == "prune" {
+		fmt.Println("Running tree pruning demo (titanic)")
+		decision_tree.PruningDemo()
+	} else if arg == "regtree" {
+		fmt.Println("Running regression tree demo (pizzas, titanic fares)")
+		decision_tree.RegressionTreeDemo()
 	} else if arg == "forest" {
 		fmt.Println("Running random forest demo (titanic)")
 		decision_tree.RandomForestDemo()
+	} else if arg == "boost" {
+		fmt.Println("Running gradient boosting demo (titanic)")
+		decision_tree.GradientBoostDemo()
+	} else if arg == "adaboost" {
+		fmt.Println("Running AdaBoost demo (iris, titanic)")
+		decision_tree.AdaBoostDemo()
 	} else if arg == "svm" {
 		fmt.Println("Running SVM demo")
 		svm.SVMDemo()
 	} else if arg == "kmeans" {
 		fmt.Println("Running KMeans demo")
 		cluster.KMeansDemo()
+	} else if arg == "tuning" {
+		fmt.Println("Running hyperparameter search demo (iris)")
+		tuning.TuningDemo()
 	} else {
-		fmt.Println("Specify: linear, logistic, neural, dectree, forest, svm, or kmeans")
+		fmt.Println("Specify: linear, logistic, neural, dectree, prune, regtree, forest, boost, adaboost, svm, kmeans, or tuning")
 	}
 }
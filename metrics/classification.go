@@ -0,0 +1,189 @@
+// Metrics for evaluating classifiers. Labels can be strings (e.g., from
+// decision trees) or numbers (e.g., from a column vector, see Values).
+
+package metrics
+
+import (
+	"fmt"
+	"mlcode/utils"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Types that can be used as class labels
+type Label interface {
+	int | int64 | float64 | string
+}
+
+// How to average precision, recall and F1 over the classes
+type Average string
+
+const (
+	Macro    Average = "macro"    // unweighted mean over classes
+	Micro    Average = "micro"    // from total true/false positives over all classes
+	Weighted Average = "weighted" // mean over classes, weighted by number of actual instances
+)
+
+// Get the values of the first column of a matrix, e.g., a column vector
+// of labels or predictions
+func Values(m *mat.Dense) []float64 {
+	nr, _ := m.Dims()
+	vals := make([]float64, nr)
+	for i := 0; i < nr; i++ {
+		vals[i] = m.At(i, 0)
+	}
+	return vals
+}
+
+// Confusion matrix, with one row per actual class and one column per
+// predicted class, in the order of the sorted labels
+type ConfusionMatrix[T Label] struct {
+	Labels []T
+	Counts [][]int
+}
+
+// Create a confusion matrix from actual and predicted labels
+func Confusion[T Label](actual, pred []T) *ConfusionMatrix[T] {
+	utils.Assert(len(actual) == len(pred), "Confusion: actual and predicted have different lengths")
+
+	// All labels found in actual or predicted values
+	labels := utils.Unique(append(append([]T{}, actual...), pred...))
+	index := map[T]int{}
+	for i, l := range labels {
+		index[l] = i
+	}
+
+	// Count each combination
+	cm := ConfusionMatrix[T]{Labels: labels, Counts: make([][]int, len(labels))}
+	for i := range labels {
+		cm.Counts[i] = make([]int, len(labels))
+	}
+	for i := range actual {
+		cm.Counts[index[actual[i]]][index[pred[i]]]++
+	}
+	return &cm
+}
+
+// True positives, false positives and false negatives for one class
+func (cm *ConfusionMatrix[T]) counts(c int) (tp, fp, fn int) {
+	tp = cm.Counts[c][c]
+	for i := range cm.Labels {
+		if i != c {
+			fp += cm.Counts[i][c]
+			fn += cm.Counts[c][i]
+		}
+	}
+	return
+}
+
+// Number of actual instances of one class
+func (cm *ConfusionMatrix[T]) support(c int) int {
+	n := 0
+	for _, v := range cm.Counts[c] {
+		n += v
+	}
+	return n
+}
+
+// Precision of one class, i.e., fraction of predictions that are correct
+func (cm *ConfusionMatrix[T]) Precision(c int) float64 {
+	tp, fp, _ := cm.counts(c)
+	return ratio(tp, tp+fp)
+}
+
+// Recall of one class, i.e., fraction of actual instances found
+func (cm *ConfusionMatrix[T]) Recall(c int) float64 {
+	tp, _, fn := cm.counts(c)
+	return ratio(tp, tp+fn)
+}
+
+// F1 score of one class, i.e., harmonic mean of precision and recall
+func (cm *ConfusionMatrix[T]) F1(c int) float64 {
+	tp, fp, fn := cm.counts(c)
+	return ratio(2*tp, 2*tp+fp+fn)
+}
+
+// Average a per-class metric over all classes. Micro averaging uses the
+// total counts, which for F1 and precision/recall is the accuracy when
+// every instance has exactly one label.
+func (cm *ConfusionMatrix[T]) average(metric func(c int) float64, avg Average) float64 {
+	switch avg {
+	case Micro:
+		var tp, fp, fn int
+		for c := range cm.Labels {
+			a, b, d := cm.counts(c)
+			tp, fp, fn = tp+a, fp+b, fn+d
+		}
+		return ratio(2*tp, 2*tp+fp+fn)
+	case Macro, Weighted:
+		var tot, wtot float64
+		for c := range cm.Labels {
+			w := 1.0
+			if avg == Weighted {
+				w = float64(cm.support(c))
+			}
+			tot += w * metric(c)
+			wtot += w
+		}
+		if wtot == 0 {
+			return 0
+		}
+		return tot / wtot
+	default:
+		panic("average: invalid averaging " + string(avg))
+	}
+}
+
+// Print the confusion matrix, with per-class precision, recall and F1
+func (cm *ConfusionMatrix[T]) Print() {
+	fmt.Printf("%12s |", "actual\\pred")
+	for _, l := range cm.Labels {
+		fmt.Printf(" %8v", l)
+	}
+	fmt.Printf(" | %9s %9s %9s\n", "precision", "recall", "F1")
+	for i, l := range cm.Labels {
+		fmt.Printf("%12v |", l)
+		for _, n := range cm.Counts[i] {
+			fmt.Printf(" %8d", n)
+		}
+		fmt.Printf(" | %9.4f %9.4f %9.4f\n", cm.Precision(i), cm.Recall(i), cm.F1(i))
+	}
+}
+
+// Fraction of predictions that are correct
+func Accuracy[T Label](actual, pred []T) float64 {
+	utils.Assert(len(actual) == len(pred), "Accuracy: actual and predicted have different lengths")
+	ok := 0
+	for i := range actual {
+		if actual[i] == pred[i] {
+			ok++
+		}
+	}
+	return ratio(ok, len(actual))
+}
+
+// Precision averaged over classes
+func Precision[T Label](actual, pred []T, avg Average) float64 {
+	cm := Confusion(actual, pred)
+	return cm.average(cm.Precision, avg)
+}
+
+// Recall averaged over classes
+func Recall[T Label](actual, pred []T, avg Average) float64 {
+	cm := Confusion(actual, pred)
+	return cm.average(cm.Recall, avg)
+}
+
+// F1 score averaged over classes
+func F1[T Label](actual, pred []T, avg Average) float64 {
+	cm := Confusion(actual, pred)
+	return cm.average(cm.F1, avg)
+}
+
+// Divide two counts, zero if the denominator is zero
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
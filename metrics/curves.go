@@ -0,0 +1,140 @@
+// ROC and precision-recall curves, and log loss, for classifiers that
+// predict scores or probabilities
+
+package metrics
+
+import (
+	"math"
+	"mlcode/utils"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Points where a curve changes, i.e., true and false positives at each
+// distinct score threshold, from highest to lowest score
+type curvePoints struct {
+	tp, fp     []float64
+	thresholds []float64
+	pos, neg   float64 // total positives and negatives
+}
+
+// Sort instances by score, and count true and false positives for a
+// threshold at each distinct score
+func sweep[T Label](actual []T, scores []float64, positive T) curvePoints {
+	utils.Assert(len(actual) == len(scores), "sweep: actual and scores have different lengths")
+
+	// Order instances by decreasing score
+	idx := make([]int, len(scores))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return scores[idx[i]] > scores[idx[j]]
+	})
+
+	// Count positives at each threshold, only where the score changes
+	var c curvePoints
+	var tp, fp float64
+	for k, i := range idx {
+		if actual[i] == positive {
+			tp++
+		} else {
+			fp++
+		}
+		if k == len(idx)-1 || scores[idx[k+1]] != scores[i] {
+			c.tp = append(c.tp, tp)
+			c.fp = append(c.fp, fp)
+			c.thresholds = append(c.thresholds, scores[i])
+		}
+	}
+	c.pos, c.neg = tp, fp
+	return c
+}
+
+// Receiver operating characteristic curve for a binary classifier, given
+// the score (e.g., probability) of the positive class for each instance.
+// Returns false positive rates, true positive rates, and the thresholds
+// (predict positive if score >= threshold), starting at (0, 0).
+func ROC[T Label](actual []T, scores []float64, positive T) (fpr, tpr, thresholds []float64) {
+	c := sweep(actual, scores, positive)
+	fpr = []float64{0}
+	tpr = []float64{0}
+	thresholds = []float64{math.Inf(1)}
+	for i := range c.tp {
+		fpr = append(fpr, safeDiv(c.fp[i], c.neg))
+		tpr = append(tpr, safeDiv(c.tp[i], c.pos))
+		thresholds = append(thresholds, c.thresholds[i])
+	}
+	return
+}
+
+// Precision-recall curve for a binary classifier, given the score of the
+// positive class for each instance. Returns precision, recall, and the
+// thresholds, starting at recall 0 and precision 1.
+func PRCurve[T Label](actual []T, scores []float64, positive T) (precision, recall, thresholds []float64) {
+	c := sweep(actual, scores, positive)
+	precision = []float64{1}
+	recall = []float64{0}
+	thresholds = []float64{math.Inf(1)}
+	for i := range c.tp {
+		precision = append(precision, safeDiv(c.tp[i], c.tp[i]+c.fp[i]))
+		recall = append(recall, safeDiv(c.tp[i], c.pos))
+		thresholds = append(thresholds, c.thresholds[i])
+	}
+	return
+}
+
+// Area under a curve, using the trapezoidal rule (x must be sorted)
+func AUC(x, y []float64) float64 {
+	var area float64
+	for i := 1; i < len(x); i++ {
+		area += (x[i] - x[i-1]) * (y[i] + y[i-1]) / 2
+	}
+	return area
+}
+
+// Area under the ROC curve
+func ROCAUC[T Label](actual []T, scores []float64, positive T) float64 {
+	fpr, tpr, _ := ROC(actual, scores, positive)
+	return AUC(fpr, tpr)
+}
+
+// Average precision, i.e., the area under the precision-recall curve
+// using the precision at each step up in recall
+func AveragePrecision[T Label](actual []T, scores []float64, positive T) float64 {
+	precision, recall, _ := PRCurve(actual, scores, positive)
+	var ap float64
+	for i := 1; i < len(recall); i++ {
+		ap += (recall[i] - recall[i-1]) * precision[i]
+	}
+	return ap
+}
+
+// Cross-entropy loss of predicted probabilities, with one column per
+// class in the same order as the list of classes. Probabilities are
+// clipped to avoid taking the log of zero.
+func LogLoss[T Label](actual []T, probs *mat.Dense, classes []T) float64 {
+	nr, nc := probs.Dims()
+	utils.Assert(nr == len(actual) && nc == len(classes), "LogLoss: probabilities do not match labels and classes")
+	eps := 1e-15
+	var loss float64
+	for i, a := range actual {
+		p := 0.0
+		for j, c := range classes {
+			if a == c {
+				p = probs.At(i, j)
+			}
+		}
+		loss -= math.Log(math.Min(math.Max(p, eps), 1-eps))
+	}
+	return loss / float64(nr)
+}
+
+// Divide, zero if the denominator is zero
+func safeDiv(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
@@ -0,0 +1,111 @@
+// Feature importance: how much each column of the data matters to a model,
+// as a table sorted from most to least important. Models can give their
+// own measure (e.g., the impurity decrease of the splits of a tree), or
+// PermutationImportance works for any model.
+
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"mlcode/utils"
+	"sort"
+)
+
+// Importance of one column
+type FeatureImportance struct {
+	Name       string
+	Importance float64
+	Std        float64 // standard deviation, e.g., over repeats, 0 if none
+}
+
+// Importance of each column, most important first
+type ImportanceTable []FeatureImportance
+
+// Create a table from the importance of each column
+func NewImportanceTable(importance map[string]float64) ImportanceTable {
+	t := ImportanceTable{}
+	for name, v := range importance {
+		t = append(t, FeatureImportance{Name: name, Importance: v})
+	}
+	t.sort()
+	return t
+}
+
+// Sort by importance, most important first, then by name
+func (t ImportanceTable) sort() {
+	sort.Slice(t, func(i, j int) bool {
+		if t[i].Importance != t[j].Importance {
+			return t[i].Importance > t[j].Importance
+		}
+		return t[i].Name < t[j].Name
+	})
+}
+
+// Importance of a column, 0 if not in the table
+func (t ImportanceTable) Get(name string) float64 {
+	for _, f := range t {
+		if f.Name == name {
+			return f.Importance
+		}
+	}
+	return 0
+}
+
+// Print the table
+func (t ImportanceTable) Print() {
+	fmt.Printf("%12s %10s %10s\n", "column", "importance", "std")
+	for _, f := range t {
+		fmt.Printf("%12s %10.4f %10.4f\n", f.Name, f.Importance, f.Std)
+	}
+}
+
+// Permutation importance (Breiman, 2001) of each column of a dataframe
+// except the target, for any model, as permutation_importance in
+// scikit-learn. The score function gives the score of the model on a
+// dataframe, higher is better (e.g., accuracy on held out rows). The
+// importance of a column is how much the score drops when its values are
+// shuffled, which breaks the link between the column and the target.
+// Shuffling is repeated nRepeats times (default 5), giving the mean and
+// standard deviation of the drop.
+func PermutationImportance(df *utils.DataFrame, depv string, score func(df *utils.DataFrame) float64,
+	nRepeats int, seed int64) ImportanceTable {
+	nRepeats = utils.IfThenElse(nRepeats <= 0, 5, nRepeats)
+	rng := rand.New(rand.NewSource(seed))
+	base := score(df)
+	t := ImportanceTable{}
+	for c, col := range *df {
+		if col.Name == depv {
+			continue
+		}
+
+		// Score with the column shuffled, in a copy of the dataframe that
+		// shares the other columns
+		drops := make([]float64, nRepeats)
+		for k := range drops {
+			shuffled := append(utils.DataFrame{}, *df...)
+			shuffled[c] = permuteSeries(&col, rng.Perm(df.NRows()))
+			drops[k] = base - score(&shuffled)
+		}
+		t = append(t, FeatureImportance{Name: col.Name, Importance: average(drops), Std: math.Sqrt(variance(drops))})
+	}
+	t.sort()
+	return t
+}
+
+// Copy of a column, with the values in the given order
+func permuteSeries(s *utils.Series, order []int) utils.Series {
+	res := utils.Series{Name: s.Name, Dtype: s.Dtype}
+	for _, i := range order {
+		switch s.Dtype {
+		case "int64":
+			res.Ints = append(res.Ints, s.Ints[i])
+		case "float64":
+			res.Floats = append(res.Floats, s.Floats[i])
+		case "string":
+			res.Strings = append(res.Strings, s.Strings[i])
+		}
+	}
+	return res
+}
@@ -0,0 +1,106 @@
+// Unit tests for metrics, expected values as calculated by scikit-learn
+
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"mlcode/utils"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Check that a value is close to the expected value
+func check(t *testing.T, name string, got, expect float64) {
+	if math.Abs(got-expect) > .0001 {
+		t.Errorf("%s = %f instead of %f", name, got, expect)
+	}
+}
+
+// Test classification metrics on string labels
+func TestClassification(t *testing.T) {
+	actual := []string{"a", "a", "b", "b", "c", "c"}
+	pred := []string{"a", "b", "b", "b", "c", "a"}
+	cm := Confusion(actual, pred)
+	if cm.Counts[0][1] != 1 || cm.Counts[1][1] != 2 || cm.Counts[2][0] != 1 {
+		t.Errorf("Wrong confusion matrix %v", cm.Counts)
+	}
+	check(t, "Accuracy", Accuracy(actual, pred), .6667)
+	check(t, "Precision macro", Precision(actual, pred, Macro), .7222)
+	check(t, "Recall macro", Recall(actual, pred, Macro), .6667)
+	check(t, "F1 macro", F1(actual, pred, Macro), .6556)
+	check(t, "F1 micro", F1(actual, pred, Micro), .6667)
+	check(t, "F1 weighted", F1(actual, pred, Weighted), .6556)
+
+	// Numeric labels from matrices
+	Y := mat.NewDense(4, 1, []float64{1, 0, 1, 1})
+	P := mat.NewDense(4, 1, []float64{1, 0, 0, 1})
+	check(t, "Accuracy (matrix)", Accuracy(Values(Y), Values(P)), .75)
+	check(t, "Recall weighted", Recall(Values(Y), Values(P), Weighted), .75)
+}
+
+// Test ROC and precision-recall curves, and log loss
+func TestCurves(t *testing.T) {
+	actual := []float64{0, 0, 1, 1}
+	scores := []float64{.1, .4, .35, .8}
+	fpr, tpr, _ := ROC(actual, scores, 1)
+	if len(fpr) != 5 || tpr[1] != .5 || fpr[2] != .5 {
+		t.Errorf("Wrong ROC curve %v %v", fpr, tpr)
+	}
+	check(t, "AUC", ROCAUC(actual, scores, 1), .75)
+	check(t, "Average precision", AveragePrecision(actual, scores, 1), .8333)
+
+	probs := mat.NewDense(4, 2, []float64{.1, .9, .9, .1, .8, .2, .35, .65})
+	labels := []string{"spam", "ham", "ham", "spam"}
+	check(t, "Log loss", LogLoss(labels, probs, []string{"ham", "spam"}), .21616)
+}
+
+// Test regression metrics
+func TestRegression(t *testing.T) {
+	actual := []float64{3, -.5, 2, 7}
+	pred := []float64{2.5, 0, 2, 8}
+	check(t, "MSE", MSE(actual, pred), .375)
+	check(t, "RMSE", RMSE(actual, pred), math.Sqrt(.375))
+	check(t, "MAE", MAE(actual, pred), .5)
+	check(t, "R2", R2(actual, pred), .9486)
+	check(t, "Explained variance", ExplainedVariance(actual, pred), .9572)
+}
+
+// Test the importance table, and permutation importance
+func TestImportance(t *testing.T) {
+	table := NewImportanceTable(map[string]float64{"b": .2, "a": .5, "c": .2})
+	if table[0].Name != "a" || table[1].Name != "b" || table[2].Name != "c" || table.Get("c") != .2 || table.Get("d") != 0 {
+		t.Errorf("Wrong table %v", table)
+	}
+
+	// The "model" only looks at x, so shuffling z changes nothing, and
+	// shuffling x makes it about as good as a guess
+	n := 1000
+	df := utils.DataFrame{
+		{Name: "x", Dtype: "float64", Floats: make([]float64, n)},
+		{Name: "z", Dtype: "int64", Ints: make([]int64, n)},
+		{Name: "y", Dtype: "string", Strings: make([]string, n)},
+	}
+	for i := 0; i < n; i++ {
+		df[0].Floats[i] = float64(i % 2)
+		df[1].Ints[i] = int64(i % 7)
+		df[2].Strings[i] = fmt.Sprint(i % 2)
+	}
+	accuracy := func(df *utils.DataFrame) float64 {
+		var ok int
+		for i, x := range df.GetColumn("x").Floats {
+			if fmt.Sprint(x) == df.GetColumn("y").Strings[i] {
+				ok++
+			}
+		}
+		return float64(ok) / float64(df.NRows())
+	}
+	imp := PermutationImportance(&df, "y", accuracy, 10, 1)
+	if len(imp) != 2 || imp[0].Name != "x" || math.Abs(imp[0].Importance-.5) > .05 || imp[0].Std == 0 || imp.Get("z") != 0 {
+		t.Errorf("Wrong permutation importance %v", imp)
+	}
+	if df[0].Floats[1] != 1 {
+		t.Error("Permutation importance changed the data")
+	}
+}
@@ -0,0 +1,84 @@
+// Metrics for evaluating regression models
+
+package metrics
+
+import (
+	"math"
+	"mlcode/utils"
+)
+
+// Mean squared error
+func MSE(actual, pred []float64) float64 {
+	utils.Assert(len(actual) == len(pred), "MSE: actual and predicted have different lengths")
+	var tot float64
+	for i := range actual {
+		d := actual[i] - pred[i]
+		tot += d * d
+	}
+	return tot / float64(len(actual))
+}
+
+// Root mean squared error
+func RMSE(actual, pred []float64) float64 {
+	return math.Sqrt(MSE(actual, pred))
+}
+
+// Mean absolute error
+func MAE(actual, pred []float64) float64 {
+	utils.Assert(len(actual) == len(pred), "MAE: actual and predicted have different lengths")
+	var tot float64
+	for i := range actual {
+		tot += math.Abs(actual[i] - pred[i])
+	}
+	return tot / float64(len(actual))
+}
+
+// Coefficient of determination, i.e., 1 - residual sum of squares / total
+// sum of squares. Can be negative if predictions are worse than the mean.
+func R2(actual, pred []float64) float64 {
+	utils.Assert(len(actual) == len(pred), "R2: actual and predicted have different lengths")
+	mean := average(actual)
+	var ssRes, ssTot float64
+	for i := range actual {
+		ssRes += (actual[i] - pred[i]) * (actual[i] - pred[i])
+		ssTot += (actual[i] - mean) * (actual[i] - mean)
+	}
+	if ssTot == 0 {
+		return 0
+	}
+	return 1 - ssRes/ssTot
+}
+
+// Explained variance, i.e., 1 - variance of residuals / variance of actual
+// values. Same as R2 if the residuals have a mean of zero.
+func ExplainedVariance(actual, pred []float64) float64 {
+	utils.Assert(len(actual) == len(pred), "ExplainedVariance: actual and predicted have different lengths")
+	resid := make([]float64, len(actual))
+	for i := range actual {
+		resid[i] = actual[i] - pred[i]
+	}
+	v := variance(actual)
+	if v == 0 {
+		return 0
+	}
+	return 1 - variance(resid)/v
+}
+
+// Mean of a list of numbers
+func average(x []float64) float64 {
+	var tot float64
+	for _, v := range x {
+		tot += v
+	}
+	return tot / float64(len(x))
+}
+
+// Population variance of a list of numbers
+func variance(x []float64) float64 {
+	mean := average(x)
+	var tot float64
+	for _, v := range x {
+		tot += (v - mean) * (v - mean)
+	}
+	return tot / float64(len(x))
+}
@@ -0,0 +1,78 @@
+// Activation functions for the layers of a neural network
+
+package neural_net
+
+import (
+	"math"
+	"mlcode/utils"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Activation function for a layer, selected by name
+type Activation string
+
+// Available activation functions
+const (
+	ActSigmoid   Activation = "sigmoid"
+	ActTanh      Activation = "tanh"
+	ActReLU      Activation = "relu"
+	ActLeakyReLU Activation = "leaky_relu"
+	ActSoftmax   Activation = "softmax" // output layer only
+	ActLinear    Activation = "linear"
+)
+
+// Slope of the leaky ReLU for negative inputs
+const leakySlope = 0.01
+
+// Apply an activation function to a matrix of weighted sums, returns a new
+// matrix (softmax is applied to each row, the others to each value)
+func (a Activation) Apply(z *mat.Dense) *mat.Dense {
+	if a == ActSoftmax {
+		return SoftMax(z)
+	}
+	nr, nc := z.Dims()
+	res := mat.NewDense(nr, nc, nil)
+	res.Apply(func(i, j int, v float64) float64 {
+		switch a {
+		case ActSigmoid:
+			return utils.Sigmoid(v)
+		case ActTanh:
+			return math.Tanh(v)
+		case ActReLU:
+			return math.Max(v, 0)
+		case ActLeakyReLU:
+			return utils.IfThenElse(v > 0, v, v*leakySlope)
+		case ActLinear, "":
+			return v
+		default:
+			panic("Activation: invalid activation " + string(a))
+		}
+	}, z)
+	return res
+}
+
+// Derivative of the activation function, calculated from its output
+// rather than its input. Not defined for softmax, which is only used
+// together with cross-entropy loss in the output layer.
+func (a Activation) Gradient(out *mat.Dense) *mat.Dense {
+	nr, nc := out.Dims()
+	res := mat.NewDense(nr, nc, nil)
+	res.Apply(func(i, j int, v float64) float64 {
+		switch a {
+		case ActSigmoid:
+			return v * (1 - v)
+		case ActTanh:
+			return 1 - v*v
+		case ActReLU:
+			return utils.IfThenElse(v > 0, 1.0, 0.0)
+		case ActLeakyReLU:
+			return utils.IfThenElse(v > 0, 1.0, leakySlope)
+		case ActLinear, "":
+			return 1
+		default:
+			panic("Gradient: no derivative for activation " + string(a))
+		}
+	}, out)
+	return res
+}
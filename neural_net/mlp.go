@@ -0,0 +1,241 @@
+// Multilayer perceptron, with any number of fully-connected layers, each
+// with its own activation function
+//
+// Sample usage:
+//
+//	m := MLP{Layers: []Dense{{Units: 64, Activation: ActReLU},
+//	    {Units: 32, Activation: ActTanh}, {Units: 10, Activation: ActSoftmax}},
+//	    LR: .1, Epochs: 500}
+//	m.Train(X, Y) // Y is one-hot encoded, one column per class
+//	preds := m.Classify(X)
+
+package neural_net
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"mlcode/optimizer"
+	"mlcode/utils"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// One fully-connected layer of a network
+type Dense struct {
+	Units      int        // number of nodes (outputs) in the layer
+	Activation Activation // activation function, default linear
+	w          *mat.Dense // weights, first row is the bias, set during training
+}
+
+// Structure for a multilayer perceptron. The last layer is the output
+// layer: with softmax or sigmoid it is trained on cross-entropy loss,
+// otherwise on squared error.
+type MLP struct {
+	Layers  []Dense   // hidden and output layers, in order
+	LR      float64   // learning rate, default .01
+	Epochs  int       // number of passes through the training data, default 100
+	Init    string    // weight initialization: xavier (default), he, normal or uniform
+	Seed    int64     // seed for random weight initialization and shuffling
+	Verbose bool      // messages during training, default false
+	classes []float64 // class labels, if trained using Fit
+
+	// Optional settings for mini-batch training, default is plain gradient
+	// descent using LR on all rows at once
+	Optimizer optimizer.Optimizer // e.g., &optimizer.Adam{LR: .001}
+	BatchSize int                 // number of rows per batch, default all
+	Shuffle   bool                // shuffle rows before each pass
+}
+
+// Train the network using backpropagation, X has one row per instance
+// (without bias column), Y has one column per output node
+func (m *MLP) Train(X, Y *mat.Dense) {
+
+	// Check the layers
+	utils.Assert(len(m.Layers) > 0, "MLP: no layers defined")
+	for i, l := range m.Layers {
+		utils.Assert(l.Units > 0, "MLP: layer has no units")
+		utils.Assert(l.Activation != ActSoftmax || i == len(m.Layers)-1, "MLP: softmax only allowed in output layer")
+	}
+	utils.Assert(m.Layers[len(m.Layers)-1].Units == utils.MatCols(Y), "MLP: output layer does not match Y")
+
+	// Set model parameters if not set yet
+	if m.LR <= 0 {
+		m.LR = .01
+	}
+	if m.Epochs <= 0 {
+		m.Epochs = 100
+	}
+
+	// Initialize weights randomly, each layer has one row per input plus bias
+	m.classes = nil
+	rng := rand.New(rand.NewSource(m.Seed))
+	inputs := utils.MatCols(X)
+	for i := range m.Layers {
+		m.Layers[i].w = initWeights(inputs+1, m.Layers[i].Units, m.Init, rng)
+		inputs = m.Layers[i].Units
+	}
+
+	// Gradient descent, one step per batch
+	opt := m.Optimizer
+	if opt == nil {
+		opt = &optimizer.SGD{LR: m.LR}
+	}
+	for i := 0; i < m.Epochs; i++ {
+		for _, batch := range optimizer.Batches(utils.MatRows(X), m.BatchSize, m.Shuffle, rng) {
+			grads := m.Backprop(optimizer.BatchRows(X, batch), optimizer.BatchRows(Y, batch))
+			for l := range m.Layers {
+				opt.Step(m.Layers[l].w, grads[l])
+			}
+		}
+		if m.Verbose {
+			fmt.Printf("Epoch %d: loss = %f\n", i, m.Loss(X, Y))
+		}
+	}
+}
+
+// Forward propagation through all the layers, returns the output of each
+// layer (the last one being the prediction)
+func (m *MLP) forward(X *mat.Dense) []*mat.Dense {
+	outs := make([]*mat.Dense, len(m.Layers))
+	a := X
+	for i, l := range m.Layers {
+		a1 := utils.PrependBias(a)
+		z := mat.NewDense(utils.MatRows(a1), l.Units, nil)
+		z.Mul(a1, l.w)
+		a = l.Activation.Apply(z)
+		outs[i] = a
+	}
+	return outs
+}
+
+// Forward prediction, returns the output layer values
+func (m *MLP) Forward(X *mat.Dense) *mat.Dense {
+	outs := m.forward(X)
+	return outs[len(outs)-1]
+}
+
+// Compute the gradient of the loss with respect to the weights of each
+// layer, working backward from the output layer
+func (m *MLP) Backprop(X, Y *mat.Dense) []*mat.Dense {
+
+	// Forward pass, keeping the output of each layer
+	outs := m.forward(X)
+	n := float64(utils.MatRows(X))
+	last := len(m.Layers) - 1
+
+	// Error at the output layer. With cross-entropy loss the gradient is
+	// just y_hat - Y, with squared error it also goes through the activation
+	delta := mat.NewDense(utils.MatRows(Y), utils.MatCols(Y), nil)
+	delta.Sub(outs[last], Y)
+	if !m.crossEntropy() {
+		delta.MulElem(delta, m.Layers[last].Activation.Gradient(outs[last]))
+	}
+
+	// Work backwards through the layers
+	grads := make([]*mat.Dense, len(m.Layers))
+	for l := last; l >= 0; l-- {
+
+		// Input to this layer, with bias prepended
+		in := X
+		if l > 0 {
+			in = outs[l-1]
+		}
+		in1 := utils.PrependBias(in)
+
+		// Gradient for this layer's weights
+		w := m.Layers[l].w
+		grads[l] = mat.NewDense(utils.MatRows(w), utils.MatCols(w), nil)
+		grads[l].Mul(in1.T(), delta)
+		grads[l].Scale(1/n, grads[l])
+
+		// Propagate error to the previous layer, through the weights
+		// (without the bias row) and the previous layer's activation
+		if l > 0 {
+			wNoBias := w.Slice(1, utils.MatRows(w), 0, utils.MatCols(w))
+			prev := mat.NewDense(utils.MatRows(in), utils.MatCols(in), nil)
+			prev.Mul(delta, wNoBias.T())
+			prev.MulElem(prev, m.Layers[l-1].Activation.Gradient(in))
+			delta = prev
+		}
+	}
+	return grads
+}
+
+// Loss of predictions vs. actual values: cross-entropy if the output layer
+// is softmax or sigmoid, otherwise half the squared error, averaged over rows
+func (m *MLP) Loss(X, Y *mat.Dense) float64 {
+	yHat := m.Forward(X)
+	nr, nc := Y.Dims()
+	out := m.Layers[len(m.Layers)-1].Activation
+	var loss float64
+	for r := 0; r < nr; r++ {
+		for c := 0; c < nc; c++ {
+			y, p := Y.At(r, c), yHat.At(r, c)
+			if out == ActSoftmax {
+				loss -= y * math.Log(p)
+			} else if out == ActSigmoid {
+				loss -= y*math.Log(p) + (1-y)*math.Log(1-p)
+			} else {
+				loss += (p - y) * (p - y) / 2
+			}
+		}
+	}
+	return loss / float64(nr)
+}
+
+// Classify, returns column vector with the output column that has the
+// highest value for each row
+func (m *MLP) Classify(X *mat.Dense) *mat.Dense {
+	preds := m.Forward(X)
+	nr, _ := preds.Dims()
+	result := mat.NewDense(nr, 1, nil)
+	for r := 0; r < nr; r++ {
+		result.Set(r, 0, float64(utils.MaxCol(preds, r)))
+	}
+	return result
+}
+
+// Fit the network to training data, for the utils.Estimator interface. With
+// a softmax output layer, Y is a column of class labels, which are one-hot
+// encoded for training; otherwise Y is the target values.
+func (m *MLP) Fit(X, Y *mat.Dense) error {
+	if err := utils.CheckXY(X, Y); err != nil {
+		return err
+	}
+	if len(m.Layers) == 0 {
+		return errors.New("MLP: no layers defined")
+	}
+	if m.Layers[len(m.Layers)-1].Activation != ActSoftmax {
+		m.Train(X, Y)
+		return nil
+	}
+	classes := utils.Classes(Y)
+	if m.Layers[len(m.Layers)-1].Units != len(classes) {
+		return fmt.Errorf("MLP: output layer has %d units for %d classes", m.Layers[len(m.Layers)-1].Units, len(classes))
+	}
+	m.Train(X, utils.OneHot(Y, classes))
+	m.classes = classes
+	return nil
+}
+
+// Predict the class label for each row if trained as a classifier using
+// Fit, otherwise the output layer values
+func (m *MLP) Predict(X *mat.Dense) *mat.Dense {
+	if m.classes == nil {
+		return m.Forward(X)
+	}
+	return utils.ArgMaxLabels(m.Forward(X), m.classes)
+}
+
+// Predict the probability of each class, i.e., the output layer values
+func (m *MLP) PredictProba(X *mat.Dense) *mat.Dense {
+	return m.Forward(X)
+}
+
+// Is the output layer trained on cross-entropy loss?
+func (m *MLP) crossEntropy() bool {
+	out := m.Layers[len(m.Layers)-1].Activation
+	return out == ActSoftmax || out == ActSigmoid
+}
@@ -14,11 +14,12 @@ import (
 	"os"
 
 	"gonum.org/v1/gonum/mat"
-	"mlcode/regression"
+	"mlcode/metrics"
+	"mlcode/utils"
 )
 
-// Demonstration of logistic regression on MNIST digits data, achieves 95%
-// accuracy on identifying the digit 5 (but 10/90 imbalanced data set).
+// Demonstration of a neural network with one hidden layer, trained using
+// backpropagation on MNIST digits data.
 func MnistDemo() {
 
 	// Read training images and labels
@@ -33,37 +34,19 @@ func MnistDemo() {
 	// one row per label, and 10 columns, one for each possible digit 0-9
 	labs1 := OneHotEncode(labs) // not required for test labels
 
-	// For binary classifier, convert labels to 1 if 5 or 0 otherwise
-	// labs[i] = ifThenElse(labs[i] == 5, 1, 0)
-	// Uncomment this to do the binary classification
-	/*labs1 := mat.NewDense(len(labs), 1, nil)
-	for i := 0; i < len(labs); i++ {
-		if labs[i] == 5 {
-			labs1.Set(i, 0, 1)
-		}
-	}
+	// The network adds its own bias, so remove the bias column from the
+	// images, and scale pixels to between 0 and 1
+	pics = scalePixels(pics)
+	tpics = scalePixels(tpics)
 
-	// Create and train binary logistic regression model,
-	//m := LogisticRegression{iterations: 100, lr: .00001, verbose: true}
-	//m.train(pics1, labs1)
-	*/
-
-	// Train multi-class classifier using 10-column one-hot encoded labels
-	m := regression.MultiLogRegression{Iterations: 100, LR: 1e-5, Verbose: true}
+	// Train neural network, with one hidden layer of 100 nodes
+	m := NeuralNetwork{Hidden: 100, LR: .5, Epochs: 200, Verbose: true}
 	m.Train(pics, labs1)
 
 	// Predict on test data, measure simple accuracy
 	fmt.Println("Predicting")
-	preds := m.Classify(tpics) // should training pics include bias?
-	var ok, n int
-	nlabs, _ := tlabs.Dims()
-	for i := 0; i < nlabs; i++ {
-		n += 1
-		if tlabs.At(i, 0) == preds.At(i, 0) {
-			ok += 1
-		}
-	}
-	fmt.Printf("Accuracy = %f\n", float64(ok)/float64(n))
+	preds := m.Classify(tpics)
+	fmt.Printf("Accuracy = %f\n", metrics.Accuracy(metrics.Values(tlabs), metrics.Values(preds)))
 }
 
 // Read MNIST images file, returns a matrix with one image per row,
@@ -177,3 +160,11 @@ func OneHotEncode(labels *mat.Dense) *mat.Dense {
 	}
 	return result
 }
+
+// Remove the bias column from a matrix of images, and scale the pixel
+// values from 0-255 to 0-1
+func scalePixels(pics *mat.Dense) *mat.Dense {
+	res := utils.ExtractCols(pics, 1, -1)
+	res.Scale(1.0/255, res)
+	return res
+}
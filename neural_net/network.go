@@ -0,0 +1,194 @@
+// Trainable neural network with one hidden layer, using backpropagation,
+// based on chapters 11-12 of "Programming Machine Learning" by Paolo Perotta
+//
+// Sample usage:
+//
+//	m := NeuralNetwork{Hidden: 100, LR: .5, Epochs: 200, Verbose: true}
+//	m.Train(X, Y) // Y is one-hot encoded, one column per class
+//	preds := m.Classify(X)
+
+package neural_net
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"mlcode/optimizer"
+	"mlcode/utils"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Structure for a neural network with one hidden layer (sigmoid) and a
+// softmax output layer, trained using cross-entropy loss
+type NeuralNetwork struct {
+	Hidden  int     // number of hidden nodes, default 100
+	LR      float64 // learning rate, default .01
+	Epochs  int     // number of passes through the training data, default 100
+	Init    string  // weight initialization: xavier (default), he, normal or uniform
+	Seed    int64   // seed for random weight initialization and shuffling
+	Verbose bool    // messages during training, default false
+	w1, w2  *mat.Dense
+	classes []float64 // class labels, if trained using Fit
+
+	// Optional settings for mini-batch training, default is plain gradient
+	// descent using LR on all rows at once
+	Optimizer optimizer.Optimizer // e.g., &optimizer.Adam{LR: .001}
+	BatchSize int                 // number of rows per batch, default all
+	Shuffle   bool                // shuffle rows before each pass
+}
+
+// Train the network using backpropagation, X has one row per instance
+// (without bias column), Y is one-hot encoded with one column per class.
+// Sets the weights of the hidden and output layers.
+func (m *NeuralNetwork) Train(X, Y *mat.Dense) {
+
+	// Set model parameters if not set yet
+	if m.Hidden <= 0 {
+		m.Hidden = 100
+	}
+	if m.LR <= 0 {
+		m.LR = .01
+	}
+	if m.Epochs <= 0 {
+		m.Epochs = 100
+	}
+
+	// Initialize weights randomly, rows include the bias
+	_, nx := X.Dims()
+	_, ny := Y.Dims()
+	rng := rand.New(rand.NewSource(m.Seed))
+	m.w1 = initWeights(nx+1, m.Hidden, m.Init, rng)
+	m.w2 = initWeights(m.Hidden+1, ny, m.Init, rng)
+	m.classes = nil
+
+	// Gradient descent, one step per batch
+	opt := m.Optimizer
+	if opt == nil {
+		opt = &optimizer.SGD{LR: m.LR}
+	}
+	for i := 0; i < m.Epochs; i++ {
+		for _, batch := range optimizer.Batches(utils.MatRows(X), m.BatchSize, m.Shuffle, rng) {
+			w1Grad, w2Grad := m.Backprop(optimizer.BatchRows(X, batch), optimizer.BatchRows(Y, batch))
+			opt.Step(m.w1, w1Grad)
+			opt.Step(m.w2, w2Grad)
+		}
+		if m.Verbose {
+			fmt.Printf("Epoch %d: loss = %f\n", i, m.Loss(X, Y))
+		}
+	}
+}
+
+// Compute the gradients of the loss with respect to both weight matrices
+// Python:
+//
+//	def back(X, Y, y_hat, w2, h):
+//	  w2_gradient = np.matmul(prepend_bias(h).T, (y_hat - Y)) / X.shape[0]
+//	  w1_gradient = np.matmul(prepend_bias(X).T, np.matmul(y_hat - Y,
+//	      w2[1:].T) * sigmoid_gradient(h)) / X.shape[0]
+//	  return (w1_gradient, w2_gradient)
+func (m *NeuralNetwork) Backprop(X, Y *mat.Dense) (*mat.Dense, *mat.Dense) {
+
+	// Forward pass, keeping the hidden layer
+	X1 := utils.PrependBias(X)
+	h := mat.NewDense(utils.MatRows(X1), m.Hidden, nil)
+	h.Mul(X1, m.w1)
+	h.Apply(func(i, j int, v float64) float64 {
+		return utils.Sigmoid(v)
+	}, h)
+	h1 := utils.PrependBias(h)
+	yHat := mat.NewDense(utils.MatRows(h1), utils.MatCols(m.w2), nil)
+	yHat.Mul(h1, m.w2)
+	yHat = SoftMax(yHat)
+
+	// Error at the output: y_hat - Y
+	n := float64(utils.MatRows(X))
+	deltas := mat.NewDense(utils.MatRows(yHat), utils.MatCols(yHat), nil)
+	deltas.Sub(yHat, Y)
+
+	// Gradient for output weights
+	w2Grad := mat.NewDense(utils.MatRows(m.w2), utils.MatCols(m.w2), nil)
+	w2Grad.Mul(h1.T(), deltas)
+	w2Grad.Scale(1/n, w2Grad)
+
+	// Propagate error back through w2 (without the bias row), and
+	// through the sigmoid: sigmoid_gradient(h) = h * (1 - h)
+	w2NoBias := m.w2.Slice(1, utils.MatRows(m.w2), 0, utils.MatCols(m.w2))
+	hGrad := mat.NewDense(utils.MatRows(h), m.Hidden, nil)
+	hGrad.Mul(deltas, w2NoBias.T())
+	hGrad.Apply(func(i, j int, v float64) float64 {
+		a := h.At(i, j)
+		return v * a * (1 - a)
+	}, hGrad)
+
+	// Gradient for hidden weights
+	w1Grad := mat.NewDense(utils.MatRows(m.w1), utils.MatCols(m.w1), nil)
+	w1Grad.Mul(X1.T(), hGrad)
+	w1Grad.Scale(1/n, w1Grad)
+	return w1Grad, w2Grad
+}
+
+// Forward prediction, returns one row of class probabilities per instance
+func (m *NeuralNetwork) Forward(X *mat.Dense) *mat.Dense {
+	return Forward(X, m.w1, m.w2)
+}
+
+// Cross-entropy loss of predictions vs. one-hot encoded labels
+func (m *NeuralNetwork) Loss(X, Y *mat.Dense) float64 {
+	return Loss(Y, m.Forward(X))
+}
+
+// Classify, returns column vector with the most likely class for each row
+func (m *NeuralNetwork) Classify(X *mat.Dense) *mat.Dense {
+	return Classify(X, m.w1, m.w2)
+}
+
+// Fit the network to training data, for the utils.Estimator interface. Y
+// is a column of class labels, which are one-hot encoded for training.
+func (m *NeuralNetwork) Fit(X, Y *mat.Dense) error {
+	if err := utils.CheckXY(X, Y); err != nil {
+		return err
+	}
+	classes := utils.Classes(Y)
+	m.Train(X, utils.OneHot(Y, classes))
+	m.classes = classes
+	return nil
+}
+
+// Predict the class label for each row, or the column number of the class
+// if trained on one-hot encoded labels using Train
+func (m *NeuralNetwork) Predict(X *mat.Dense) *mat.Dense {
+	if m.classes == nil {
+		return m.Classify(X)
+	}
+	return utils.ArgMaxLabels(m.Forward(X), m.classes)
+}
+
+// Predict the probability of each class, one column per class
+func (m *NeuralNetwork) PredictProba(X *mat.Dense) *mat.Dense {
+	return m.Forward(X)
+}
+
+// Create a matrix of random initial weights. Scale depends on the method:
+// xavier uses sqrt(2 / (rows + cols)), he uses sqrt(2 / rows), normal uses
+// sqrt(1 / rows) as in the book, and uniform draws from +/- sqrt(6 / (rows +
+// cols)).
+func initWeights(rows, cols int, method string, rng *rand.Rand) *mat.Dense {
+	w := mat.NewDense(rows, cols, nil)
+	w.Apply(func(i, j int, v float64) float64 {
+		switch method {
+		case "he":
+			return rng.NormFloat64() * math.Sqrt(2/float64(rows))
+		case "normal":
+			return rng.NormFloat64() * math.Sqrt(1/float64(rows))
+		case "uniform":
+			lim := math.Sqrt(6 / float64(rows+cols))
+			return (rng.Float64()*2 - 1) * lim
+		case "xavier", "":
+			return rng.NormFloat64() * math.Sqrt(2/float64(rows+cols))
+		default:
+			panic("initWeights: invalid initialization " + method)
+		}
+	}, w)
+	return w
+}
@@ -22,18 +22,20 @@ func Forward(X, w1, w2 *mat.Dense) *mat.Dense {
 	// Make a copy of X with bias prepended
 	X1 := utils.PrependBias(X)
 
-	// Multiply X1 by w1
+	// Multiply X1 by w1, and apply the sigmoid to get the hidden layer
 	h1 := mat.NewDense(utils.MatRows(X1), utils.MatCols(w1), nil)
 	h1.Mul(X1, w1)
+	h1.Apply(func(i, j int, v float64) float64 {
+		return utils.Sigmoid(v)
+	}, h1)
 
 	// Prepend bias to the new hidden layer
 	h1a := utils.PrependBias(h1)
 
-	// Multiply hidden by w2
+	// Multiply hidden by w2, softmax turns the result into probabilities
 	y := mat.NewDense(utils.MatRows(h1a), utils.MatCols(w2), nil)
 	y.Mul(h1a, w2)
-
-	return y
+	return SoftMax(y)
 }
 
 // Loss function
@@ -48,7 +50,7 @@ func Loss(Y, yHat *mat.Dense) float64 {
 	logs := mat.NewDense(nr, nc, nil)
 	logs.Apply(func(i, j int, v float64) float64 {
 		return math.Log(v)
-	}, logs)
+	}, yHat)
 
 	// Element-wise multiply Y by y-hat logs
 	logs.MulElem(Y, logs)
@@ -60,7 +62,7 @@ func Loss(Y, yHat *mat.Dense) float64 {
 			loss += logs.At(r, c)
 		}
 	}
-	return loss / float64(nr)
+	return -loss / float64(nr)
 }
 
 // Classify, taking highest probability for each instance
@@ -3,6 +3,7 @@
 package neural_net
 
 import (
+	"math"
 	"testing"
 
 	"gonum.org/v1/gonum/mat"
@@ -20,3 +21,91 @@ func TestNeuralNet(t *testing.T) {
 	}
 
 }
+
+// Test training a network with backpropagation
+func TestNeuralNetworkTrain(t *testing.T) {
+
+	// Two classes that are not linearly separable (XOR), one-hot encoded
+	X := mat.NewDense(4, 2, []float64{0, 0, 0, 1, 1, 0, 1, 1})
+	Y := mat.NewDense(4, 2, []float64{1, 0, 0, 1, 0, 1, 1, 0})
+
+	// Check the gradients against numerical estimates
+	m := NeuralNetwork{Hidden: 3, Epochs: 1, Seed: 1}
+	m.Train(X, Y)
+	g1, _ := m.Backprop(X, Y)
+	eps := 1e-6
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			w := m.w1.At(i, j)
+			m.w1.Set(i, j, w+eps)
+			l1 := m.Loss(X, Y)
+			m.w1.Set(i, j, w-eps)
+			l2 := m.Loss(X, Y)
+			m.w1.Set(i, j, w)
+			if math.Abs((l1-l2)/(2*eps)-g1.At(i, j)) > 1e-6 {
+				t.Errorf("Gradient w1[%d,%d] = %f, numerical %f", i, j, g1.At(i, j), (l1-l2)/(2*eps))
+			}
+		}
+	}
+
+	// Train until the network classifies all four points correctly
+	m = NeuralNetwork{Hidden: 8, LR: 2, Epochs: 3000, Seed: 1}
+	m.Train(X, Y)
+	preds := m.Classify(X)
+	expect := mat.NewDense(4, 1, []float64{0, 1, 1, 0})
+	if !mat.Equal(preds, expect) {
+		t.Error("Neural network failed to learn XOR")
+	}
+
+	// Probabilities should add up to 1 for each row
+	probs := m.Forward(X)
+	for r := 0; r < 4; r++ {
+		if math.Abs(mat.Sum(probs.RowView(r))-1) > 1e-9 {
+			t.Error("Forward probabilities do not add up to 1")
+		}
+	}
+}
+
+// Test multilayer perceptron gradients and training
+func TestMLP(t *testing.T) {
+
+	// Check gradients against numerical estimates, for each type of
+	// output layer and a mix of hidden activations
+	X := mat.NewDense(4, 2, []float64{0, 0, 0, 1, 1, 0, 1, 1})
+	Y := mat.NewDense(4, 2, []float64{1, 0, 0, 1, 0, 1, 1, 0})
+	for _, out := range []Activation{ActSoftmax, ActSigmoid, ActLinear, ActTanh} {
+		m := MLP{Layers: []Dense{{Units: 4, Activation: ActTanh},
+			{Units: 3, Activation: ActLeakyReLU}, {Units: 2, Activation: out}},
+			Epochs: 1, Seed: 2}
+		m.Train(X, Y)
+		grads := m.Backprop(X, Y)
+		eps := 1e-6
+		for l, layer := range m.Layers {
+			nr, nc := layer.w.Dims()
+			for i := 0; i < nr; i++ {
+				for j := 0; j < nc; j++ {
+					w := layer.w.At(i, j)
+					layer.w.Set(i, j, w+eps)
+					l1 := m.Loss(X, Y)
+					layer.w.Set(i, j, w-eps)
+					l2 := m.Loss(X, Y)
+					layer.w.Set(i, j, w)
+					num := (l1 - l2) / (2 * eps)
+					if math.Abs(num-grads[l].At(i, j)) > 1e-5 {
+						t.Errorf("%s: gradient layer %d [%d,%d] = %f, numerical %f", out, l, i, j, grads[l].At(i, j), num)
+					}
+				}
+			}
+		}
+	}
+
+	// Train a deeper network on XOR
+	m := MLP{Layers: []Dense{{Units: 8, Activation: ActTanh},
+		{Units: 8, Activation: ActReLU}, {Units: 2, Activation: ActSoftmax}},
+		LR: .5, Epochs: 2000, Seed: 1}
+	m.Train(X, Y)
+	expect := mat.NewDense(4, 1, []float64{0, 1, 1, 0})
+	if !mat.Equal(m.Classify(X), expect) {
+		t.Error("MLP failed to learn XOR")
+	}
+}
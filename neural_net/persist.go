@@ -0,0 +1,87 @@
+// Saving and loading trained neural networks, as JSON if the file name
+// ends in .json, otherwise in binary gob format
+
+package neural_net
+
+import (
+	"mlcode/utils"
+)
+
+// Versions of the saved data of each model, to be increased when it
+// changes in an incompatible way
+const (
+	networkVersion = 1
+	mlpVersion     = 1
+)
+
+// Saved data for a network with one hidden layer
+type networkData struct {
+	Hidden  int
+	W1, W2  utils.MatrixData
+	Classes []float64
+}
+
+// Save the trained network to a file
+func (m *NeuralNetwork) Save(filename string) error {
+	d := networkData{Hidden: m.Hidden, W1: utils.ToMatrixData(m.w1),
+		W2: utils.ToMatrixData(m.w2), Classes: m.classes}
+	return utils.SaveModel(filename, "NeuralNetwork", networkVersion, d)
+}
+
+// Load a trained network from a file, replacing the weights and classes
+func (m *NeuralNetwork) Load(filename string) error {
+	var d networkData
+	if err := utils.LoadModel(filename, "NeuralNetwork", networkVersion, &d); err != nil {
+		return err
+	}
+	w1, err := d.W1.Dense()
+	if err != nil {
+		return err
+	}
+	w2, err := d.W2.Dense()
+	if err != nil {
+		return err
+	}
+	m.Hidden, m.w1, m.w2, m.classes = d.Hidden, w1, w2, d.Classes
+	return nil
+}
+
+// Saved data for one layer of a multilayer perceptron
+type layerData struct {
+	Units      int
+	Activation Activation
+	W          utils.MatrixData
+}
+
+// Saved data for a multilayer perceptron
+type mlpData struct {
+	Layers  []layerData
+	Classes []float64
+}
+
+// Save the trained network to a file
+func (m *MLP) Save(filename string) error {
+	d := mlpData{Classes: m.classes}
+	for _, l := range m.Layers {
+		d.Layers = append(d.Layers, layerData{Units: l.Units, Activation: l.Activation, W: utils.ToMatrixData(l.w)})
+	}
+	return utils.SaveModel(filename, "MLP", mlpVersion, d)
+}
+
+// Load a trained network from a file, replacing the layers and classes
+func (m *MLP) Load(filename string) error {
+	var d mlpData
+	if err := utils.LoadModel(filename, "MLP", mlpVersion, &d); err != nil {
+		return err
+	}
+	layers := []Dense{}
+	for _, l := range d.Layers {
+		w, err := l.W.Dense()
+		if err != nil {
+			return err
+		}
+		layers = append(layers, Dense{Units: l.Units, Activation: l.Activation, w: w})
+	}
+	m.Layers, m.classes = layers, d.Classes
+	return nil
+}
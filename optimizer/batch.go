@@ -0,0 +1,92 @@
+// Mini-batches for training
+
+package optimizer
+
+import (
+	"math/rand"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Divide row numbers 0..n-1 into batches of the given size (the last
+// batch may be smaller), optionally shuffled. A batch size of zero or
+// less means one batch with all the rows.
+func Batches(n, size int, shuffle bool, rng *rand.Rand) [][]int {
+
+	// List of row numbers, shuffled if required
+	rows := make([]int, n)
+	for i := 0; i < n; i++ {
+		rows[i] = i
+	}
+	if shuffle {
+		rng.Shuffle(n, func(i, j int) {
+			rows[i], rows[j] = rows[j], rows[i]
+		})
+	}
+
+	// Cut into batches
+	if size <= 0 || size > n {
+		size = n
+	}
+	batches := [][]int{}
+	for i := 0; i < n; i += size {
+		end := i + size
+		if end > n {
+			end = n
+		}
+		batches = append(batches, rows[i:end])
+	}
+	return batches
+}
+
+// Extract the rows for a batch from a matrix. If the batch is all rows in
+// their original order, returns the matrix itself rather than a copy.
+func BatchRows(m *mat.Dense, batch []int) *mat.Dense {
+	nr, nc := m.Dims()
+	inOrder := len(batch) == nr
+	for i := 0; inOrder && i < len(batch); i++ {
+		inOrder = batch[i] == i
+	}
+	if inOrder {
+		return m
+	}
+	res := mat.NewDense(len(batch), nc, nil)
+	for i, r := range batch {
+		res.SetRow(i, m.RawRowView(r))
+	}
+	return res
+}
+
+// Run one pass (epoch) through the training data in mini-batches, updating
+// the weights w after each batch, using a function that calculates the
+// gradient for a batch
+func Epoch(opt Optimizer, w, X, Y *mat.Dense, batchSize int, shuffle bool, rng *rand.Rand,
+	gradient func(X, Y *mat.Dense) *mat.Dense) {
+	nr, _ := X.Dims()
+	for _, batch := range Batches(nr, batchSize, shuffle, rng) {
+		opt.Step(w, gradient(BatchRows(X, batch), BatchRows(Y, batch)))
+	}
+}
+
+// Extract the weights for the rows of a batch, nil if there are no weights
+func BatchWeights(weights []float64, batch []int) []float64 {
+	if weights == nil {
+		return nil
+	}
+	res := make([]float64, len(batch))
+	for i, r := range batch {
+		res[i] = weights[r]
+	}
+	return res
+}
+
+// Same as Epoch, but with a weight for each row of the training data,
+// which is passed to the gradient function along with the rows of each
+// batch. The weights may be nil, meaning all rows count the same.
+func EpochWeighted(opt Optimizer, w, X, Y *mat.Dense, weights []float64, batchSize int, shuffle bool,
+	rng *rand.Rand, gradient func(X, Y *mat.Dense, weights []float64) *mat.Dense) {
+	nr, _ := X.Dims()
+	for _, batch := range Batches(nr, batchSize, shuffle, rng) {
+		opt.Step(w, gradient(BatchRows(X, batch), BatchRows(Y, batch), BatchWeights(weights, batch)))
+	}
+}
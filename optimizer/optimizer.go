@@ -0,0 +1,194 @@
+// optimizer.go
+//
+// Gradient-based optimizers shared by the models, to update a matrix of
+// weights given its gradient. Each optimizer keeps its own state (e.g.,
+// velocity) for every weight matrix it updates, so one optimizer object
+// can be used for all the weight matrices of a model, but should not be
+// shared between models.
+//
+// Sample usage:
+//
+//	opt := &optimizer.Adam{LR: .001}
+//	for _, batch := range optimizer.Batches(nrows, 32, true, rng) {
+//	    grads := m.Gradient(optimizer.BatchRows(X, batch), optimizer.BatchRows(Y, batch))
+//	    opt.Step(w, grads)
+//	}
+
+package optimizer
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Interface for all optimizers: update the weights w in-place, given the
+// gradient of the loss with respect to those weights
+type Optimizer interface {
+	Step(w, grad *mat.Dense)
+}
+
+// Plain (stochastic) gradient descent: w -= lr * grad
+type SGD struct {
+	LR float64 // learning rate, default .001
+}
+
+func (o *SGD) Step(w, grad *mat.Dense) {
+	lr := o.Rate()
+	w.Apply(func(i, j int, v float64) float64 {
+		return v - lr*grad.At(i, j)
+	}, w)
+}
+
+// Learning rate of each step, with the default applied
+func (o *SGD) Rate() float64 {
+	return orDefault(o.LR, .001)
+}
+
+// Gradient descent with momentum, keeps a velocity for each weight:
+// v = beta * v + lr * grad, w -= v
+type Momentum struct {
+	LR       float64 // learning rate, default .001
+	Beta     float64 // momentum, default .9
+	velocity map[*mat.Dense]*mat.Dense
+}
+
+func (o *Momentum) Step(w, grad *mat.Dense) {
+	lr := orDefault(o.LR, .001)
+	beta := orDefault(o.Beta, .9)
+	v := state(&o.velocity, w)
+	v.Apply(func(i, j int, x float64) float64 {
+		return beta*x + lr*grad.At(i, j)
+	}, v)
+	w.Sub(w, v)
+}
+
+// Nesterov accelerated gradient, which applies the momentum step to the
+// "look-ahead" position: v = beta * v + lr * grad, w -= beta * v + lr * grad
+type Nesterov struct {
+	LR       float64 // learning rate, default .001
+	Beta     float64 // momentum, default .9
+	velocity map[*mat.Dense]*mat.Dense
+}
+
+func (o *Nesterov) Step(w, grad *mat.Dense) {
+	lr := orDefault(o.LR, .001)
+	beta := orDefault(o.Beta, .9)
+	v := state(&o.velocity, w)
+	v.Apply(func(i, j int, x float64) float64 {
+		return beta*x + lr*grad.At(i, j)
+	}, v)
+	w.Apply(func(i, j int, x float64) float64 {
+		return x - beta*v.At(i, j) - lr*grad.At(i, j)
+	}, w)
+}
+
+// RMSProp, which divides the learning rate by a moving average of the
+// squared gradients: s = rho * s + (1 - rho) * grad^2,
+// w -= lr * grad / (sqrt(s) + eps)
+type RMSProp struct {
+	LR      float64 // learning rate, default .001
+	Rho     float64 // decay rate of the moving average, default .9
+	Eps     float64 // small number to avoid division by zero, default 1e-8
+	squares map[*mat.Dense]*mat.Dense
+}
+
+func (o *RMSProp) Step(w, grad *mat.Dense) {
+	lr := orDefault(o.LR, .001)
+	rho := orDefault(o.Rho, .9)
+	eps := orDefault(o.Eps, 1e-8)
+	s := state(&o.squares, w)
+	s.Apply(func(i, j int, x float64) float64 {
+		g := grad.At(i, j)
+		return rho*x + (1-rho)*g*g
+	}, s)
+	w.Apply(func(i, j int, x float64) float64 {
+		return x - lr*grad.At(i, j)/(math.Sqrt(s.At(i, j))+eps)
+	}, w)
+}
+
+// Adam, which keeps moving averages of both the gradients and the squared
+// gradients, with bias correction for the first few steps
+type Adam struct {
+	LR      float64 // learning rate, default .001
+	Beta1   float64 // decay rate for the gradients, default .9
+	Beta2   float64 // decay rate for the squared gradients, default .999
+	Eps     float64 // small number to avoid division by zero, default 1e-8
+	moments map[*mat.Dense]*adamState
+}
+
+// Moving averages and step count for one weight matrix
+type adamState struct {
+	m, v *mat.Dense
+	t    int
+}
+
+func (o *Adam) Step(w, grad *mat.Dense) {
+	o.step(w, grad, 0)
+}
+
+// Adam step, with optional weight decay applied directly to the weights
+func (o *Adam) step(w, grad *mat.Dense, decay float64) {
+
+	// Get settings and the state for this weight matrix
+	lr := orDefault(o.LR, .001)
+	b1 := orDefault(o.Beta1, .9)
+	b2 := orDefault(o.Beta2, .999)
+	eps := orDefault(o.Eps, 1e-8)
+	if o.moments == nil {
+		o.moments = map[*mat.Dense]*adamState{}
+	}
+	st := o.moments[w]
+	if st == nil {
+		nr, nc := w.Dims()
+		st = &adamState{m: mat.NewDense(nr, nc, nil), v: mat.NewDense(nr, nc, nil)}
+		o.moments[w] = st
+	}
+	st.t++
+
+	// Update moving averages, and weights using bias-corrected averages
+	c1 := 1 - math.Pow(b1, float64(st.t))
+	c2 := 1 - math.Pow(b2, float64(st.t))
+	w.Apply(func(i, j int, x float64) float64 {
+		g := grad.At(i, j)
+		m := b1*st.m.At(i, j) + (1-b1)*g
+		v := b2*st.v.At(i, j) + (1-b2)*g*g
+		st.m.Set(i, j, m)
+		st.v.Set(i, j, v)
+		return x - lr*(m/c1)/(math.Sqrt(v/c2)+eps) - lr*decay*x
+	}, w)
+}
+
+// AdamW, i.e., Adam with weight decay applied directly to the weights
+// rather than through the gradient
+type AdamW struct {
+	Adam
+	Decay float64 // weight decay, default .01
+}
+
+func (o *AdamW) Step(w, grad *mat.Dense) {
+	o.step(w, grad, orDefault(o.Decay, .01))
+}
+
+// Get the state matrix for a set of weights, creating it (as zeros) if
+// this is the first step for these weights
+func state(states *map[*mat.Dense]*mat.Dense, w *mat.Dense) *mat.Dense {
+	if *states == nil {
+		*states = map[*mat.Dense]*mat.Dense{}
+	}
+	s := (*states)[w]
+	if s == nil {
+		nr, nc := w.Dims()
+		s = mat.NewDense(nr, nc, nil)
+		(*states)[w] = s
+	}
+	return s
+}
+
+// Use a default value for a setting that has not been set
+func orDefault(v, def float64) float64 {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
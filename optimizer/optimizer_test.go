@@ -0,0 +1,66 @@
+// Unit tests for optimizers and mini-batches
+
+package optimizer
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Each optimizer should find the minimum of a simple quadratic,
+// f(w) = sum((w - target)^2)
+func TestOptimizers(t *testing.T) {
+	target := mat.NewDense(2, 2, []float64{1, -2, 3, .5})
+	opts := map[string]Optimizer{
+		"sgd":      &SGD{LR: .1},
+		"momentum": &Momentum{LR: .05},
+		"nesterov": &Nesterov{LR: .05},
+		"rmsprop":  &RMSProp{LR: .01},
+		"adam":     &Adam{LR: .05},
+		"adamw":    &AdamW{Adam: Adam{LR: .05}, Decay: 1e-6},
+	}
+	for name, opt := range opts {
+		w := mat.NewDense(2, 2, nil)
+		grad := mat.NewDense(2, 2, nil)
+		for i := 0; i < 2000; i++ {
+			grad.Sub(w, target)
+			grad.Scale(2, grad)
+			opt.Step(w, grad)
+		}
+		if !mat.EqualApprox(w, target, .01) {
+			t.Errorf("%s did not converge: %v", name, mat.Formatted(w))
+		}
+	}
+}
+
+// Batches should cover all rows exactly once
+func TestBatches(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	batches := Batches(10, 3, true, rng)
+	if len(batches) != 4 || len(batches[3]) != 1 {
+		t.Errorf("Wrong batch sizes: %v", batches)
+	}
+	rows := []int{}
+	for _, b := range batches {
+		rows = append(rows, b...)
+	}
+	sort.Ints(rows)
+	for i := 0; i < 10; i++ {
+		if rows[i] != i {
+			t.Errorf("Batches do not cover all rows: %v", batches)
+			break
+		}
+	}
+
+	// Whole matrix in order should not be copied
+	X := mat.NewDense(3, 1, []float64{1, 2, 3})
+	if BatchRows(X, Batches(3, 0, false, rng)[0]) != X {
+		t.Error("BatchRows copied whole matrix")
+	}
+	if BatchRows(X, []int{2, 0}).At(0, 0) != 3 {
+		t.Error("BatchRows extracted wrong rows")
+	}
+}
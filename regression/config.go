@@ -0,0 +1,202 @@
+// Checking the settings of the regression models, which are exported
+// fields of each model, zero values meaning the default. Fit returns an
+// error for invalid settings, and Train panics.
+//
+// Sample usage:
+//
+//	m := LinearRegression{Solver: SolverQR}
+//	err := m.Fit(X, Y)
+//	fmt.Println(m.Intercept(), m.Coefficients())
+
+package regression
+
+import (
+	"fmt"
+	"mlcode/utils"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Check the settings for linear regression
+func (m *LinearRegression) Validate() error {
+	if err := checkTraining(m.LR, m.Tol, m.Iterations, m.BatchSize); err != nil {
+		return fmt.Errorf("LinearRegression: %w", err)
+	}
+	switch m.Solver {
+	case "", SolverGD, SolverNormal, SolverQR, SolverSVD, SolverCD:
+	case SolverNewton, SolverLBFGS:
+		return fmt.Errorf("LinearRegression: %s solver is only for logistic regression", m.Solver)
+	default:
+		return fmt.Errorf("LinearRegression: unknown solver %q", m.Solver)
+	}
+	if _, err := newPenalty(m.Penalty, m.Alpha, m.L1Ratio, nil); err != nil {
+		return fmt.Errorf("LinearRegression: %w", err)
+	}
+	if m.Penalty == PenaltyL1 || m.Penalty == PenaltyElasticNet {
+		if m.Solver == SolverNormal || m.Solver == SolverQR || m.Solver == SolverSVD {
+			return fmt.Errorf("LinearRegression: %s penalty needs the cd or gd solver", m.Penalty)
+		}
+		if err := checkL1Optimizer(m.Optimizer); err != nil && m.Solver != SolverCD {
+			return fmt.Errorf("LinearRegression: %w", err)
+		}
+	}
+	return nil
+}
+
+// Check the settings for logistic regression
+func (m *LogisticRegression) Validate() error {
+	if err := checkTraining(m.LR, m.Tol, m.Iterations, m.BatchSize); err != nil {
+		return fmt.Errorf("LogisticRegression: %w", err)
+	}
+	if err := checkLogisticSolver(m.Solver); err != nil {
+		return fmt.Errorf("LogisticRegression: %w", err)
+	}
+	if _, err := newPenalty(m.Penalty, m.Alpha, m.L1Ratio, nil); err != nil {
+		return fmt.Errorf("LogisticRegression: %w", err)
+	}
+	if m.Penalty == PenaltyL1 || m.Penalty == PenaltyElasticNet {
+		if m.Solver == SolverNewton || m.Solver == SolverLBFGS {
+			return fmt.Errorf("LogisticRegression: %s penalty needs the gd solver", m.Penalty)
+		}
+		if err := checkL1Optimizer(m.Optimizer); err != nil {
+			return fmt.Errorf("LogisticRegression: %w", err)
+		}
+	}
+	if err := utils.CheckClassWeight(m.ClassWeight); err != nil {
+		return fmt.Errorf("LogisticRegression: %w", err)
+	}
+	return nil
+}
+
+// Check the settings for multi-class logistic regression
+func (m *MultiLogRegression) Validate() error {
+	if err := checkTraining(m.LR, m.Tol, m.Iterations, m.BatchSize); err != nil {
+		return fmt.Errorf("MultiLogRegression: %w", err)
+	}
+	if err := checkLogisticSolver(m.Solver); err != nil {
+		return fmt.Errorf("MultiLogRegression: %w", err)
+	}
+	if err := utils.CheckClassWeight(m.ClassWeight); err != nil {
+		return fmt.Errorf("MultiLogRegression: %w", err)
+	}
+	return nil
+}
+
+// Check the solver for logistic regression
+func checkLogisticSolver(s Solver) error {
+	switch s {
+	case "", SolverGD, SolverNewton, SolverLBFGS:
+		return nil
+	case SolverNormal, SolverQR, SolverSVD, SolverCD:
+		return fmt.Errorf("%s solver is only for linear regression", s)
+	}
+	return fmt.Errorf("unknown solver %q", s)
+}
+
+// Defaults for training a logistic model, for settings that are not set,
+// leaving the settings themselves unchanged. Second order solvers need
+// fewer iterations, and can reach a smaller tolerance.
+func logisticDefaults(solver Solver, lr, tol float64, iterations int) (float64, float64, int) {
+	secondOrder := solver == SolverNewton || solver == SolverLBFGS
+	if iterations <= 0 {
+		iterations = utils.IfThenElse(secondOrder, 100, 1000)
+	}
+	if lr <= 0 {
+		lr = .001
+	}
+	if tol <= 0 {
+		tol = utils.IfThenElse(secondOrder, 1e-8, .001)
+	}
+	return lr, tol, iterations
+}
+
+// Check settings for gradient descent, zero means default
+func checkTraining(lr, tol float64, iterations, batchSize int) error {
+	if lr < 0 {
+		return fmt.Errorf("learning rate must not be negative, got %v", lr)
+	}
+	if tol < 0 {
+		return fmt.Errorf("tolerance must not be negative, got %v", tol)
+	}
+	if iterations < 0 {
+		return fmt.Errorf("iterations must not be negative, got %d", iterations)
+	}
+	if batchSize < 0 {
+		return fmt.Errorf("batch size must not be negative, got %d", batchSize)
+	}
+	return nil
+}
+
+// Find the intercept column of X, i.e., the first column of all ones, or
+// -1 if there is none
+func findIntercept(X *mat.Dense) int {
+	for j, free := range interceptCols(X) {
+		if free {
+			return j
+		}
+	}
+	return -1
+}
+
+// Copy of the weights of a trained model, nil if not trained
+func copyWeights(w *mat.Dense) *mat.Dense {
+	if w == nil {
+		return nil
+	}
+	return mat.DenseCopyOf(w)
+}
+
+// Rows of the weights other than the intercept row, nil if not trained or
+// there are no other rows
+func withoutIntercept(w *mat.Dense, icept int) *mat.Dense {
+	if w == nil {
+		return nil
+	}
+	nr, nc := w.Dims()
+	rows := []int{}
+	for i := 0; i < nr; i++ {
+		if i != icept {
+			rows = append(rows, i)
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	res := mat.NewDense(len(rows), nc, nil)
+	for i, r := range rows {
+		res.SetRow(i, w.RawRowView(r))
+	}
+	return res
+}
+
+// Intercept row of the weights, one value per column, zeros if there is no
+// intercept, nil if not trained
+func interceptRow(w *mat.Dense, icept int) []float64 {
+	if w == nil {
+		return nil
+	}
+	_, nc := w.Dims()
+	res := make([]float64, nc)
+	if icept >= 0 {
+		copy(res, w.RawRowView(icept))
+	}
+	return res
+}
+
+// Coefficients other than the intercept, for a single column of weights
+func coefficients(w *mat.Dense, icept int) []float64 {
+	c := withoutIntercept(w, icept)
+	if c == nil {
+		return nil
+	}
+	return mat.Col(nil, 0, c)
+}
+
+// Intercept for a single column of weights, zero if none or not trained
+func intercept(w *mat.Dense, icept int) float64 {
+	r := interceptRow(w, icept)
+	if r == nil {
+		return 0
+	}
+	return r[0]
+}
@@ -0,0 +1,69 @@
+// Unit tests for model settings and accessors
+
+package regression
+
+import (
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+	"mlcode/utils"
+)
+
+// Test that invalid settings are rejected, and that settings can be given
+// in a struct literal
+func TestConfig(t *testing.T) {
+	if (&LinearRegression{LR: -1}).Validate() == nil {
+		t.Error("Negative learning rate accepted")
+	}
+	if (&LinearRegression{Solver: "magic"}).Validate() == nil {
+		t.Error("Unknown solver accepted")
+	}
+	if (&LogisticRegression{Penalty: PenaltyElasticNet, L1Ratio: 1.5}).Validate() == nil {
+		t.Error("Invalid L1Ratio accepted")
+	}
+	m := MultiLogRegression{Iterations: -5}
+	if m.Fit(mat.NewDense(2, 1, []float64{1, 2}), mat.NewDense(2, 1, []float64{0, 1})) == nil {
+		t.Error("Negative iterations accepted")
+	}
+	m = MultiLogRegression{LR: .1, Iterations: 100}
+	if err := m.Validate(); err != nil || m.LR != .1 || m.Iterations != 100 {
+		t.Errorf("Valid settings not used: %v", err)
+	}
+}
+
+// Test reading the coefficients and intercept of trained models
+func TestAccessors(t *testing.T) {
+
+	// y = 3 + 2 * x, intercept in the second column
+	X := mat.NewDense(4, 2, []float64{0, 1, 1, 1, 2, 1, 3, 1})
+	Y := mat.NewDense(4, 1, []float64{3, 5, 7, 9})
+	m := LinearRegression{Solver: SolverQR}
+	if m.Coefficients() != nil || m.Weights() != nil {
+		t.Error("Untrained model should have no coefficients")
+	}
+	m.Fit(X, Y)
+	if !utils.Close(m.Intercept(), 3) || !utils.Same(m.Coefficients(), []float64{2}) {
+		t.Errorf("Wrong intercept %f or coefficients %v", m.Intercept(), m.Coefficients())
+	}
+	m.Weights().Set(0, 0, 100) // a copy, so the model is unchanged
+	if !utils.Close(m.Coefficients()[0], 2) {
+		t.Error("Weights should return a copy")
+	}
+
+	// Without an intercept column, the intercept is zero
+	m.Fit(utils.ExtractCols(X, 0, 0), Y)
+	if m.Intercept() != 0 || len(m.Coefficients()) != 1 {
+		t.Error("Model without intercept column has an intercept")
+	}
+
+	// Multi-class model has one intercept and column of coefficients per
+	// class
+	Xc := mat.NewDense(6, 2, []float64{1, 1, 1, 2, 1, 3, 1, 7, 1, 8, 1, 9})
+	Yc := mat.NewDense(6, 1, []float64{0, 0, 0, 1, 1, 2})
+	ml := MultiLogRegression{LR: .1, Iterations: 100}
+	ml.Fit(Xc, Yc)
+	r, c := ml.Coefficients().Dims()
+	if r != 1 || c != 3 || len(ml.Intercept()) != 3 || len(ml.Classes()) != 3 {
+		t.Error("Multi-class model has wrong shape of coefficients")
+	}
+}
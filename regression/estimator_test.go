@@ -0,0 +1,79 @@
+// Unit tests for the Estimator interface on regression models
+
+package regression
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+	"mlcode/utils"
+)
+
+// Test fitting and predicting through the common interface
+func TestEstimators(t *testing.T) {
+
+	// Data with an intercept column, two classes
+	X := mat.NewDense(6, 2, []float64{1, 1, 1, 2, 1, 3, 1, 7, 1, 8, 1, 9})
+	Y := mat.NewDense(6, 1, []float64{0, 0, 0, 1, 1, 1})
+
+	// All models should accept the same data, and predict one value per row
+	models := map[string]utils.Estimator{
+		"linear":   &LinearRegression{},
+		"logistic": &LogisticRegression{Iterations: 5000, LR: .1},
+		"multilog": &MultiLogRegression{Iterations: 5000, LR: .1},
+	}
+	for name, m := range models {
+		if err := m.Fit(X, Y); err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		preds := m.Predict(X)
+		if utils.MatRows(preds) != 6 || utils.MatCols(preds) != 1 {
+			t.Errorf("%s: wrong shape of predictions", name)
+		}
+	}
+
+	// Classifiers should predict the labels and probabilities
+	for _, name := range []string{"logistic", "multilog"} {
+		m := models[name].(utils.Classifier)
+		if !mat.Equal(m.Predict(X), Y) {
+			t.Errorf("%s: wrong predictions", name)
+		}
+		if utils.MatCols(m.PredictProba(X)) != 2 {
+			t.Errorf("%s: probabilities should have one column per class", name)
+		}
+	}
+
+	// Invalid data should be rejected
+	if (&LogisticRegression{}).Fit(X, mat.NewDense(6, 1, []float64{0, 1, 2, 0, 1, 2})) == nil {
+		t.Error("Logistic regression accepted labels other than 0/1")
+	}
+	if (&LinearRegression{}).Fit(X, mat.NewDense(5, 1, nil)) == nil {
+		t.Error("Linear regression accepted mismatched rows")
+	}
+}
+
+// Test saving and loading a trained model
+func TestSaveModel(t *testing.T) {
+	X := mat.NewDense(6, 2, []float64{1, 1, 1, 2, 1, 3, 1, 7, 1, 8, 1, 9})
+	Y := mat.NewDense(6, 1, []float64{3, 3, 3, 5, 5, 4})
+	m := MultiLogRegression{Iterations: 100, LR: .1, Multinomial: true}
+	m.Fit(X, Y)
+	for _, name := range []string{"model.json", "model.gob"} {
+		filename := filepath.Join(t.TempDir(), name)
+		if err := m.Save(filename); err != nil {
+			t.Fatal(err)
+		}
+		m2 := MultiLogRegression{}
+		if err := m2.Load(filename); err != nil {
+			t.Fatal(err)
+		}
+		if !mat.Equal(m.w, m2.w) || !mat.Equal(m.PredictProba(X), m2.PredictProba(X)) {
+			t.Errorf("%s: model did not round-trip", name)
+		}
+		if (&LinearRegression{}).Load(filename) == nil {
+			t.Errorf("%s: loaded as wrong type of model", name)
+		}
+	}
+}
@@ -5,65 +5,108 @@
 //
 // Sample usage (see demo in separate file):
 // m := LinearRegression{}   // create model
-// m.verbose = true  // set flag (also lr, iterations, tol)
-// m.train(X, Y)   // train the  model
-// matPrint(m.w) // prints final coefficients
-// preds := m.predict(X) // make prediction
+// m.Verbose = true  // set flag (also LR, Iterations, Tol)
+// m.Solver = SolverQR  // optional, exact solution instead of gradient descent
+// m.Train(X, Y)   // train the  model
+// utils.MatPrint(m.Weights()) // prints final coefficients
+// preds := m.Predict(X) // make prediction
 
 package regression
 
 import (
 	"fmt"
 	"math"
+	"math/rand"
 
 	"gonum.org/v1/gonum/mat"
+	"mlcode/optimizer"
+	"mlcode/utils"
 )
 
 // Structure for a linear regression model
 type LinearRegression struct {
-	lr         float64    // learning rate, default .001
-	tol        float64    // tolerance to stop training, default .001
-	iterations int        // max iterations, default 1000
-	verbose    bool       // messages during train, default false
-	w          *mat.Dense // vector of weights, set during training
+	LR         float64 // learning rate, default .001
+	Tol        float64 // tolerance to stop training, default .001
+	Iterations int     // max iterations, default 1000
+	Verbose    bool    // messages during train, default false
+
+	// Optional settings for mini-batch training, default is plain gradient
+	// descent using LR on all rows at once
+	Optimizer optimizer.Optimizer // e.g., &optimizer.Adam{LR: .01}
+	BatchSize int                 // number of rows per batch, default all
+	Shuffle   bool                // shuffle rows before each pass
+	Seed      int64               // random seed for shuffling
+
+	// Method to find the coefficients, SolverNormal, SolverQR or SolverSVD
+	// give the exact least squares solution in one step, SolverCD uses
+	// coordinate descent, default SolverGD
+	Solver Solver
+
+	// Optional regularization, see penalty.go. With the gd solver, the L1
+	// term needs no Optimizer other than optimizer.SGD.
+	Penalty Penalty // PenaltyL2, PenaltyL1 or PenaltyElasticNet, default none
+	Alpha   float64 // strength of the penalty
+	L1Ratio float64 // mix of L1 in elastic net, default .5
+
+	// Optional names of the X columns, e.g., headings from ReadMatrixCSV,
+	// used to label coefficients in the Summary
+	Names []string
+
+	w     *mat.Dense // vector of weights, set during training
+	icept int        // column of X for the intercept, -1 if none
 }
 
 // Train linear regression model using gradient descent on coeffients,
-// until loss stops improving by at least the tolerance. Sets vector
-// of coefficients.
+// until loss stops improving by at least the tolerance, or using a
+// different solver if one was selected. Sets vector of coefficients.
+// Panics if the solver or penalty settings are invalid, use Fit to get
+// an error instead.
 func (m *LinearRegression) Train(X, Y *mat.Dense) {
+	if err := m.Validate(); err != nil {
+		panic(err.Error())
+	}
+
+	// Other solvers find the coefficients directly
+	if m.solverSelected() {
+		if err := m.solve(X, Y); err != nil {
+			panic(err.Error())
+		}
+		return
+	}
+	pen, _ := newPenalty(m.Penalty, m.Alpha, m.L1Ratio, X)
 
 	// Initialize weights/coefficients to zero (a column vector, with length =
 	// number of columns in X)
 	_, c := X.Dims()
 	m.w = mat.NewDense(c, 1, nil) // Python: np.zeros((X.shape[1], 1))
-
-	// Set other model parameters if not set yet
-	if m.iterations <= 0 {
-		m.iterations = 1000
-	}
-	if m.lr <= 0 || m.lr >= 1 {
-		m.lr = .001
-	}
-	if m.tol <= 0 || m.tol >= 1 {
-		m.tol = .001
+	m.icept = findIntercept(X)
+
+	// Set other model parameters if not set yet, and add the penalty (if
+	// any) to the gradient and optimizer steps
+	m.setDefaults()
+	opt := m.Optimizer
+	if opt == nil {
+		opt = &optimizer.SGD{LR: m.LR}
 	}
+	opt = pen.optimizer(opt)
+	gradient := pen.gradient(m.w, m.Gradient)
+	rng := rand.New(rand.NewSource(m.Seed))
 
 	// Initialize the previous loss, so we can detect when we are converging
 	prevLoss := 0.0
 
 	// Iterate until tolerance is low
-	for i := 0; i < m.iterations; i++ { // run to maximum iterations
+	for i := 0; i < m.Iterations; i++ { // run to maximum iterations
 
-		// Calculate loss using current weights
-		l := m.Loss(X, Y)
-		if m.verbose {
+		// Calculate loss using current weights, including penalty
+		l := m.Loss(X, Y) + pen.value(m.w)
+		if m.Verbose {
 			fmt.Printf("Iteration %d: loss = %f\n", i, l)
 		}
 
 		// Solution found if improvement less than tolerance
-		if i > 0 && math.Abs(prevLoss-l) < m.tol {
-			if m.verbose {
+		if i > 0 && math.Abs(prevLoss-l) < m.Tol {
+			if m.Verbose {
 				fmt.Println("Solution found")
 			}
 			return
@@ -72,17 +115,73 @@ func (m *LinearRegression) Train(X, Y *mat.Dense) {
 		// Remember loss for next iteration
 		prevLoss = l
 
-		// Adjust the weights (coefficients) using the gradients
+		// Adjust the weights (coefficients) using the gradients, one
+		// step per batch
 		// Python: w -= gradient(X, Y, w) * lr
-		grads := m.Gradient(X, Y)
-		grads.Scale(m.lr, grads)
-		m.w.Sub(m.w, grads)
+		optimizer.Epoch(opt, m.w, X, Y, m.BatchSize, m.Shuffle, rng, gradient)
 	}
 
 	// Message if reached max iterations
-	if m.verbose {
-		fmt.Printf("Stopped at %d iterations\n", m.iterations)
+	if m.Verbose {
+		fmt.Printf("Stopped at %d iterations\n", m.Iterations)
+	}
+}
+
+// Fit the model to training data, for the utils.Estimator interface
+func (m *LinearRegression) Fit(X, Y *mat.Dense) error {
+	if err := utils.CheckXY(X, Y); err != nil {
+		return err
+	}
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	if m.solverSelected() {
+		return m.solve(X, Y)
+	}
+	m.Train(X, Y)
+	return nil
+}
+
+// Set parameters for gradient or coordinate descent, if not set yet
+func (m *LinearRegression) setDefaults() {
+	if m.Iterations <= 0 {
+		m.Iterations = 1000
 	}
+	if m.LR <= 0 {
+		m.LR = .001
+	}
+	if m.Tol <= 0 {
+		m.Tol = .001
+	}
+}
+
+// Is a solver other than gradient descent selected?
+func (m *LinearRegression) solverSelected() bool {
+	return m.Solver != "" && m.Solver != SolverGD
+}
+
+// Set the coefficients using the selected solver, assumes the settings
+// have been validated. Closed-form solvers support the L2 penalty, by
+// adding rows to X and Y, but not L1.
+func (m *LinearRegression) solve(X, Y *mat.Dense) error {
+	pen, _ := newPenalty(m.Penalty, m.Alpha, m.L1Ratio, X)
+	var w *mat.Dense
+	if m.Solver == SolverCD {
+		m.setDefaults()
+		w = coordinateDescent(X, Y, pen, m.Iterations, m.Tol, m.Verbose)
+	} else {
+		var err error
+		Xa, Ya := pen.augment(X, Y)
+		if w, err = leastSquares(Xa, Ya, m.Solver); err != nil {
+			return fmt.Errorf("LinearRegression: %w", err)
+		}
+	}
+	m.w = w
+	m.icept = findIntercept(X)
+	if m.Verbose {
+		fmt.Printf("Solved using %s, loss = %f\n", m.Solver, m.Loss(X, Y))
+	}
+	return nil
 }
 
 // Predict Y values (one column), given X values (one column per variable) and
@@ -96,6 +195,23 @@ func (m *LinearRegression) Predict(X *mat.Dense) *mat.Dense {
 	return res
 }
 
+// Learned weights, one per column of X including any intercept, nil if
+// not trained. Returns a copy.
+func (m *LinearRegression) Weights() *mat.Dense {
+	return copyWeights(m.w)
+}
+
+// Learned coefficients for the columns of X, other than the intercept
+func (m *LinearRegression) Coefficients() []float64 {
+	return coefficients(m.w, m.icept)
+}
+
+// Learned intercept, i.e., the weight of the first column of X that is
+// all ones, zero if there is no such column
+func (m *LinearRegression) Intercept() float64 {
+	return intercept(m.w, m.icept)
+}
+
 // Calculate the mean squared difference between predicted and actual values
 // Python: np.average((predict(X, w) - Y) ** 2)
 func (m *LinearRegression) Loss(X, Y *mat.Dense) float64 {
@@ -21,15 +21,35 @@ func Linear_Regression_Demo() {
 
 	// Create and train model
 	m := LinearRegression{}
-	m.verbose = true
+	m.Verbose = true
 	m.Train(X, Y)
 
 	// Show coefficients
 	fmt.Println("Final weights")
-	utils.MatPrint(m.w) // prints final coefficients
+	utils.MatPrint(m.Weights()) // prints final coefficients
 
 	// Make prediction
 	preds := m.Predict(X)
 	fmt.Println("Predictions:")
 	utils.MatPrint(preds)
+
+	// Compare with the exact least squares solution, found in one step
+	// using QR decomposition
+	m2 := LinearRegression{Solver: SolverQR}
+	if err := m2.Fit(X, Y); err != nil {
+		panic(err)
+	}
+	fmt.Printf("Exact weights (QR), loss = %f vs. %f using gradient descent\n", m2.Loss(X, Y), m.Loss(X, Y))
+	utils.MatPrint(m2.Weights())
+
+	// Statistical summary of the exact model, with coefficients named
+	// after the columns
+	for _, col := range (*df)[:3] {
+		m2.Names = append(m2.Names, col.Name)
+	}
+	summ, err := m2.Summary(X, Y)
+	if err != nil {
+		panic(err)
+	}
+	summ.Print()
 }
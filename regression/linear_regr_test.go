@@ -29,3 +29,39 @@ func TestLinearRegression(t *testing.T) {
 		t.Error("Linear regression failed")
 	}
 }
+
+// Test the closed-form solvers give the same, exact, least squares solution
+func TestLinearSolvers(t *testing.T) {
+
+	// Same data as above, the gradient should be zero at the exact solution
+	X := mat.NewDense(5, 3, []float64{13, 26, 9, 2, 14, 6, 14, 20, 3, 23, 25, 9, 13, 24, 8})
+	Y := mat.NewDense(5, 1, []float64{44, 23, 28, 60, 42})
+	var first *mat.Dense
+	for _, s := range []Solver{SolverNormal, SolverQR, SolverSVD} {
+		m := LinearRegression{Solver: s}
+		if err := m.Fit(X, Y); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("Solver %s weights %v", s, mat.Formatted(m.w.T()))
+		if mat.Norm(m.Gradient(X, Y), 2) > 1e-8 {
+			t.Errorf("Solver %s: not at minimum", s)
+		}
+		if first != nil && !mat.EqualApprox(m.w, first, 1e-8) {
+			t.Errorf("Solver %s: different weights", s)
+		}
+		first = m.w
+	}
+
+	// With a duplicated column, only SVD can solve it, with the same loss
+	loss := (&LinearRegression{w: first}).Loss(X, Y)
+	Xdup := mat.NewDense(5, 4, nil)
+	Xdup.Augment(X, utils.ExtractCols(X, 0, 0))
+	m := LinearRegression{Solver: SolverNormal}
+	if err := m.Fit(Xdup, Y); err == nil {
+		t.Error("Normal equations should fail on collinear columns")
+	}
+	m = LinearRegression{Solver: SolverSVD}
+	if err := m.Fit(Xdup, Y); err != nil || !utils.Close(m.Loss(Xdup, Y), loss) {
+		t.Errorf("SVD failed on collinear columns: %v", err)
+	}
+}
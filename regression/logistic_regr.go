@@ -7,16 +7,20 @@
 //	data, _ := readMatrixCSV("data/police.txt")
 //	X := extractCols(data, 0, 2) // all cols except last
 //	Y := extractCols(data, 3, 3) // just the last col
-//  m := LogisticRegression{} // create model object
-//  m.verbose = true  // set an attribute
-//	m.train(X, Y) // train the model
-//	matPrint(m.w)  // show resulting coefficients
+//	m := LogisticRegression{} // create model object
+//	m.Verbose = true  // set an attribute (also LR, Iterations, Tol)
+//	m.Solver = SolverNewton // optional, converges in a few iterations
+//	m.Train(X, Y) // train the model
+//	fmt.Println(m.Converged(), m.NIterations()) // check convergence
+//	utils.MatPrint(m.Weights())  // show resulting coefficients
 
 package regression
 
 import (
 	"fmt"
 	"math"
+	"math/rand"
+	"mlcode/optimizer"
 	"mlcode/utils"
 
 	"gonum.org/v1/gonum/mat"
@@ -24,48 +28,207 @@ import (
 
 // Structure for a logistic regression model
 type LogisticRegression struct {
-	lr         float64    // learning rate, default .001
-	tol        float64    // tolerance to stop training, default .001
-	iterations int        // max iterations, default 1000
-	verbose    bool       // messages during train, default false
-	w          *mat.Dense // vector of weights, set during training
+	LR         float64 // learning rate, default .001
+	Tol        float64 // stop when all gradients are smaller, default .001 (1e-8 for newton and lbfgs)
+	Iterations int     // max iterations, default 1000 (100 for newton and lbfgs)
+	Verbose    bool    // messages during train, default false
+
+	// Method to find the coefficients, SolverNewton or SolverLBFGS use
+	// second derivatives and converge in far fewer iterations, see
+	// logistic_solvers.go, default SolverGD
+	Solver Solver
+
+	// Optional settings for mini-batch training, default is plain gradient
+	// descent using LR on all rows at once
+	Optimizer optimizer.Optimizer // e.g., &optimizer.Adam{LR: .01}
+	BatchSize int                 // number of rows per batch, default all
+	Shuffle   bool                // shuffle rows before each pass
+	Seed      int64               // random seed for shuffling
+
+	// Optional regularization, see penalty.go. The L1 term uses soft
+	// thresholding after each step, with the learning rate of the step, so
+	// needs the gd solver, and no Optimizer other than optimizer.SGD.
+	Penalty Penalty // PenaltyL2, PenaltyL1 or PenaltyElasticNet, default none
+	Alpha   float64 // strength of the penalty
+	L1Ratio float64 // mix of L1 in elastic net, default .5
+
+	// Optional weighting of rows by class, utils.ClassWeightBalanced gives
+	// each class the same total weight, for imbalanced data. Can be
+	// combined with sample weights, see TrainWeighted.
+	ClassWeight string
+
+	w         *mat.Dense // vector of weights, set during training
+	icept     int        // column of X for the intercept, -1 if none
+	nIter     int        // number of iterations run in training
+	converged bool       // whether training stopped within the tolerance
 }
 
-// Train a logistic regression model
+// Train a logistic regression model, until the gradient is smaller than
+// the tolerance or the maximum iterations are reached. Panics if the
+// solver or penalty settings are invalid, use Fit to get an error instead.
 func (m *LogisticRegression) Train(X, Y *mat.Dense) {
+	m.TrainWeighted(X, Y, nil)
+}
+
+// Train a logistic regression model with a weight for each row of the
+// training data (nil for none), which is combined with the ClassWeight
+// setting. Rows with a higher weight count more in the loss. Panics if
+// the settings or weights are invalid, use FitWeighted to get an error
+// instead.
+func (m *LogisticRegression) TrainWeighted(X, Y *mat.Dense, weights []float64) {
+	if err := m.Validate(); err != nil {
+		panic(err.Error())
+	}
+	s, err := utils.SampleWeights(mat.Col(nil, 0, Y), weights, m.ClassWeight)
+	if err != nil {
+		panic("LogisticRegression: " + err.Error())
+	}
+	pen, _ := newPenalty(m.Penalty, m.Alpha, m.L1Ratio, X)
+	m.icept = findIntercept(X)
+
+	// Defaults for any settings not set
+	lr, tol, iterations := logisticDefaults(m.Solver, m.LR, m.Tol, m.Iterations)
+
+	// Second order solvers find the coefficients in a few iterations
+	if m.Solver == SolverNewton || m.Solver == SolverLBFGS {
+		m.w, m.nIter, m.converged = fitLogistic(X, Y, s, pen, false, m.Solver, iterations, tol, m.Verbose)
+		return
+	}
 
 	// Initialize weights/coefficients to zero (a column vector, with length =
 	// number of columns in X)
 	_, c := X.Dims()
 	m.w = mat.NewDense(c, 1, nil) // Python: np.zeros((X.shape[1], 1))
 
-	// Set other model parameters if not set yet
-	if m.iterations <= 0 {
-		m.iterations = 1000
+	// Add the penalty (if any) to the gradient and optimizer steps
+	opt := m.Optimizer
+	if opt == nil {
+		opt = &optimizer.SGD{LR: lr}
 	}
-	if m.lr <= 0 || m.lr >= 1 {
-		m.lr = .001
-	}
-	if m.tol <= 0 || m.tol >= 1 {
-		m.tol = .001
+	opt = pen.optimizer(opt)
+	gradient := func(X, Y *mat.Dense, s []float64) *mat.Dense {
+		g := m.gradientWeighted(X, Y, s)
+		pen.addGradient(g, m.w)
+		return g
 	}
+	rng := rand.New(rand.NewSource(m.Seed))
 
-	// Just repeat for given number of interations
-	for i := 0; i < m.iterations; i++ {
+	// Repeat until converged or reached max iterations
+	m.converged = false
+	for m.nIter = 0; m.nIter < iterations && !m.converged; m.nIter++ {
 
-		// Calculate loss
-		l := m.Loss(X, Y)
-		if m.verbose {
-			fmt.Printf("Iteration %d: loss = %f\n", i, l)
+		// Calculate loss, including penalty (only for information purposes)
+		if m.Verbose {
+			l := m.lossWeighted(X, Y, s) + pen.value(m.w)
+			fmt.Printf("Iteration %d: loss = %f\n", m.nIter, l)
 		}
 
-		// Adjust the weights (coefficients) using the gradients
+		// Adjust the weights (coefficients) using the gradients, one
+		// step per batch, solution found if the gradient on all rows is
+		// within tolerance
 		// Python: w -= gradient(X, Y, w) * lr
-		grads := m.Gradient(X, Y)
-		grads.Scale(m.lr, grads)
-		m.w.Sub(m.w, grads)
+		g := epochGradient(opt, m.w, X, Y, s, m.BatchSize, m.Shuffle, rng, gradient)
+		m.converged = pen.optimality(g, m.w) < tol
+	}
+	if m.Verbose {
+		fmt.Printf("Stopped at %d iterations, converged = %v\n", m.nIter, m.converged)
 	}
+}
+
+// Run one pass through the training data in mini-batches, as
+// optimizer.EpochWeighted, and return the average of the batch gradients,
+// weighted by the number of rows in each batch. With one batch this is the
+// gradient on all rows, otherwise close to it, so it can be used to check
+// convergence without another pass through the data.
+func epochGradient(opt optimizer.Optimizer, w, X, Y *mat.Dense, s []float64, batchSize int, shuffle bool,
+	rng *rand.Rand, gradient func(X, Y *mat.Dense, s []float64) *mat.Dense) *mat.Dense {
+	nr, _ := X.Dims()
+	var tot mat.Dense
+	optimizer.EpochWeighted(opt, w, X, Y, s, batchSize, shuffle, rng, func(X, Y *mat.Dense, s []float64) *mat.Dense {
+		g := gradient(X, Y, s)
+		br, _ := X.Dims()
+		var part mat.Dense
+		part.Scale(float64(br)/float64(nr), g)
+		if tot.IsEmpty() {
+			tot.CloneFrom(&part)
+		} else {
+			tot.Add(&tot, &part)
+		}
+		return g
+	})
+	return &tot
+}
 
+// Fit the model to training data, for the utils.Estimator interface.
+// Y must contain only 0 and 1.
+func (m *LogisticRegression) Fit(X, Y *mat.Dense) error {
+	return m.FitWeighted(X, Y, nil)
+}
+
+// Fit the model to training data with a weight for each row, see
+// TrainWeighted
+func (m *LogisticRegression) FitWeighted(X, Y *mat.Dense, weights []float64) error {
+	if err := utils.CheckXY(X, Y); err != nil {
+		return err
+	}
+	for _, c := range utils.Classes(Y) {
+		if c != 0 && c != 1 {
+			return fmt.Errorf("LogisticRegression: labels must be 0 or 1, found %v", c)
+		}
+	}
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	if _, err := utils.SampleWeights(mat.Col(nil, 0, Y), weights, m.ClassWeight); err != nil {
+		return fmt.Errorf("LogisticRegression: %w", err)
+	}
+	m.TrainWeighted(X, Y, weights)
+	return nil
+}
+
+// Predict class labels (0 or 1), same as Classify
+func (m *LogisticRegression) Predict(X *mat.Dense) *mat.Dense {
+	return m.Classify(X)
+}
+
+// Predict probabilities of class 0 and class 1, one column each
+func (m *LogisticRegression) PredictProba(X *mat.Dense) *mat.Dense {
+	p := m.Forward(X)
+	nr, _ := p.Dims()
+	res := mat.NewDense(nr, 2, nil)
+	for i := 0; i < nr; i++ {
+		res.Set(i, 0, 1-p.At(i, 0))
+		res.Set(i, 1, p.At(i, 0))
+	}
+	return res
+}
+
+// Learned weights, one per column of X including any intercept, nil if
+// not trained. Returns a copy.
+func (m *LogisticRegression) Weights() *mat.Dense {
+	return copyWeights(m.w)
+}
+
+// Learned coefficients for the columns of X, other than the intercept
+func (m *LogisticRegression) Coefficients() []float64 {
+	return coefficients(m.w, m.icept)
+}
+
+// Learned intercept, i.e., the weight of the first column of X that is
+// all ones, zero if there is no such column
+func (m *LogisticRegression) Intercept() float64 {
+	return intercept(m.w, m.icept)
+}
+
+// Number of iterations run in the last training
+func (m *LogisticRegression) NIterations() int {
+	return m.nIter
+}
+
+// Whether the last training converged, i.e., the gradient got smaller than
+// the tolerance before the maximum iterations
+func (m *LogisticRegression) Converged() bool {
+	return m.converged
 }
 
 // Forward prediction given X values and weights (coefficients)
@@ -97,6 +260,11 @@ func (m *LogisticRegression) Classify(X *mat.Dense) *mat.Dense {
 
 // Calculate loss function for predictions vs. actual values
 func (m *LogisticRegression) Loss(X, Y *mat.Dense) float64 {
+	return m.lossWeighted(X, Y, nil)
+}
+
+// Calculate the loss, with each row multiplied by its weight (nil for none)
+func (m *LogisticRegression) lossWeighted(X, Y *mat.Dense, s []float64) float64 {
 
 	// Calculate predictions
 	// Python: y_hat = forward(X, w)
@@ -112,7 +280,7 @@ func (m *LogisticRegression) Loss(X, Y *mat.Dense) float64 {
 	rows, _ := Y.Dims()
 	var result float64
 	for i := 0; i < rows; i++ {
-		result += Y.At(i, 0)*math.Log(y_hat.At(i, 0)) + (1-Y.At(i, 0))*math.Log(1-y_hat.At(i, 0))
+		result += utils.Weight(s, i) * (Y.At(i, 0)*math.Log(y_hat.At(i, 0)) + (1-Y.At(i, 0))*math.Log(1-y_hat.At(i, 0)))
 	}
 	return result / float64(rows) * -1
 }
@@ -120,11 +288,18 @@ func (m *LogisticRegression) Loss(X, Y *mat.Dense) float64 {
 // Compute the gradient for logistic regression
 // Python: return 2 * np.matmul(X.T, (forward(X, w) - Y)) / X.shape[0]
 func (m *LogisticRegression) Gradient(X, Y *mat.Dense) *mat.Dense {
+	return m.gradientWeighted(X, Y, nil)
+}
+
+// Compute the gradient, with each row multiplied by its weight (nil for
+// none)
+func (m *LogisticRegression) gradientWeighted(X, Y *mat.Dense, s []float64) *mat.Dense {
 
-	// Get differences of predictions vs. actual
+	// Get differences of predictions vs. actual, times the weights
 	// Python: (forward(X, w) - Y))
 	deltas := m.Forward(X)
 	deltas.Sub(deltas, Y)
+	scaleRows(deltas, s)
 
 	// Multiply transposed X by the deltas
 	// Python: np.matmul(X.T, ...)
@@ -22,8 +22,18 @@ func Logistic_Regression_Demo() {
 	utils.MatPrint(Y)
 
 	// Train logistic regression model
-	m := LogisticRegression{verbose: true}
+	m := LogisticRegression{}
+	m.Verbose = true
 	m.Train(X, Y)
 	fmt.Println("\nFinal coefficients:")
-	utils.MatPrint(m.w)
+	utils.MatPrint(m.Weights())
+	fmt.Printf("Converged = %v after %d iterations\n", m.Converged(), m.NIterations())
+
+	// Newton's method gets to the exact solution in a few iterations
+	m2 := LogisticRegression{}
+	m2.Solver = SolverNewton
+	m2.Train(X, Y)
+	fmt.Printf("\nNewton: converged = %v after %d iterations, coefficients:\n",
+		m2.Converged(), m2.NIterations())
+	utils.MatPrint(m2.Weights())
 }
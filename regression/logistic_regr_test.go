@@ -26,7 +26,7 @@ func TestLogRegr(t *testing.T) {
 
 	// Create a model
 	m := LogisticRegression{}
-	m.lr = 0.001
+	m.LR = 0.001
 
 	// Set up input values
 	X := mat.NewDense(5, 3, []float64{13, 26, 9, 2, 14, 6, 14, 20, 3, 23, 25, 9, 13, 24, 8})
@@ -79,7 +79,7 @@ func TestLogRegr(t *testing.T) {
 
 	// Test adjustment of weights using gradient (embedded inside training
 	// function, so replicate code here)
-	grads.Scale(m.lr, grads)
+	grads.Scale(m.LR, grads)
 	m.w.Sub(m.w, grads)
 	expect5 := mat.NewDense(3, 1, []float64{0.03459139, 0.03958463, 0.00976558})
 	if !utils.MatSame(m.w, expect5) {
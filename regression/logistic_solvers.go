@@ -0,0 +1,318 @@
+// Second order solvers for logistic regression, which converge in far
+// fewer iterations than gradient descent and need no learning rate:
+//
+//   - Newton-Raphson, also known as iteratively reweighted least squares
+//     (IRLS), uses the exact Hessian (matrix of second derivatives) of the
+//     loss, so converges quadratically near the solution. Each step solves
+//     a system with one row per weight, so it suits models with a modest
+//     number of columns.
+//   - L-BFGS builds up an approximation of the Hessian from the recent
+//     gradients, using gonum/optimize, so scales to more columns.
+//
+// Both work for binary, one-vs-rest and multinomial (softmax) models, and
+// support the L2 penalty. They stop when the largest absolute value of the
+// gradient is below the tolerance. Similar to solver="newton-cg" and
+// solver="lbfgs" in scikit-learn's LogisticRegression.
+
+package regression
+
+import (
+	"fmt"
+	"math"
+	"mlcode/utils"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/optimize"
+)
+
+// Loss of a logistic model as a function of its weights, flattened row by
+// row into a vector (row j, class k is element j*K+k), with the gradient
+// and Hessian
+type logisticObjective struct {
+	X, Y        *mat.Dense // training data, Y has one column per class
+	s           []float64  // sample weights, nil if none
+	pen         penalty    // only the L2 term is used
+	multinomial bool       // softmax outputs, otherwise a sigmoid per column
+}
+
+// Weighted sums X * W for flattened weights
+func (o *logisticObjective) scores(w []float64) *mat.Dense {
+	nr, _ := o.X.Dims()
+	xc, k := o.size()
+	z := mat.NewDense(nr, k, nil)
+	z.Mul(o.X, mat.NewDense(xc, k, w))
+	return z
+}
+
+// Predicted probabilities for flattened weights, one column per class
+func (o *logisticObjective) probs(w []float64) *mat.Dense {
+	p := o.scores(w)
+	if o.multinomial {
+		softmaxRows(p)
+	} else {
+		p.Apply(func(i, j int, v float64) float64 {
+			return utils.Sigmoid(v)
+		}, p)
+	}
+	return p
+}
+
+// Number of rows and columns of the weights
+func (o *logisticObjective) size() (int, int) {
+	_, xc := o.X.Dims()
+	_, k := o.Y.Dims()
+	return xc, k
+}
+
+// Cross-entropy loss plus the L2 penalty, computed from the weighted sums
+// so that it stays accurate when probabilities are very close to 0 or 1
+func (o *logisticObjective) loss(w []float64) float64 {
+	z := o.scores(w)
+	nr, k := z.Dims()
+	var tot float64
+	for i := 0; i < nr; i++ {
+		zi := z.RawRowView(i)
+		yi := o.Y.RawRowView(i)
+
+		// Softmax: -sum(y * log(p)) = sum(y * (logsumexp(z) - z))
+		if o.multinomial {
+			max := zi[0]
+			for _, v := range zi {
+				max = math.Max(max, v)
+			}
+			var s float64
+			for _, v := range zi {
+				s += math.Exp(v - max)
+			}
+			lse := max + math.Log(s)
+			for j := 0; j < k; j++ {
+				tot += utils.Weight(o.s, i) * yi[j] * (lse - zi[j])
+			}
+			continue
+		}
+
+		// Sigmoid: -y log(p) - (1-y) log(1-p) = log(1 + exp(z)) - y z
+		for j := 0; j < k; j++ {
+			tot += utils.Weight(o.s, i) * (softplus(zi[j]) - yi[j]*zi[j])
+		}
+	}
+	return tot/float64(nr) + o.l2Value(w)
+}
+
+// Gradient of the loss, X'(P - Y) / n plus the L2 term, with the rows
+// of P - Y multiplied by their weights
+func (o *logisticObjective) grad(g, w []float64) {
+	p := o.probs(w)
+	p.Sub(p, o.Y)
+	scaleRows(p, o.s)
+	nr, _ := o.X.Dims()
+	xc, k := o.size()
+	res := mat.NewDense(xc, k, g)
+	res.Mul(o.X.T(), p)
+	res.Scale(1/float64(nr), res)
+	o.forPenalized(w, func(i int) {
+		g[i] += o.pen.l2 * w[i]
+	})
+}
+
+// Hessian of the loss. For rows j, l and classes k, m of the weights this
+// is sum(x_j * x_l * c_km) / n, where c_km = p_k * (1 - p_k) if k = m and
+// -p_k * p_m otherwise for softmax, and zero for separate sigmoids, times
+// the weight of each row.
+func (o *logisticObjective) hess(h *mat.SymDense, w []float64) {
+	p := o.probs(w)
+	nr, _ := o.X.Dims()
+	xc, k := o.size()
+	var xtcx, cx mat.Dense
+	for a := 0; a < k; a++ {
+		for b := a; b < k; b++ {
+			if a != b && !o.multinomial {
+				continue
+			}
+
+			// Scale rows of X by c_ab, then multiply by X'
+			cx.Scale(1, o.X)
+			for i := 0; i < nr; i++ {
+				c := -p.At(i, a) * p.At(i, b)
+				if a == b {
+					c = p.At(i, a) * (1 - p.At(i, a))
+				}
+				c *= utils.Weight(o.s, i)
+				row := cx.RawRowView(i)
+				for j := range row {
+					row[j] *= c
+				}
+			}
+			xtcx.Mul(o.X.T(), &cx)
+			for j := 0; j < xc; j++ {
+				for l := 0; l < xc; l++ {
+					h.SetSym(j*k+a, l*k+b, xtcx.At(j, l)/float64(nr))
+				}
+			}
+		}
+	}
+	o.forPenalized(w, func(i int) {
+		h.SetSym(i, i, h.At(i, i)+o.pen.l2)
+	})
+}
+
+// Value of the L2 penalty, for all classes
+func (o *logisticObjective) l2Value(w []float64) float64 {
+	var tot float64
+	o.forPenalized(w, func(i int) {
+		tot += o.pen.l2 / 2 * w[i] * w[i]
+	})
+	return tot
+}
+
+// Call a function for each element of the flattened weights that has an
+// L2 penalty, i.e., all classes of each column other than the intercept
+func (o *logisticObjective) forPenalized(w []float64, fn func(i int)) {
+	if o.pen.l2 == 0 {
+		return
+	}
+	_, k := o.size()
+	for j, free := range o.pen.free {
+		if !free {
+			for c := 0; c < k; c++ {
+				fn(j*k + c)
+			}
+		}
+	}
+}
+
+// Fit the weights of a logistic model using the Newton or L-BFGS solver,
+// starting from zero, with optional sample weights s. Returns the weights,
+// one column per column of Y, the number of iterations and whether the
+// gradient got below the tolerance.
+func fitLogistic(X, Y *mat.Dense, s []float64, pen penalty, multinomial bool, solver Solver, iterations int, tol float64, verbose bool) (*mat.Dense, int, bool) {
+	o := &logisticObjective{X: X, Y: Y, s: s, pen: pen, multinomial: multinomial}
+	xc, k := o.size()
+	w := make([]float64, xc*k)
+	var n int
+	if solver == SolverNewton {
+		n = newton(o, w, iterations, tol, verbose)
+	} else {
+		n = lbfgs(o, w, iterations, tol, verbose)
+	}
+
+	// Check convergence the same way for both solvers
+	g := make([]float64, len(w))
+	o.grad(g, w)
+	converged := maxAbs(g) < tol
+	if verbose {
+		fmt.Printf("%s: loss = %g, max gradient = %g after %d iterations\n",
+			solver, o.loss(w), maxAbs(g), n)
+	}
+	return mat.NewDense(xc, k, w), n, converged
+}
+
+// Newton-Raphson: repeatedly solve H d = g and step w -= d, halving the
+// step while it does not reduce the loss. Updates w in place, returns the
+// number of iterations.
+func newton(o *logisticObjective, w []float64, iterations int, tol float64, verbose bool) int {
+	nw := len(w)
+	g := make([]float64, nw)
+	h := mat.NewSymDense(nw, nil)
+	d := mat.NewVecDense(nw, nil)
+	trial := make([]float64, nw)
+	for it := 0; it < iterations; it++ {
+		o.grad(g, w)
+		l := o.loss(w)
+		if verbose {
+			fmt.Printf("Iteration %d: loss = %g, max gradient = %g\n", it, l, maxAbs(g))
+		}
+		if maxAbs(g) < tol {
+			return it
+		}
+
+		// Newton direction, using Cholesky if the Hessian is well
+		// conditioned, otherwise the minimum norm solution by SVD (e.g.,
+		// softmax weights, where adding the same value to every class
+		// changes nothing)
+		o.hess(h, w)
+		if err := solveSym(d, h, mat.NewVecDense(nw, g)); err != nil {
+			if verbose {
+				fmt.Println("Newton:", err)
+			}
+			return it
+		}
+
+		// Backtracking line search, stop if no step reduces the loss
+		// (only happens when already at the limit of precision)
+		step := 1.0
+		for {
+			for i := range w {
+				trial[i] = w[i] - step*d.AtVec(i)
+			}
+			if o.loss(trial) <= l {
+				break
+			}
+			step /= 2
+			if step < 1e-10 {
+				return it
+			}
+		}
+		copy(w, trial)
+	}
+	return iterations
+}
+
+// Solve the symmetric system h x = b
+func solveSym(x *mat.VecDense, h *mat.SymDense, b *mat.VecDense) error {
+	var chol mat.Cholesky
+	if chol.Factorize(h) && chol.Cond() < 1/svdRcond {
+		return chol.SolveVecTo(x, b)
+	}
+	var svd mat.SVD
+	if !svd.Factorize(h, mat.SVDThin) {
+		return fmt.Errorf("svd of Hessian failed")
+	}
+	rank := svd.Rank(svdRcond)
+	if rank == 0 {
+		return fmt.Errorf("Hessian is zero")
+	}
+	svd.SolveVecTo(x, b, rank)
+	return nil
+}
+
+// L-BFGS using gonum/optimize, stopping when the gradient is below the
+// tolerance or the maximum iterations are reached. Updates w in place,
+// returns the number of iterations.
+func lbfgs(o *logisticObjective, w []float64, iterations int, tol float64, verbose bool) int {
+	settings := &optimize.Settings{
+		GradientThreshold: tol,
+		MajorIterations:   iterations,
+		Converger:         optimize.NeverTerminate{},
+	}
+	if verbose {
+		settings.Recorder = optimize.NewPrinter()
+	}
+	prob := optimize.Problem{Func: o.loss, Grad: o.grad}
+	res, err := optimize.Minimize(prob, w, settings, &optimize.LBFGS{})
+
+	// The line search fails if the loss can no longer be reduced, the
+	// result is still the best point found
+	if err != nil && verbose {
+		fmt.Println("L-BFGS:", err)
+	}
+	if res == nil {
+		return 0
+	}
+	copy(w, res.X)
+	return res.Stats.MajorIterations
+}
+
+// Numerically stable log(1 + exp(z))
+func softplus(z float64) float64 {
+	return math.Max(z, 0) + math.Log1p(math.Exp(-math.Abs(z)))
+}
+
+// Largest absolute value in a slice
+func maxAbs(x []float64) float64 {
+	var res float64
+	for _, v := range x {
+		res = math.Max(res, math.Abs(v))
+	}
+	return res
+}
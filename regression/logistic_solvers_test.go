@@ -0,0 +1,130 @@
+// Unit tests for the second order logistic regression solvers
+
+package regression
+
+import (
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Overlapping classes, so the weights have a finite minimum: an intercept
+// column and two features, with labels from a noisy linear score, cut
+// into the given number of classes
+func overlapData(n, classes int) (*mat.Dense, *mat.Dense) {
+	rng := rand.New(rand.NewSource(1))
+	X := mat.NewDense(n, 3, nil)
+	Y := mat.NewDense(n, 1, nil)
+	for i := 0; i < n; i++ {
+		x1, x2 := rng.NormFloat64(), rng.NormFloat64()
+		X.SetRow(i, []float64{1, x1, x2})
+		score := x1 - .5*x2 + rng.NormFloat64()
+		label := 0
+		for c := 1; c < classes; c++ {
+			if score > float64(c)-float64(classes)/2 {
+				label = c
+			}
+		}
+		Y.Set(i, 0, float64(label))
+	}
+	return X, Y
+}
+
+// Test binary logistic regression, Newton and L-BFGS should converge to
+// the same weights, with or without a penalty
+func TestLogisticSolvers(t *testing.T) {
+	X, Y := overlapData(100, 2)
+	for _, pen := range []Penalty{PenaltyNone, PenaltyL2} {
+		var first *mat.Dense
+		for _, s := range []Solver{SolverNewton, SolverLBFGS} {
+			m := LogisticRegression{Solver: s, Penalty: pen, Alpha: .1}
+			if err := m.Fit(X, Y); err != nil {
+				t.Fatal(err)
+			}
+			t.Logf("%s %s %v %d iterations", s, pen, mat.Formatted(m.w.T()), m.NIterations())
+			if !m.Converged() {
+				t.Errorf("%s %s: did not converge", s, pen)
+			}
+			if s == SolverNewton && m.NIterations() > 10 {
+				t.Errorf("Newton %s: too many iterations, %d", pen, m.NIterations())
+			}
+			if first != nil && !mat.EqualApprox(m.w, first, 1e-6) {
+				t.Errorf("%s %s: different solution", s, pen)
+			}
+			first = m.w
+		}
+	}
+
+	// Gradient descent reports convergence too, given enough iterations
+	m := LogisticRegression{LR: 1, Tol: 1e-4, Iterations: 10000}
+	m.Fit(X, Y)
+	if !m.Converged() || m.NIterations() == 10000 {
+		t.Error("Gradient descent did not converge")
+	}
+
+	// Defaults for one solver are not kept in the settings, so do not
+	// apply to another
+	m = LogisticRegression{Solver: SolverNewton}
+	m.Fit(X, Y)
+	m.Solver = SolverGD
+	m.Fit(X, Y)
+	if m.Tol != 0 || m.Iterations != 0 || m.LR != 0 || m.NIterations() <= 100 {
+		t.Errorf("Newton defaults kept: Tol %v, %d iterations, ran %d", m.Tol, m.Iterations, m.NIterations())
+	}
+
+	// L1 penalty needs gradient descent
+	m = LogisticRegression{Solver: SolverNewton, Penalty: PenaltyL1, Alpha: .1}
+	if m.Fit(X, Y) == nil {
+		t.Error("Newton solver accepted L1 penalty")
+	}
+	if (&LinearRegression{Solver: SolverLBFGS}).Fit(X, Y) == nil {
+		t.Error("Linear regression accepted lbfgs solver")
+	}
+}
+
+// Test multi-class logistic regression, one-vs-rest and multinomial
+func TestMultiLogSolvers(t *testing.T) {
+	X, Y := overlapData(150, 3)
+	for _, multi := range []bool{false, true} {
+		var first *mat.Dense
+		for _, s := range []Solver{SolverNewton, SolverLBFGS} {
+			m := MultiLogRegression{Solver: s, Multinomial: multi}
+			if err := m.Fit(X, Y); err != nil {
+				t.Fatal(err)
+			}
+			t.Logf("%s multinomial: %v %d iterations", s, multi, m.NIterations())
+			if !m.Converged() {
+				t.Errorf("%s multinomial %v: did not converge", s, multi)
+			}
+			p := m.PredictProba(X)
+			if first != nil && !mat.EqualApprox(p, first, 1e-6) {
+				t.Errorf("%s multinomial %v: different solution", s, multi)
+			}
+			first = p
+		}
+	}
+
+	// Gradient descent without a tolerance runs all the iterations
+	m := MultiLogRegression{LR: .1, Iterations: 50}
+	m.Fit(X, Y)
+	if m.NIterations() != 50 || m.Converged() {
+		t.Errorf("Stopped early after %d iterations", m.NIterations())
+	}
+
+	// One-vs-rest is a separate binary model for each class
+	m = MultiLogRegression{Solver: SolverNewton}
+	m.Fit(X, Y)
+	Y0 := mat.NewDense(150, 1, nil)
+	Y0.Apply(func(i, j int, v float64) float64 {
+		if Y.At(i, 0) == 0 {
+			return 1
+		}
+		return 0
+	}, Y0)
+	b := LogisticRegression{Solver: SolverNewton}
+	b.Fit(X, Y0)
+	if !mat.EqualApprox(m.w.ColView(0), b.w.ColView(0), 1e-8) {
+		t.Error("One-vs-rest weights differ from binary model")
+	}
+}
@@ -1,13 +1,17 @@
 // Multi-class Logistic Regression
 //
 // Based on Python implementation in Chapter 7 of "Programming Machine
-// Learning" by Paolo Perrotta
+// Learning" by Paolo Perrotta. By default each class has its own sigmoid
+// output (one-vs-rest), with Multinomial set the outputs use softmax, as
+// in LogisticRegression(multi_class="multinomial") in scikit-learn.
 
 package regression
 
 import (
 	"fmt"
 	"math"
+	"math/rand"
+	"mlcode/optimizer"
 	"mlcode/utils"
 
 	"gonum.org/v1/gonum/mat"
@@ -15,48 +19,205 @@ import (
 
 // Structure for a multi-class logistic regression model
 type MultiLogRegression struct {
-	LR         float64    // learning rate, e.g., .001
-	Iterations int        // iterations to run
-	Verbose    bool       // messages during train, default false
-	w          *mat.Dense // vector of weights, set during training
+	LR         float64 // learning rate, default .001
+	Tol        float64 // stop when all gradients are smaller, default 0, run all iterations (1e-8 for newton and lbfgs)
+	Iterations int     // max iterations, default 1000 (100 for newton and lbfgs)
+	Verbose    bool    // messages during train, default false
+
+	// Method to find the coefficients, SolverNewton or SolverLBFGS, see
+	// logistic_solvers.go, default SolverGD
+	Solver Solver
+
+	// Use softmax output and categorical cross-entropy, so the class
+	// probabilities sum to 1. Default is a separate sigmoid per class
+	// (one-vs-rest).
+	Multinomial bool
+
+	// Optional settings for mini-batch training, default is plain gradient
+	// descent using LR on all rows at once
+	Optimizer optimizer.Optimizer // e.g., &optimizer.Adam{LR: .01}
+	BatchSize int                 // number of rows per batch, default all
+	Shuffle   bool                // shuffle rows before each pass
+	Seed      int64               // random seed for shuffling
+
+	// Optional weighting of rows by class, utils.ClassWeightBalanced gives
+	// each class the same total weight, for imbalanced data. Can be
+	// combined with sample weights, see TrainWeighted.
+	ClassWeight string
+
+	w         *mat.Dense // matrix of weights, one column per class, set during training
+	icept     int        // column of X for the intercept, -1 if none
+	classes   []float64  // class labels, if trained using Fit
+	nIter     int        // number of iterations run in training
+	converged bool       // whether training stopped within the tolerance
 }
 
-// Train a multi-class logistic regression model, sets the weights in the model object
+// Train a multi-class logistic regression model, until the gradient is
+// smaller than the tolerance or the maximum iterations are reached, sets
+// the weights in the model object. Y is one-hot encoded, one column per
+// class, use Fit for a column of class labels. Panics if the settings are
+// invalid, use Fit to get an error instead.
 func (m *MultiLogRegression) Train(X, Y *mat.Dense) {
+	m.TrainWeighted(X, Y, nil)
+}
+
+// Train a multi-class logistic regression model with a weight for each row
+// of the training data (nil for none), which is combined with the
+// ClassWeight setting. Panics if the settings or weights are invalid, use
+// FitWeighted to get an error instead.
+func (m *MultiLogRegression) TrainWeighted(X, Y *mat.Dense, weights []float64) {
+	if err := m.Validate(); err != nil {
+		panic(err.Error())
+	}
+
+	s, err := utils.SampleWeights(rowLabels(Y), weights, m.ClassWeight)
+	if err != nil {
+		panic("MultiLogRegression: " + err.Error())
+	}
+
+	m.icept = findIntercept(X)
+	m.classes = nil
+
+	// Defaults for any settings not set. With gradient descent, a Tol of
+	// zero runs all the iterations.
+	lr, tol, iterations := logisticDefaults(m.Solver, m.LR, m.Tol, m.Iterations)
+
+	// Second order solvers find the coefficients in a few iterations
+	if m.Solver == SolverNewton || m.Solver == SolverLBFGS {
+		m.w, m.nIter, m.converged = fitLogistic(X, Y, s, penalty{}, m.Multinomial, m.Solver, iterations, tol, m.Verbose)
+		return
+	}
+	tol = m.Tol
 
 	// Initialize weights/coefficients to zero
 	// Python: np.zeros((X_train.shape[1], Y_train.shape[1]))
 	_, xc := X.Dims()
 	_, yc := Y.Dims()
 	m.w = mat.NewDense(xc, yc, nil)
+	opt := m.Optimizer
+	if opt == nil {
+		opt = &optimizer.SGD{LR: lr}
+	}
+	rng := rand.New(rand.NewSource(m.Seed))
 
-	// Just repeat for given number of interations
-	for i := 0; i < m.Iterations; i++ {
+	// Repeat until converged or reached max iterations
+	m.converged = false
+	for m.nIter = 0; m.nIter < iterations && !m.converged; m.nIter++ {
 
 		// Calculate loss (only for information purposes)
 		if m.Verbose {
-			l := m.Loss(X, Y)
-			fmt.Printf("Iteration %d: loss = %f\n", i, l)
+			l := m.lossWeighted(X, Y, s)
+			fmt.Printf("Iteration %d: loss = %f\n", m.nIter, l)
 		}
 
-		// Adjust the weights (coefficients) using the gradients
+		// Adjust the weights (coefficients) using the gradients, one
+		// step per batch, solution found if the gradient on all rows is
+		// within tolerance
 		// Python: w -= gradient(X, Y, w) * lr
-		grads := m.Gradient(X, Y)
-		grads.Scale(m.LR, grads)
-		m.w.Sub(m.w, grads)
-		//matPrint(m.w)
+		g := epochGradient(opt, m.w, X, Y, s, m.BatchSize, m.Shuffle, rng, m.gradientWeighted)
+		m.converged = maxAbs(g.RawMatrix().Data) < tol
 	}
+	if m.Verbose {
+		fmt.Printf("Stopped at %d iterations, converged = %v\n", m.nIter, m.converged)
+	}
+}
+
+// Fit the model to training data, for the utils.Estimator interface. Y is
+// a column of class labels, which are one-hot encoded for training.
+func (m *MultiLogRegression) Fit(X, Y *mat.Dense) error {
+	return m.FitWeighted(X, Y, nil)
+}
+
+// Fit the model to training data with a weight for each row, see
+// TrainWeighted
+func (m *MultiLogRegression) FitWeighted(X, Y *mat.Dense, weights []float64) error {
+	if err := utils.CheckXY(X, Y); err != nil {
+		return err
+	}
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	if _, err := utils.SampleWeights(mat.Col(nil, 0, Y), weights, m.ClassWeight); err != nil {
+		return fmt.Errorf("MultiLogRegression: %w", err)
+	}
+	classes := utils.Classes(Y)
+	m.TrainWeighted(X, utils.OneHot(Y, classes), weights)
+	m.classes = classes
+	return nil
+}
+
+// Predict the class label for each row. If the model was trained on
+// one-hot encoded labels, this is the column number of the class, same as
+// Classify.
+func (m *MultiLogRegression) Predict(X *mat.Dense) *mat.Dense {
+	if m.classes == nil {
+		return m.Classify(X)
+	}
+	return utils.ArgMaxLabels(m.Forward(X), m.classes)
+}
+
+// Predict the probability of each class, one column per class. Rows sum
+// to 1: with Multinomial these are the softmax outputs, otherwise the
+// sigmoid outputs are divided by their total, as in scikit-learn.
+func (m *MultiLogRegression) PredictProba(X *mat.Dense) *mat.Dense {
+	p := m.Forward(X)
+	if !m.Multinomial {
+		normalizeRows(p)
+	}
+	return p
+}
 
+// Learned weights, one row per column of X including any intercept, and
+// one column per class, nil if not trained. Returns a copy.
+func (m *MultiLogRegression) Weights() *mat.Dense {
+	return copyWeights(m.w)
 }
 
-// Compute the gradient for logistic regression
+// Learned coefficients for the columns of X other than the intercept, one
+// column per class
+func (m *MultiLogRegression) Coefficients() *mat.Dense {
+	return withoutIntercept(m.w, m.icept)
+}
+
+// Learned intercept for each class, zero if X had no intercept column
+func (m *MultiLogRegression) Intercept() []float64 {
+	return interceptRow(m.w, m.icept)
+}
+
+// Class labels, in the order of the columns of the weights and
+// probabilities, nil if the model was trained on one-hot labels
+func (m *MultiLogRegression) Classes() []float64 {
+	return m.classes
+}
+
+// Number of iterations run in the last training
+func (m *MultiLogRegression) NIterations() int {
+	return m.nIter
+}
+
+// Whether the last training converged, i.e., the gradient got smaller than
+// the tolerance before the maximum iterations
+func (m *MultiLogRegression) Converged() bool {
+	return m.converged
+}
+
+// Compute the gradient for logistic regression, the same formula for
+// sigmoid outputs with binary cross-entropy and softmax outputs with
+// categorical cross-entropy
 // Python: return np.matmul(X.T, (forward(X, w) - Y)) / X.shape[0]
 func (m *MultiLogRegression) Gradient(X, Y *mat.Dense) *mat.Dense {
+	return m.gradientWeighted(X, Y, nil)
+}
+
+// Compute the gradient, with each row multiplied by its weight (nil for
+// none)
+func (m *MultiLogRegression) gradientWeighted(X, Y *mat.Dense, s []float64) *mat.Dense {
 
-	// Get differences of predictions vs. actual
+	// Get differences of predictions vs. actual, times the weights
 	// Python: (forward(X, w) - Y))
 	deltas := m.Forward(X)
 	deltas.Sub(deltas, Y)
+	scaleRows(deltas, s)
 
 	// Multiply transposed X by the deltas
 	// Python: np.matmul(X.T, ...)
@@ -71,7 +232,7 @@ func (m *MultiLogRegression) Gradient(X, Y *mat.Dense) *mat.Dense {
 }
 
 // Forward prediction given X values and weights (coefficients)
-// Python: sigmoid(np.matmul(X, w))
+// Python: sigmoid(np.matmul(X, w)), or softmax(...) if multinomial
 func (m *MultiLogRegression) Forward(X *mat.Dense) *mat.Dense {
 
 	// weighted_sum = np.matmul(X, w)
@@ -79,6 +240,10 @@ func (m *MultiLogRegression) Forward(X *mat.Dense) *mat.Dense {
 	_, wc := m.w.Dims()
 	res := mat.NewDense(xr, wc, nil) // TODO: Avoid allocating each time?
 	res.Mul(X, m.w)
+	if m.Multinomial {
+		softmaxRows(res)
+		return res
+	}
 
 	// return sigmoid(weighted_sum) -- must be vectorized
 	res.Apply(func(i, j int, v float64) float64 {
@@ -90,6 +255,11 @@ func (m *MultiLogRegression) Forward(X *mat.Dense) *mat.Dense {
 
 // Calculate loss function for predictions vs. actual values
 func (m *MultiLogRegression) Loss(X, Y *mat.Dense) float64 {
+	return m.lossWeighted(X, Y, nil)
+}
+
+// Calculate the loss, with each row multiplied by its weight (nil for none)
+func (m *MultiLogRegression) lossWeighted(X, Y *mat.Dense, s []float64) float64 {
 
 	// Calculate predictions
 	// Python: y_hat = forward(X, w)
@@ -104,10 +274,23 @@ func (m *MultiLogRegression) Loss(X, Y *mat.Dense) float64 {
 	yrows, ycols := Y.Dims()
 	xrows, _ := X.Dims()
 	var result float64
+
+	// Categorical cross-entropy for softmax, only the actual class counts
+	// Python: -np.sum(Y * np.log(y_hat)) / X.shape[0]
+	if m.Multinomial {
+		for i := 0; i < yrows; i++ {
+			for j := 0; j < ycols; j++ {
+				if Y.At(i, j) != 0 {
+					result += utils.Weight(s, i) * Y.At(i, j) * math.Log(y_hat.At(i, j))
+				}
+			}
+		}
+		return result / float64(xrows) * -1
+	}
 	for i := 0; i < yrows; i++ {
 		for j := 0; j < ycols; j++ {
-			result += Y.At(i, j) * math.Log(y_hat.At(i, j))
-			result += (1 - Y.At(i, j)) * math.Log(1-y_hat.At(i, j))
+			result += utils.Weight(s, i) * Y.At(i, j) * math.Log(y_hat.At(i, j))
+			result += utils.Weight(s, i) * (1 - Y.At(i, j)) * math.Log(1-y_hat.At(i, j))
 		}
 	}
 
@@ -131,3 +314,40 @@ func (m *MultiLogRegression) Classify(X *mat.Dense) *mat.Dense {
 	}
 	return result
 }
+
+// Apply softmax to each row in place, subtracting the row maximum first
+// so that large values do not overflow
+// Python: e = np.exp(z - z.max(axis=1, keepdims=True))
+//
+//	return e / e.sum(axis=1, keepdims=True)
+func softmaxRows(z *mat.Dense) {
+	nr, _ := z.Dims()
+	for i := 0; i < nr; i++ {
+		row := z.RawRowView(i)
+		max := row[0]
+		for _, v := range row {
+			max = math.Max(max, v)
+		}
+		for j, v := range row {
+			row[j] = math.Exp(v - max)
+		}
+	}
+	normalizeRows(z)
+}
+
+// Divide each row by its total, in place, so the rows sum to 1
+func normalizeRows(p *mat.Dense) {
+	nr, _ := p.Dims()
+	for i := 0; i < nr; i++ {
+		row := p.RawRowView(i)
+		var tot float64
+		for _, v := range row {
+			tot += v
+		}
+		if tot > 0 {
+			for j := range row {
+				row[j] /= tot
+			}
+		}
+	}
+}
@@ -4,6 +4,7 @@ package regression
 
 import (
 	"fmt"
+	"math"
 	"testing"
 
 	"gonum.org/v1/gonum/mat"
@@ -82,3 +83,60 @@ func TestMultiLogRegr(t *testing.T) {
 		fmt.Printf("Loss failed: got %f, expected %f\n", l, expect5)
 	}
 }
+
+// Test multinomial (softmax) mode: gradient matches the loss, probabilities
+// sum to 1, and training works on a column of labels
+func TestMultinomial(t *testing.T) {
+	X := mat.NewDense(6, 2, []float64{1, 1, 1, 2, 1, 3, 1, 7, 1, 8, 1, 12})
+	Y := mat.NewDense(6, 1, []float64{4, 4, 6, 6, 9, 9})
+	m := MultiLogRegression{}
+	m.Multinomial = true
+	m.w = mat.NewDense(2, 3, []float64{.1, -.2, .3, .05, .01, -.02})
+
+	// Compare gradient with numerical derivative of the loss
+	Y1 := utils.OneHot(Y, utils.Classes(Y))
+	grad := m.Gradient(X, Y1)
+	eps := 1e-6
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 3; j++ {
+			w := m.w.At(i, j)
+			m.w.Set(i, j, w+eps)
+			lp := m.Loss(X, Y1)
+			m.w.Set(i, j, w-eps)
+			lm := m.Loss(X, Y1)
+			m.w.Set(i, j, w)
+			if math.Abs((lp-lm)/(2*eps)-grad.At(i, j)) > 1e-6 {
+				t.Errorf("Multinomial gradient wrong at %d, %d", i, j)
+			}
+		}
+	}
+
+	// Fit on the labels directly, probabilities sum to 1 in both modes
+	for _, multi := range []bool{true, false} {
+		m := MultiLogRegression{LR: .05, Iterations: 5000, Multinomial: multi}
+		if err := m.Fit(X, Y); err != nil {
+			t.Fatal(err)
+		}
+		if !utils.Same(m.Classes(), []float64{4, 6, 9}) {
+			t.Errorf("Multinomial %v: wrong classes %v", multi, m.Classes())
+		}
+		p := m.PredictProba(X)
+		for i := 0; i < 6; i++ {
+			if math.Abs(mat.Sum(p.RowView(i))-1) > 1e-9 {
+				t.Errorf("Multinomial %v: probabilities do not sum to 1", multi)
+			}
+		}
+		// One-vs-rest cannot separate the middle class with a single
+		// feature, softmax can
+		if multi && !mat.Equal(m.Predict(X), Y) {
+			t.Errorf("Multinomial %v: wrong predictions %v", multi, mat.Formatted(m.Predict(X).T()))
+		}
+	}
+
+	// Train takes Y as it is, so a single 0/1 column is one output
+	m = MultiLogRegression{LR: .05, Iterations: 100}
+	m.Train(X, mat.NewDense(6, 1, []float64{0, 0, 0, 1, 1, 1}))
+	if _, c := m.Weights().Dims(); c != 1 || m.Classes() != nil {
+		t.Errorf("Train encoded a single column as labels, %d columns of weights", c)
+	}
+}
@@ -0,0 +1,311 @@
+// Regularization penalties for linear and logistic regression, which
+// shrink the coefficients towards zero to reduce overfitting. The
+// penalty added to the loss is
+//
+//	Alpha * (L1Ratio * sum(|w|) + (1 - L1Ratio) / 2 * sum(w^2))
+//
+// so ridge (L2) is L1Ratio = 0, lasso (L1) is L1Ratio = 1, and elastic net
+// is anything in between. Intercept columns (all ones) are not penalized.
+
+package regression
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"mlcode/optimizer"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Type of regularization penalty
+type Penalty string
+
+const (
+	PenaltyNone       Penalty = "none"       // no regularization (the default)
+	PenaltyL2         Penalty = "l2"         // ridge, shrinks all coefficients
+	PenaltyL1         Penalty = "l1"         // lasso, sets some coefficients to zero
+	PenaltyElasticNet Penalty = "elasticnet" // mix of L1 and L2, using L1Ratio
+)
+
+// Penalty weights for training, and which columns are free of penalty
+type penalty struct {
+	l1, l2 float64 // strength of the L1 and L2 terms
+	free   []bool  // columns not penalized, i.e., the intercept
+}
+
+// Work out the L1 and L2 strengths for a penalty, and find the intercept
+// columns in X (if given). L1Ratio defaults to .5 for elastic net.
+func newPenalty(kind Penalty, alpha, l1Ratio float64, X *mat.Dense) (penalty, error) {
+	var p penalty
+	if alpha < 0 {
+		return p, fmt.Errorf("penalty: alpha must not be negative, got %v", alpha)
+	}
+	switch kind {
+	case "", PenaltyNone:
+		return p, nil
+	case PenaltyL2:
+		p.l2 = alpha
+	case PenaltyL1:
+		p.l1 = alpha
+	case PenaltyElasticNet:
+		if l1Ratio == 0 {
+			l1Ratio = .5
+		}
+		if l1Ratio < 0 || l1Ratio > 1 {
+			return p, fmt.Errorf("penalty: L1Ratio must be between 0 and 1, got %v", l1Ratio)
+		}
+		p.l1 = alpha * l1Ratio
+		p.l2 = alpha * (1 - l1Ratio)
+	default:
+		return p, fmt.Errorf("unknown penalty %q", kind)
+	}
+	if X != nil {
+		p.free = interceptCols(X)
+	}
+	return p, nil
+}
+
+// Value of the penalty for a vector of weights, to add to the loss
+func (p penalty) value(w *mat.Dense) float64 {
+	var tot float64
+	for j, free := range p.free {
+		if !free {
+			v := w.At(j, 0)
+			tot += p.l1*math.Abs(v) + p.l2/2*v*v
+		}
+	}
+	return tot
+}
+
+// Add the gradient of the L2 term to a gradient, in place. The L1 term
+// has no gradient at zero, so is handled by soft thresholding instead.
+func (p penalty) addGradient(grad, w *mat.Dense) {
+	for j, free := range p.free {
+		if !free {
+			grad.Set(j, 0, grad.At(j, 0)+p.l2*w.At(j, 0))
+		}
+	}
+}
+
+// Wrap a gradient function to include the L2 term
+func (p penalty) gradient(w *mat.Dense, gradient func(X, Y *mat.Dense) *mat.Dense) func(X, Y *mat.Dense) *mat.Dense {
+	if p.l2 == 0 {
+		return gradient
+	}
+	return func(X, Y *mat.Dense) *mat.Dense {
+		g := gradient(X, Y)
+		p.addGradient(g, w)
+		return g
+	}
+}
+
+// Largest violation of the conditions for a minimum, given the gradient
+// of the loss (including the L2 term) for each weight, used to detect
+// convergence. This is the largest absolute gradient, except that with an
+// L1 penalty a weight at zero is optimal if its gradient is within l1, and
+// a nonzero weight has l1 added in the direction of its sign.
+func (p penalty) optimality(grad, w *mat.Dense) float64 {
+	nr, nc := grad.Dims()
+	var res float64
+	for j := 0; j < nr; j++ {
+		for k := 0; k < nc; k++ {
+			g := grad.At(j, k)
+			if p.l1 > 0 && !p.free[j] {
+				if v := w.At(j, k); v == 0 {
+					g = math.Max(math.Abs(g)-p.l1, 0)
+				} else {
+					g += math.Copysign(p.l1, v)
+				}
+			}
+			res = math.Max(res, math.Abs(g))
+		}
+	}
+	return res
+}
+
+// Wrap an optimizer so that each step is followed by soft thresholding
+// for the L1 term (proximal gradient descent, or ISTA), using the learning
+// rate of the step. This is only right for plain gradient descent steps,
+// so other optimizers are rejected, see checkL1Optimizer.
+func (p penalty) optimizer(opt optimizer.Optimizer) optimizer.Optimizer {
+	if p.l1 == 0 {
+		return opt
+	}
+	sgd := opt.(*optimizer.SGD)
+	return &proximal{opt: opt, threshold: sgd.Rate() * p.l1, free: p.free}
+}
+
+// Check that the optimizer (if any) takes plain gradient descent steps,
+// as needed for soft thresholding of the L1 term: optimizers with momentum
+// or per-weight learning rates move each weight by a different amount
+func checkL1Optimizer(opt optimizer.Optimizer) error {
+	if _, ok := opt.(*optimizer.SGD); opt != nil && !ok {
+		return errors.New("L1 and elastic net penalties need the optimizer to be optimizer.SGD")
+	}
+	return nil
+}
+
+// Optimizer that applies soft thresholding after each step
+type proximal struct {
+	opt       optimizer.Optimizer
+	threshold float64
+	free      []bool
+}
+
+func (o *proximal) Step(w, grad *mat.Dense) {
+	o.opt.Step(w, grad)
+	for j, free := range o.free {
+		if !free {
+			w.Set(j, 0, softThreshold(w.At(j, 0), o.threshold))
+		}
+	}
+}
+
+// Move x towards zero by t, stopping at zero
+func softThreshold(x, t float64) float64 {
+	if x > t {
+		return x - t
+	}
+	if x < -t {
+		return x + t
+	}
+	return 0
+}
+
+// Find intercept columns, i.e., all values are one
+func interceptCols(X *mat.Dense) []bool {
+	nr, nc := X.Dims()
+	res := make([]bool, nc)
+	for j := 0; j < nc; j++ {
+		res[j] = true
+		for i := 0; i < nr && res[j]; i++ {
+			res[j] = X.At(i, j) == 1
+		}
+	}
+	return res
+}
+
+// Add rows to X and Y so that least squares on the result gives the ridge
+// solution: minimizing |Xw - Y|^2 / n + l2/2 |w|^2 is the same as least
+// squares with an extra row sqrt(n * l2 / 2) for each penalized column,
+// and zero for Y
+func (p penalty) augment(X, Y *mat.Dense) (*mat.Dense, *mat.Dense) {
+	if p.l2 == 0 {
+		return X, Y
+	}
+	nr, nc := X.Dims()
+	s := math.Sqrt(float64(nr) * p.l2 / 2)
+	Xa := mat.NewDense(nr+nc, nc, nil)
+	Ya := mat.NewDense(nr+nc, 1, nil)
+	Xa.Slice(0, nr, 0, nc).(*mat.Dense).Copy(X)
+	Ya.Slice(0, nr, 0, 1).(*mat.Dense).Copy(Y)
+	for j, free := range p.free {
+		if !free {
+			Xa.Set(nr+j, j, s)
+		}
+	}
+	return Xa, Ya
+}
+
+// Coordinate descent for linear regression with an elastic net penalty,
+// minimizes |Y - Xw|^2 / n + l1 |w| + l2/2 |w|^2 by optimizing one
+// coefficient at a time, keeping the others fixed, until no coefficient
+// changes by more than the tolerance. Similar to ElasticNet and Lasso in
+// scikit-learn.
+func coordinateDescent(X, Y *mat.Dense, p penalty, iterations int, tol float64, verbose bool) *mat.Dense {
+	nr, nc := X.Dims()
+	n := float64(nr)
+	if p.free == nil {
+		p.free = make([]bool, nc) // no penalty, all zero
+	}
+	w := mat.NewDense(nc, 1, nil)
+
+	// Residuals Y - Xw, kept up to date as coefficients change
+	resid := mat.DenseCopyOf(Y)
+
+	// Squared norm of each column, scaled by 2/n
+	z := make([]float64, nc)
+	for j := 0; j < nc; j++ {
+		col := mat.Col(nil, j, X)
+		z[j] = 2 / n * mat.Dot(mat.NewVecDense(nr, col), mat.NewVecDense(nr, col))
+	}
+
+	for it := 0; it < iterations; it++ {
+		maxChange := 0.0
+		for j := 0; j < nc; j++ {
+			if z[j] == 0 {
+				continue
+			}
+
+			// Correlation of column j with the residuals, not counting
+			// column j itself
+			old := w.At(j, 0)
+			var rho float64
+			for i := 0; i < nr; i++ {
+				rho += X.At(i, j) * (resid.At(i, 0) + X.At(i, j)*old)
+			}
+			rho *= 2 / n
+
+			// New coefficient, shrunk towards zero unless an intercept
+			var v float64
+			if p.free[j] {
+				v = rho / z[j]
+			} else {
+				v = softThreshold(rho, p.l1) / (z[j] + p.l2)
+			}
+
+			// Update the residuals for the change
+			if v != old {
+				for i := 0; i < nr; i++ {
+					resid.Set(i, 0, resid.At(i, 0)-X.At(i, j)*(v-old))
+				}
+				w.Set(j, 0, v)
+				maxChange = math.Max(maxChange, math.Abs(v-old))
+			}
+		}
+		if verbose {
+			fmt.Printf("Iteration %d: max change = %f\n", it, maxChange)
+		}
+		if maxChange < tol {
+			break
+		}
+	}
+	return w
+}
+
+// Regularization path: fit a copy of the model for each value of alpha,
+// with the other settings unchanged, and return the coefficients as one
+// column per alpha, to show how they shrink as alpha increases
+func (m *LinearRegression) RegularizationPath(X, Y *mat.Dense, alphas []float64) (*mat.Dense, error) {
+	return regularizationPath(X, alphas, func(alpha float64) (*mat.Dense, error) {
+		m2 := *m
+		m2.Alpha = alpha
+		err := m2.Fit(X, Y)
+		return m2.w, err
+	})
+}
+
+// Regularization path for logistic regression, see above
+func (m *LogisticRegression) RegularizationPath(X, Y *mat.Dense, alphas []float64) (*mat.Dense, error) {
+	return regularizationPath(X, alphas, func(alpha float64) (*mat.Dense, error) {
+		m2 := *m
+		m2.Alpha = alpha
+		err := m2.Fit(X, Y)
+		return m2.w, err
+	})
+}
+
+// Fit a model for each alpha, and collect the coefficients
+func regularizationPath(X *mat.Dense, alphas []float64, fit func(alpha float64) (*mat.Dense, error)) (*mat.Dense, error) {
+	_, nc := X.Dims()
+	res := mat.NewDense(nc, len(alphas), nil)
+	for i, alpha := range alphas {
+		w, err := fit(alpha)
+		if err != nil {
+			return nil, fmt.Errorf("alpha %v: %w", alpha, err)
+		}
+		res.SetCol(i, mat.Col(nil, 0, w))
+	}
+	return res, nil
+}
@@ -0,0 +1,133 @@
+// Unit tests for regularization penalties
+
+package regression
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+	"mlcode/optimizer"
+	"mlcode/utils"
+)
+
+// Small data set with an intercept column and two features, the second
+// feature is mostly noise
+func penaltyData() (*mat.Dense, *mat.Dense) {
+	X := mat.NewDense(8, 3, []float64{
+		1, -1.5, 0.3, 1, -1, -0.2, 1, -0.5, 0.4, 1, 0, -0.1,
+		1, 0.5, 0.2, 1, 1, -0.4, 1, 1.5, 0.1, 1, 2, -0.3})
+	Y := mat.NewDense(8, 1, []float64{-1.9, -1.1, 0.2, 0.9, 2.1, 3.2, 3.8, 5.1})
+	return X, Y
+}
+
+// Test ridge regression, all solvers should give the same solution
+func TestRidge(t *testing.T) {
+	X, Y := penaltyData()
+	var first *mat.Dense
+	for _, s := range []Solver{SolverNormal, SolverQR, SolverSVD, SolverCD} {
+		m := LinearRegression{Solver: s, Penalty: PenaltyL2, Alpha: .5, Tol: 1e-12}
+		if err := m.Fit(X, Y); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("Ridge %s %v", s, mat.Formatted(m.w.T()))
+
+		// At the minimum, the gradient of the loss is balanced by the
+		// penalty, except for the intercept
+		g := m.Gradient(X, Y)
+		for j := 1; j < 3; j++ {
+			if math.Abs(g.At(j, 0)+.5*m.w.At(j, 0)) > 1e-8 {
+				t.Errorf("Ridge %s: not at minimum", s)
+			}
+		}
+		if math.Abs(g.At(0, 0)) > 1e-8 {
+			t.Errorf("Ridge %s: intercept was penalized", s)
+		}
+		if first != nil && !mat.EqualApprox(m.w, first, 1e-8) {
+			t.Errorf("Ridge %s: different solution", s)
+		}
+		first = m.w
+	}
+}
+
+// Test lasso, using coordinate descent and proximal gradient descent
+func TestLasso(t *testing.T) {
+	X, Y := penaltyData()
+	cd := LinearRegression{Solver: SolverCD, Penalty: PenaltyL1, Alpha: .5, Tol: 1e-12}
+	if err := cd.Fit(X, Y); err != nil {
+		t.Fatal(err)
+	}
+	gd := LinearRegression{Penalty: PenaltyL1, Alpha: .5, LR: .1, Iterations: 5000, Tol: 1e-12}
+	if err := gd.Fit(X, Y); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Lasso cd %v gd %v", mat.Formatted(cd.w.T()), mat.Formatted(gd.w.T()))
+	if !mat.EqualApprox(cd.w, gd.w, 1e-4) {
+		t.Error("Lasso: coordinate and gradient descent differ")
+	}
+
+	// Soft thresholding uses the learning rate of an SGD optimizer, and
+	// other optimizers are rejected
+	gd2 := LinearRegression{Penalty: PenaltyL1, Alpha: .5, Iterations: 5000, Tol: 1e-12,
+		Optimizer: &optimizer.SGD{LR: .1}}
+	if err := gd2.Fit(X, Y); err != nil || !mat.EqualApprox(cd.w, gd2.w, 1e-4) {
+		t.Errorf("Lasso with SGD optimizer differs: %v", err)
+	}
+	gd2.Optimizer = &optimizer.Adam{LR: .1}
+	if err := gd2.Fit(X, Y); err == nil {
+		t.Error("Lasso accepted the Adam optimizer")
+	}
+
+	// The noise feature should be dropped
+	if cd.w.At(2, 0) != 0 || cd.w.At(1, 0) == 0 {
+		t.Error("Lasso did not select the right feature")
+	}
+
+	// With a large enough alpha, only the intercept is left, which is the
+	// mean of Y
+	cd.Alpha = 100
+	cd.Fit(X, Y)
+	if cd.w.At(1, 0) != 0 || !utils.Close(cd.w.At(0, 0), mat.Sum(Y)/8) {
+		t.Errorf("Lasso with large alpha gave %v", mat.Formatted(cd.w.T()))
+	}
+
+	// L1 needs an iterative solver, and settings are checked
+	bad := []LinearRegression{
+		{Solver: SolverQR, Penalty: PenaltyL1, Alpha: 1},
+		{Penalty: PenaltyElasticNet, Alpha: 1, L1Ratio: 2},
+		{Penalty: PenaltyL2, Alpha: -1},
+		{Penalty: "l3"},
+	}
+	for _, m := range bad {
+		if m.Fit(X, Y) == nil {
+			t.Errorf("Invalid settings accepted: %v %v", m.Solver, m.Penalty)
+		}
+	}
+}
+
+// Test regularization paths, coefficients shrink as alpha increases
+func TestRegularizationPath(t *testing.T) {
+	X, Y := penaltyData()
+	alphas := []float64{0, .1, 1, 10}
+	m := LinearRegression{Solver: SolverCD, Penalty: PenaltyElasticNet, L1Ratio: .7}
+	path, err := m.RegularizationPath(X, Y, alphas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(alphas); i++ {
+		if math.Abs(path.At(1, i)) > math.Abs(path.At(1, i-1)) {
+			t.Errorf("Coefficient grew with alpha: %v", mat.Formatted(path))
+		}
+	}
+
+	// Logistic regression, classify by the sign of the first feature
+	Yc := mat.NewDense(8, 1, []float64{0, 0, 0, 0, 1, 1, 1, 1})
+	lm := LogisticRegression{Penalty: PenaltyL2, LR: .5, Iterations: 2000}
+	path, err = lm.RegularizationPath(X, Yc, []float64{0, .1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(path.At(1, 0) > path.At(1, 1) && path.At(1, 1) > path.At(1, 2) && path.At(1, 2) > 0) {
+		t.Errorf("Logistic coefficients did not shrink: %v", mat.Formatted(path))
+	}
+}
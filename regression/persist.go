@@ -0,0 +1,89 @@
+// Saving and loading trained regression models, as JSON if the file name
+// ends in .json, otherwise in binary gob format
+
+package regression
+
+import (
+	"mlcode/utils"
+)
+
+// Versions of the saved data of each model, to be increased when it
+// changes in an incompatible way: 2 added the intercept column, and 3 the
+// multinomial flag of multi-class models
+const (
+	linearVersion   = 2
+	logisticVersion = 2
+	multiLogVersion = 3
+)
+
+// Saved data for a regression model: weights, intercept column, and class
+// labels for multi-class models trained using Fit
+type regressionData struct {
+	W           utils.MatrixData
+	Intercept   int // column of the intercept, -1 if none
+	Classes     []float64
+	Multinomial bool // softmax outputs, for multi-class models
+}
+
+// Save the trained model to a file
+func (m *LinearRegression) Save(filename string) error {
+	return utils.SaveModel(filename, "LinearRegression", linearVersion, regressionData{W: utils.ToMatrixData(m.w), Intercept: m.icept})
+}
+
+// Load a trained model from a file, replacing the weights and intercept
+func (m *LinearRegression) Load(filename string) error {
+	var d regressionData
+	if err := utils.LoadModel(filename, "LinearRegression", linearVersion, &d); err != nil {
+		return err
+	}
+	w, err := d.W.Dense()
+	if err != nil {
+		return err
+	}
+	m.w = w
+	m.icept = d.Intercept
+	return nil
+}
+
+// Save the trained model to a file
+func (m *LogisticRegression) Save(filename string) error {
+	return utils.SaveModel(filename, "LogisticRegression", logisticVersion, regressionData{W: utils.ToMatrixData(m.w), Intercept: m.icept})
+}
+
+// Load a trained model from a file, replacing the weights and intercept
+func (m *LogisticRegression) Load(filename string) error {
+	var d regressionData
+	if err := utils.LoadModel(filename, "LogisticRegression", logisticVersion, &d); err != nil {
+		return err
+	}
+	w, err := d.W.Dense()
+	if err != nil {
+		return err
+	}
+	m.w = w
+	m.icept = d.Intercept
+	return nil
+}
+
+// Save the trained model to a file
+func (m *MultiLogRegression) Save(filename string) error {
+	d := regressionData{W: utils.ToMatrixData(m.w), Intercept: m.icept, Classes: m.classes, Multinomial: m.Multinomial}
+	return utils.SaveModel(filename, "MultiLogRegression", multiLogVersion, d)
+}
+
+// Load a trained model from a file, replacing the weights and classes
+func (m *MultiLogRegression) Load(filename string) error {
+	var d regressionData
+	if err := utils.LoadModel(filename, "MultiLogRegression", multiLogVersion, &d); err != nil {
+		return err
+	}
+	w, err := d.W.Dense()
+	if err != nil {
+		return err
+	}
+	m.w = w
+	m.icept = d.Intercept
+	m.classes = d.Classes
+	m.Multinomial = d.Multinomial
+	return nil
+}
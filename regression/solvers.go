@@ -0,0 +1,87 @@
+// Closed-form least squares solvers for linear regression, which find the
+// exact coefficients in one step instead of by gradient descent. All
+// minimize the sum of squared errors |Xw - Y|^2.
+
+package regression
+
+import (
+	"errors"
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Method used to find the coefficients of a regression model
+type Solver string
+
+const (
+	SolverGD     Solver = "gd"     // gradient descent (the default)
+	SolverNormal Solver = "normal" // normal equations, using Cholesky decomposition
+	SolverQR     Solver = "qr"     // QR decomposition of X
+	SolverSVD    Solver = "svd"    // singular value decomposition of X
+	SolverCD     Solver = "cd"     // coordinate descent, for L1 and elastic net penalties
+	SolverNewton Solver = "newton" // Newton-Raphson (IRLS), for logistic regression
+	SolverLBFGS  Solver = "lbfgs"  // L-BFGS quasi-Newton, for logistic regression
+)
+
+// Relative size below which singular values are treated as zero by the
+// SVD solver, so that redundant columns get a minimum-norm solution
+const svdRcond = 1e-12
+
+// Solve the least squares problem using the given closed-form solver,
+// returns the coefficients as a column vector
+func leastSquares(X, Y *mat.Dense, solver Solver) (*mat.Dense, error) {
+	xr, xc := X.Dims()
+	w := mat.NewDense(xc, 1, nil)
+	switch solver {
+
+	// Normal equations: solve (X'X) w = X'Y. Fast, since X'X is small, but
+	// squares the condition number, so loses accuracy if columns are nearly
+	// collinear. Fails if X'X is singular.
+	// Python: np.linalg.solve(X.T @ X, X.T @ Y)
+	case SolverNormal:
+		var xtx mat.SymDense
+		xtx.SymOuterK(1, X.T())
+		var chol mat.Cholesky
+		if !chol.Factorize(&xtx) {
+			return nil, errors.New("normal equations: X'X is singular, try the svd solver")
+		}
+		var xty mat.Dense
+		xty.Mul(X.T(), Y)
+		if err := chol.SolveTo(w, &xty); err != nil {
+			return nil, fmt.Errorf("normal equations: %w", err)
+		}
+
+	// QR decomposition: X = QR, then solve Rw = Q'Y. More accurate than the
+	// normal equations, needs at least as many rows as columns and
+	// linearly independent columns.
+	case SolverQR:
+		if xr < xc {
+			return nil, errors.New("qr: fewer rows than columns, try the svd solver")
+		}
+		var qr mat.QR
+		qr.Factorize(X)
+		if err := qr.SolveTo(w, false, Y); err != nil {
+			return nil, fmt.Errorf("qr: %w", err)
+		}
+
+	// Singular value decomposition: the most robust, works even if columns
+	// are collinear or there are more columns than rows, in which case it
+	// gives the solution with the smallest coefficients.
+	// Python: np.linalg.lstsq(X, Y)
+	case SolverSVD:
+		var svd mat.SVD
+		if !svd.Factorize(X, mat.SVDThin) {
+			return nil, errors.New("svd: factorization failed")
+		}
+		rank := svd.Rank(svdRcond)
+		if rank == 0 {
+			return nil, errors.New("svd: X has rank zero")
+		}
+		svd.SolveTo(w, Y, rank)
+
+	default:
+		return nil, fmt.Errorf("unknown solver %q", solver)
+	}
+	return w, nil
+}
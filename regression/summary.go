@@ -0,0 +1,202 @@
+// Statistical summary of a trained linear regression model, similar to
+// summary(lm(...)) in R or OLS in Python's statsmodels: standard errors,
+// t-statistics and p-values for the coefficients, and measures of fit.
+// Assumes the exact ordinary least squares solution, so models trained with
+// a penalty, or by gradient or coordinate descent (which may not have
+// converged), are rejected, as their standard errors would not be valid.
+
+package regression
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// Confidence level for coefficient intervals in the summary
+const confLevel = .95
+
+// Summary statistics for one coefficient
+type CoefStats struct {
+	Name      string  // column name, or x0, x1, ...
+	Estimate  float64 // the coefficient
+	StdErr    float64 // standard error of the estimate
+	T         float64 // t-statistic, i.e., estimate / standard error
+	P         float64 // p-value for the t-statistic (two-sided)
+	Lower     float64 // lower bound of 95% confidence interval
+	Upper     float64 // upper bound of 95% confidence interval
+	Intercept bool    // true if this is an intercept column (all ones)
+}
+
+// Summary of a linear regression model
+type Summary struct {
+	Coefs    []CoefStats
+	N        int     // number of observations
+	DF       int     // residual degrees of freedom, N - number of coefficients
+	RSE      float64 // residual standard error
+	R2       float64 // R-squared
+	AdjR2    float64 // adjusted R-squared
+	F        float64 // F-statistic, for all coefficients (except intercept) being zero
+	FDF1     int     // degrees of freedom for F, numerator
+	FP       float64 // p-value for the F-statistic
+	LogLik   float64 // log-likelihood, assuming normal errors
+	AIC, BIC float64 // Akaike and Bayesian information criteria
+}
+
+// Calculate summary statistics for a trained model, using the training
+// data. Coefficient names are taken from m.Names if set, e.g., headings
+// from ReadMatrixCSV. If the model has an intercept column (all ones), R2
+// and F compare against a model with just the intercept, otherwise against
+// zero, as in R. Returns an error unless the model was trained without a
+// penalty, using SolverNormal, SolverQR or SolverSVD.
+func (m *LinearRegression) Summary(X, Y *mat.Dense) (*Summary, error) {
+	if m.w == nil {
+		return nil, errors.New("Summary: model has not been trained")
+	}
+	if m.Penalty != "" && m.Penalty != PenaltyNone {
+		return nil, fmt.Errorf("Summary: not valid for a model with %s penalty", m.Penalty)
+	}
+	if m.Solver != SolverNormal && m.Solver != SolverQR && m.Solver != SolverSVD {
+		return nil, errors.New("Summary: needs a model trained with the normal, qr or svd solver")
+	}
+	n, p := X.Dims()
+	if yr, _ := Y.Dims(); yr != n {
+		return nil, errors.New("Summary: X and Y have different number of rows")
+	}
+	df := n - p
+	if df <= 0 {
+		return nil, errors.New("Summary: need more rows than coefficients")
+	}
+	free := interceptCols(X)
+	hasIntercept := false
+	for _, f := range free {
+		hasIntercept = hasIntercept || f
+	}
+
+	// Residual sum of squares, and total sum of squares around the mean
+	// (or around zero if there is no intercept)
+	pred := m.Predict(X)
+	mean := 0.0
+	if hasIntercept {
+		mean = mat.Sum(Y) / float64(n)
+	}
+	var rss, tss float64
+	for i := 0; i < n; i++ {
+		e := Y.At(i, 0) - pred.At(i, 0)
+		rss += e * e
+		d := Y.At(i, 0) - mean
+		tss += d * d
+	}
+
+	// Covariance of the coefficients: sigma^2 (X'X)^-1
+	// Python: np.linalg.inv(X.T @ X) * rss / df
+	var xtx, cov mat.Dense
+	xtx.Mul(X.T(), X)
+	if err := cov.Inverse(&xtx); err != nil {
+		return nil, fmt.Errorf("Summary: X'X is singular, columns may be collinear: %w", err)
+	}
+	sigma2 := rss / float64(df)
+	cov.Scale(sigma2, &cov)
+
+	// Statistics for each coefficient
+	tdist := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: float64(df)}
+	tcrit := tdist.Quantile(1 - (1-confLevel)/2)
+	names := m.coefNames(free)
+	s := Summary{N: n, DF: df, RSE: math.Sqrt(sigma2)}
+	for j := 0; j < p; j++ {
+		c := CoefStats{Name: names[j], Estimate: m.w.At(j, 0), Intercept: free[j]}
+		c.StdErr = math.Sqrt(cov.At(j, j))
+		c.T = c.Estimate / c.StdErr
+		c.P = 2 * tdist.Survival(math.Abs(c.T))
+		c.Lower = c.Estimate - tcrit*c.StdErr
+		c.Upper = c.Estimate + tcrit*c.StdErr
+		s.Coefs = append(s.Coefs, c)
+	}
+
+	// R-squared, adjusted for the number of coefficients
+	s.R2 = 1 - rss/tss
+	dfTot := float64(n)
+	s.FDF1 = p
+	if hasIntercept {
+		dfTot--
+		s.FDF1--
+	}
+	s.AdjR2 = 1 - (1-s.R2)*dfTot/float64(df)
+
+	// F-statistic, only if there is something to test
+	if s.FDF1 > 0 {
+		s.F = ((tss - rss) / float64(s.FDF1)) / sigma2
+		fdist := distuv.F{D1: float64(s.FDF1), D2: float64(df)}
+		s.FP = fdist.Survival(s.F)
+	}
+
+	// Log-likelihood and information criteria, counting the error
+	// variance as a parameter, as in R's AIC()
+	nf := float64(n)
+	s.LogLik = -nf / 2 * (math.Log(2*math.Pi) + math.Log(rss/nf) + 1)
+	k := float64(p + 1)
+	s.AIC = -2*s.LogLik + 2*k
+	s.BIC = -2*s.LogLik + math.Log(nf)*k
+	return &s, nil
+}
+
+// Names for the coefficients: from m.Names if one per column, or one per
+// column other than the intercept, otherwise x0, x1, ...
+func (m *LinearRegression) coefNames(free []bool) []string {
+	names := make([]string, len(free))
+	nonIntercept := 0
+	for _, f := range free {
+		if !f {
+			nonIntercept++
+		}
+	}
+	next := 0 // next name to use, if intercept not named
+	for j := range names {
+		switch {
+		case len(m.Names) == len(free):
+			names[j] = m.Names[j]
+		case len(m.Names) == nonIntercept && free[j]:
+			names[j] = "(Intercept)"
+		case len(m.Names) == nonIntercept:
+			names[j] = m.Names[next]
+			next++
+		default:
+			names[j] = fmt.Sprintf("x%d", j)
+		}
+	}
+	return names
+}
+
+// Print the summary as a table, similar to R
+func (s *Summary) Print() {
+	fmt.Printf("%-15s %12s %12s %9s %10s %12s %12s\n", "", "Estimate", "Std. Error", "t value", "Pr(>|t|)", "2.5%", "97.5%")
+	for _, c := range s.Coefs {
+		fmt.Printf("%-15s %12.5g %12.5g %9.3f %10.4g %12.5g %12.5g %s\n",
+			c.Name, c.Estimate, c.StdErr, c.T, c.P, c.Lower, c.Upper, stars(c.P))
+	}
+	fmt.Println("---\nSignif. codes:  0 '***' 0.001 '**' 0.01 '*' 0.05 '.' 0.1 ' ' 1")
+	fmt.Printf("\nResidual standard error: %.4g on %d degrees of freedom\n", s.RSE, s.DF)
+	fmt.Printf("Multiple R-squared: %.4f,\tAdjusted R-squared: %.4f\n", s.R2, s.AdjR2)
+	if s.FDF1 > 0 {
+		fmt.Printf("F-statistic: %.4g on %d and %d DF,  p-value: %.4g\n", s.F, s.FDF1, s.DF, s.FP)
+	}
+	fmt.Printf("Log-likelihood: %.4f,  AIC: %.4f,  BIC: %.4f\n", s.LogLik, s.AIC, s.BIC)
+}
+
+// Significance stars for a p-value, as in R
+func stars(p float64) string {
+	switch {
+	case p < .001:
+		return "***"
+	case p < .01:
+		return "**"
+	case p < .05:
+		return "*"
+	case p < .1:
+		return "."
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+// Unit tests for the linear regression summary
+
+package regression
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Test summary statistics, against values from R:
+// summary(lm(y ~ x)) for x = 1..5, y = 2, 4, 5, 4, 5
+func TestSummary(t *testing.T) {
+	X := mat.NewDense(5, 2, []float64{1, 1, 1, 2, 1, 3, 1, 4, 1, 5})
+	Y := mat.NewDense(5, 1, []float64{2, 4, 5, 4, 5})
+	m := LinearRegression{Solver: SolverQR, Names: []string{"x"}}
+	if err := m.Fit(X, Y); err != nil {
+		t.Fatal(err)
+	}
+	s, err := m.Summary(X, Y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if testing.Verbose() {
+		s.Print()
+	}
+
+	close := func(name string, got, expect float64) {
+		if math.Abs(got-expect) > 1e-4 {
+			t.Errorf("Summary %s: got %f instead of %f", name, got, expect)
+		}
+	}
+	c := s.Coefs[1]
+	if s.Coefs[0].Name != "(Intercept)" || c.Name != "x" {
+		t.Errorf("Summary: wrong names %s, %s", s.Coefs[0].Name, c.Name)
+	}
+	close("intercept", s.Coefs[0].Estimate, 2.2)
+	close("intercept std err", s.Coefs[0].StdErr, 0.938083)
+	close("slope", c.Estimate, 0.6)
+	close("slope std err", c.StdErr, 0.282843)
+	close("t value", c.T, 2.121320)
+	close("p value", c.P, 0.123970)
+	close("lower", c.Lower, -0.300129)
+	close("upper", c.Upper, 1.500129)
+	close("RSE", s.RSE, 0.894427)
+	close("R2", s.R2, 0.6)
+	close("adjusted R2", s.AdjR2, 0.466667)
+	close("F", s.F, 4.5)
+	close("F p value", s.FP, 0.123970)
+	close("AIC", s.AIC, 16.519541)
+	close("BIC", s.BIC, 15.347848)
+
+	// Not enough rows
+	if _, err := m.Summary(mat.NewDense(2, 2, []float64{1, 1, 1, 2}), mat.NewDense(2, 1, nil)); err == nil {
+		t.Error("Summary should need more rows than coefficients")
+	}
+
+	// Penalized model
+	m = LinearRegression{Solver: SolverQR, Penalty: PenaltyL2, Alpha: 1}
+	if err := m.Fit(X, Y); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Summary(X, Y); err == nil {
+		t.Error("Summary should reject a model with a penalty")
+	}
+
+	// Model trained by gradient descent
+	m = LinearRegression{LR: .01}
+	m.Fit(X, Y)
+	if _, err := m.Summary(X, Y); err == nil {
+		t.Error("Summary should reject a model trained by gradient descent")
+	}
+}
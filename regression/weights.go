@@ -0,0 +1,36 @@
+// Sample weights for the logistic models, so that some rows count more
+// than others in the loss and gradient. The weights come from
+// utils.SampleWeights, and have a mean of 1.
+
+package regression
+
+import (
+	"mlcode/utils"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Multiply each row of a matrix by its weight, in place
+func scaleRows(m *mat.Dense, s []float64) {
+	if s == nil {
+		return
+	}
+	nr, _ := m.Dims()
+	for i := 0; i < nr; i++ {
+		row := m.RawRowView(i)
+		for j := range row {
+			row[j] *= s[i]
+		}
+	}
+}
+
+// Class label of each row of one-hot encoded Y, i.e., the column number
+// of the largest value
+func rowLabels(Y *mat.Dense) []float64 {
+	nr, _ := Y.Dims()
+	res := make([]float64, nr)
+	for i := range res {
+		res[i] = float64(utils.MaxCol(Y, i))
+	}
+	return res
+}
@@ -0,0 +1,81 @@
+// Unit tests for sample and class weights in the logistic models
+
+package regression
+
+import (
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+	"mlcode/utils"
+)
+
+// Test that a weight of 2 is the same as repeating a row, and that
+// balanced class weights help the smaller class
+func TestWeights(t *testing.T) {
+	X, Y := overlapData(60, 2)
+
+	// Repeat the first 20 rows, or give them a weight of 2
+	weights := make([]float64, 60)
+	X2 := mat.NewDense(80, 3, nil)
+	Y2 := mat.NewDense(80, 1, nil)
+	for i := 0; i < 80; i++ {
+		X2.SetRow(i, X.RawRowView(i%60))
+		Y2.Set(i, 0, Y.At(i%60, 0))
+	}
+	for i := range weights {
+		weights[i] = utils.IfThenElse(i < 20, 2.0, 1.0)
+	}
+	for _, s := range []Solver{SolverNewton, SolverGD} {
+		m1 := LogisticRegression{Solver: s, LR: .5, Tol: 1e-10, Iterations: 20000}
+		m2 := m1
+		if err := m1.FitWeighted(X, Y, weights); err != nil {
+			t.Fatal(err)
+		}
+		m2.Fit(X2, Y2)
+		t.Logf("%s weighted %v repeated %v", s, mat.Formatted(m1.w.T()), mat.Formatted(m2.w.T()))
+		if !mat.EqualApprox(m1.w, m2.w, 1e-6) {
+			t.Errorf("%s: weights different from repeated rows", s)
+		}
+	}
+
+	// Same for multi-class, and check the number of weights
+	m3 := MultiLogRegression{Solver: SolverNewton, Multinomial: true}
+	m4 := MultiLogRegression{Solver: SolverNewton, Multinomial: true}
+	m3.FitWeighted(X, Y, weights)
+	m4.Fit(X2, Y2)
+	if !mat.EqualApprox(m3.PredictProba(X), m4.PredictProba(X), 1e-8) {
+		t.Error("Multinomial: weights different from repeated rows")
+	}
+	if m3.FitWeighted(X, Y, weights[:10]) == nil {
+		t.Error("Accepted wrong number of weights")
+	}
+
+	// Imbalanced data: only 10% of rows in class 1, balanced weights
+	// should predict class 1 for more of them
+	Yi := mat.NewDense(60, 1, nil)
+	for i := 0; i < 60; i++ {
+		Yi.Set(i, 0, utils.IfThenElse(X.At(i, 1)+.3*X.At(i, 2) > 1.2, 1.0, 0.0))
+	}
+	recall := func(classWeight string) float64 {
+		m := LogisticRegression{Solver: SolverNewton, Penalty: PenaltyL2,
+			Alpha: .1, ClassWeight: classWeight}
+		m.Fit(X, Yi)
+		preds := m.Predict(X)
+		var tp, pos float64
+		for i := 0; i < 60; i++ {
+			if Yi.At(i, 0) == 1 {
+				pos++
+				tp += preds.At(i, 0)
+			}
+		}
+		return tp / pos
+	}
+	r1, r2 := recall(""), recall(utils.ClassWeightBalanced)
+	t.Logf("Recall without weights %v balanced %v", r1, r2)
+	if r2 <= r1 {
+		t.Error("Balanced weights did not improve recall")
+	}
+	if (&LogisticRegression{ClassWeight: "equal"}).Fit(X, Yi) == nil {
+		t.Error("Accepted unknown class weight")
+	}
+}
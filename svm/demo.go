@@ -4,6 +4,7 @@ package svm
 
 import (
 	"fmt"
+	"mlcode/metrics"
 	"mlcode/utils"
 
 	"gonum.org/v1/gonum/mat"
@@ -45,28 +46,33 @@ func SVMDemo() {
 
 	// TODO: Remove correlated or insignificant columns
 
-	// TODO: Split into test/train sets
-
 	// Convert dataframes X and Y to matrices
 	X := feats.ToMatrix()
 	dfY := utils.DataFrame{}
 	dfY = append(dfY, *diag)
 	Y := dfY.ToMatrix()
 
-	// Train the model, returns final weights
-	W := sgd(X, Y)
+	// Split into training and test sets, 20% for testing, stratified so
+	// both have the same proportion of malignant cases
+	Xtrain, Xtest, Ytrain, Ytest := utils.MatTrainTestSplit(X, Y, .2, true, 42)
+
+	// Train the model
+	m := SVM{Verbose: true}
+	m.Train(Xtrain, Ytrain)
 
-	// Make predictions and compare accuracy
-	nr, _ := X.Dims()
-	preds := mat.NewVecDense(nr, nil)
-	preds.MulVec(X, W)
+	// Make predictions and measure accuracy
+	preds := m.Predict(Xtest)
+	fmt.Println("Test accuracy =", metrics.Accuracy(metrics.Values(Ytest), metrics.Values(preds)))
 
-	// Measure accuracy
-	ok := 0
-	for i := 0; i < nr; i++ {
-		if utils.SameSign(preds.AtVec(i), Y.At(i, 0)) {
-			ok++
-		}
+	// Cross-validate a model with the same settings, using 5 folds
+	folds := utils.StratifiedKFold(metrics.Values(Y), 5, true, 42)
+	scores, err := utils.CrossValidate(func() utils.Estimator { return &SVM{} }, X, Y, folds,
+		func(actual, pred *mat.Dense) float64 {
+			return metrics.Accuracy(metrics.Values(actual), metrics.Values(pred))
+		})
+	if err != nil {
+		panic(err)
 	}
-	fmt.Println("Accuracy =", float64(ok)/float64(nr))
+	mean, std := utils.MeanStd(scores)
+	fmt.Printf("Cross-validated accuracy = %.4f (+/- %.4f)\n", mean, std)
 }
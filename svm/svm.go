@@ -15,67 +15,183 @@ package svm
 import (
 	"fmt"
 	"math"
+	"math/rand"
+	"mlcode/optimizer"
+	"mlcode/utils"
 
 	"gonum.org/v1/gonum/mat"
 )
 
-// Global hyperparameters
-var maxIterations int = 5000
-var regularizationStrength float64 = 10000
-var learningRate float64 = 0.000001
-var costThreshold float64 = 0.01 // stop when improvement less than this fraction
+// Structure for a Support Vector Machine model, zero values are replaced
+// by defaults when training
+type SVM struct {
+	Iterations     int     // maximum passes through the data, default 5000
+	Regularization float64 // regularization strength, default 10000
+	LR             float64 // learning rate, default 0.000001
+	Tol            float64 // stop when cost improves by less than this fraction, default 0.01
+	Verbose        bool    // messages during training, default false
+	w              *mat.Dense
+	classes        []float64 // the two class labels, if trained using Fit
+
+	// Optional settings for mini-batch training, default is stochastic
+	// gradient descent using LR, one row at a time
+	Optimizer optimizer.Optimizer // e.g., &optimizer.Adam{LR: .01}
+	BatchSize int                 // number of rows per batch, default 1
+	Shuffle   bool                // shuffle rows before each pass
+	Seed      int64               // random seed for shuffling
+
+	// Optional weighting of rows by class, utils.ClassWeightBalanced gives
+	// each class the same total weight, for imbalanced data. Can be
+	// combined with sample weights, see TrainWeighted.
+	ClassWeight string
+}
 
 // Train a Support Vector Machine model, using stochastic gradient descent.
-// Returns a vector of weights that can be used to predict.
-func sgd(X, Y *mat.Dense) *mat.VecDense {
+// Y values must be 1 or -1. Sets the vector of weights used to predict.
+func (m *SVM) Train(X, Y *mat.Dense) {
+	m.TrainWeighted(X, Y, nil)
+}
+
+// Train a Support Vector Machine model with a weight for each row of the
+// training data (nil for none), which is combined with the ClassWeight
+// setting. Rows with a higher weight count more in the hinge loss. Panics
+// if the weights are invalid, use FitWeighted to get an error instead.
+func (m *SVM) TrainWeighted(X, Y *mat.Dense, weights []float64) {
+	s, err := utils.SampleWeights(mat.Col(nil, 0, Y), weights, m.ClassWeight)
+	if err != nil {
+		panic("SVM: " + err.Error())
+	}
+
+	// Set model parameters if not set yet
+	if m.Iterations <= 0 {
+		m.Iterations = 5000
+	}
+	if m.Regularization <= 0 {
+		m.Regularization = 10000
+	}
+	if m.LR <= 0 {
+		m.LR = 0.000001
+	}
+	if m.Tol <= 0 {
+		m.Tol = 0.01
+	}
+	if m.BatchSize <= 0 {
+		m.BatchSize = 1
+	}
+	opt := m.Optimizer
+	if opt == nil {
+		opt = &optimizer.SGD{LR: m.LR}
+	}
+	rng := rand.New(rand.NewSource(m.Seed))
 
 	// Initialize weights as a vector of zeros
-	nr, nc := X.Dims()
-	W := mat.NewVecDense(nc, nil)
-	fmt.Printf("Initial cost = %f\n", computeCost(W, X, Y))
+	_, nc := X.Dims()
+	m.w = mat.NewDense(nc, 1, nil)
+	m.classes = nil
+	if m.Verbose {
+		fmt.Printf("Initial cost = %f\n", m.costWeighted(X, Y, s))
+	}
 
 	// Iterate until no more improvement, or maximum iterations
 	prevCost := math.MaxFloat64
-	for iter := 1; iter <= maxIterations; iter++ {
-
-		// Do each row, keep adjusting weights
-		// TODO: X, Y = shuffle(features, outputs)
-		for i := 0; i < nr; i++ {
+	for iter := 1; iter <= m.Iterations; iter++ {
 
-			// Get gradient for this row
-			x := X.RowView(i) // mat.Vector
-			y := Y.At(i, 0)   // float64
-			ascent := calculateCostGradient(W, &x, y)
-
-			// Python: W = W - (learningRate * ascent)
-			ascent.ScaleVec(learningRate, ascent)
-			W.SubVec(W, ascent)
-		}
+		// Do each batch of rows, keep adjusting weights
+		// Python: W = W - (learningRate * ascent)
+		optimizer.EpochWeighted(opt, m.w, X, Y, s, m.BatchSize, m.Shuffle, rng, m.gradientWeighted)
 
 		// Stop when converged, i.e., no more improvement
-		cost := computeCost(W, X, Y)
-		fmt.Printf("Iteration %d: cost = %f\n", iter, cost)
-		if math.Abs(prevCost-cost) < costThreshold*prevCost {
+		cost := m.costWeighted(X, Y, s)
+		if m.Verbose {
+			fmt.Printf("Iteration %d: cost = %f\n", iter, cost)
+		}
+		if math.Abs(prevCost-cost) < m.Tol*prevCost {
 			break
 		}
 		prevCost = cost
 	}
+}
 
-	// Return final weights
-	return W
+// Fit the model to training data, for the utils.Estimator interface. Y
+// must have exactly two class labels (e.g., 0/1 or -1/1), the lower one is
+// trained as -1 and the higher one as 1.
+func (m *SVM) Fit(X, Y *mat.Dense) error {
+	return m.FitWeighted(X, Y, nil)
 }
 
-// Compute cost gradient for training SVM
-// Assumes X and W are vectors (one row), Y is one number
-func calculateCostGradient(W *mat.VecDense, x *mat.Vector, y float64) *mat.VecDense {
+// Fit the model to training data with a weight for each row, see
+// TrainWeighted
+func (m *SVM) FitWeighted(X, Y *mat.Dense, weights []float64) error {
+	if err := utils.CheckXY(X, Y); err != nil {
+		return err
+	}
+	if _, err := utils.SampleWeights(mat.Col(nil, 0, Y), weights, m.ClassWeight); err != nil {
+		return fmt.Errorf("SVM: %w", err)
+	}
+	classes := utils.Classes(Y)
+	if len(classes) != 2 {
+		return fmt.Errorf("SVM: need exactly 2 classes, found %d", len(classes))
+	}
+	Y1 := mat.NewDense(utils.MatRows(Y), 1, nil)
+	Y1.Apply(func(i, j int, v float64) float64 {
+		return utils.IfThenElse(v == classes[1], 1.0, -1.0)
+	}, Y)
+	m.TrainWeighted(X, Y1, weights)
+	m.classes = classes
+	return nil
+}
+
+// Predict class labels, i.e., the sign of the raw scores (-1 or 1), or
+// the original labels if trained using Fit
+func (m *SVM) Predict(X *mat.Dense) *mat.Dense {
+	preds := m.Forward(X)
+	preds.Apply(func(i, j int, v float64) float64 {
+		if m.classes != nil {
+			return utils.IfThenElse(v >= 0, m.classes[1], m.classes[0])
+		}
+		return utils.IfThenElse(v >= 0, 1.0, -1.0)
+	}, preds)
+	return preds
+}
+
+// Predict raw scores, the sign of each is the predicted class
+func (m *SVM) Forward(X *mat.Dense) *mat.Dense {
+	nr, _ := X.Dims()
+	res := mat.NewDense(nr, 1, nil)
+	res.Mul(X, m.w)
+	return res
+}
+
+// Compute cost gradient for training SVM, averaged over the rows of a batch
+func (m *SVM) Gradient(X, Y *mat.Dense) *mat.Dense {
+	return m.gradientWeighted(X, Y, nil)
+}
+
+// Compute cost gradient, with the hinge loss of each row multiplied by its
+// weight (nil for none)
+func (m *SVM) gradientWeighted(X, Y *mat.Dense, s []float64) *mat.Dense {
+	nr, nc := X.Dims()
+	dw := mat.NewDense(nc, 1, nil)
+	for i := 0; i < nr; i++ {
+		x := X.RowView(i) // mat.Vector
+		y := Y.At(i, 0)   // float64
+		dw.Add(dw, m.rowGradient(x, y, utils.Weight(s, i)))
+	}
+	dw.Scale(1/float64(nr), dw)
+	return dw
+}
+
+// Compute cost gradient for one row, with the given weight
+// Assumes x is a vector (one row), y is one number
+func (m *SVM) rowGradient(x mat.Vector, y, weight float64) *mat.Dense {
 
 	// Calculate total distance
 	// Python: d = 1 - (Y * np.sum(X * W))
 	// was: distance = 1 - (Y_batch * np.dot(X_batch, W))
-	nc := (*x).Len()
+	nc := x.Len()
 	var dist float64
 	for i := 0; i < nc; i++ { // equivalent to: Y * np.sum(X * W)
-		dist += W.AtVec(i) * (*x).AtVec(i) * y
+		dist += m.w.At(i, 0) * x.AtVec(i) * y
 	}
 	if dist < 1 {
 		dist = 1 - dist
@@ -87,10 +203,10 @@ func calculateCostGradient(W *mat.VecDense, x *mat.Vector, y float64) *mat.VecDe
 	// dw = np.zeros(len(W))
 	// if dist > 0:
 	//     dw = W - (regularization_strength * Y * X)
-	dw := mat.NewVecDense(nc, nil) // 31 x 1
+	dw := mat.NewDense(nc, 1, nil) // 31 x 1
 	if dist > 0 {                  // dist no longer used!
 		for i := 0; i < nc; i++ {
-			dw.SetVec(i, W.AtVec(i)-regularizationStrength*y*(*x).AtVec(i))
+			dw.Set(i, 0, m.w.At(i, 0)-m.Regularization*weight*y*x.AtVec(i))
 		}
 	}
 
@@ -99,14 +215,19 @@ func calculateCostGradient(W *mat.VecDense, x *mat.Vector, y float64) *mat.VecDe
 }
 
 // Compute cost for SVM
-func computeCost(W *mat.VecDense, X, Y *mat.Dense) float64 {
+func (m *SVM) Cost(X, Y *mat.Dense) float64 {
+	return m.costWeighted(X, Y, nil)
+}
+
+// Compute cost, with the hinge loss of each row multiplied by its weight
+// (nil for none)
+func (m *SVM) costWeighted(X, Y *mat.Dense, s []float64) float64 {
 
 	// Calculate distances
 	// Python: distances = 1 - Y * np.dot(X, W)
 	// distances[distances < 0] = 0  # i.e., max(0, distance)
 	nr, nc := X.Dims()
-	dist := mat.NewDense(nr, 1, nil)
-	dist.Mul(X, W)
+	dist := m.Forward(X)
 	dist.MulElem(Y, dist)
 	dist.Apply(func(i, j int, v float64) float64 {
 		if v < 1 {
@@ -119,18 +240,49 @@ func computeCost(W *mat.VecDense, X, Y *mat.Dense) float64 {
 	// Python: sumDistances = np.sum(distances)
 	var sumDist float64
 	for i := 0; i < nr; i++ {
-		sumDist += dist.At(i, 0)
+		sumDist += utils.Weight(s, i) * dist.At(i, 0)
 	}
 
 	// Calculate dot(W, W)
 	var cost float64 = 0
 	for c := 0; c < nc; c++ {
-		w := W.AtVec(c)
+		w := m.w.At(c, 0)
 		cost += w * w
 	}
 
 	// Calculate cost
 	// Python: cost = 1 / 2 * np.dot(W, W) + hinge_loss
-	hingeLoss := regularizationStrength * (sumDist / float64(nr))
+	hingeLoss := m.Regularization * (sumDist / float64(nr))
 	return cost/2 + hingeLoss
 }
+
+// Version of the saved data of an SVM model, to be increased when it
+// changes in an incompatible way
+const svmVersion = 1
+
+// Saved data for an SVM model
+type svmData struct {
+	W       utils.MatrixData
+	Classes []float64
+}
+
+// Save the trained model to a file, as JSON if the file name ends in
+// .json, otherwise in binary gob format
+func (m *SVM) Save(filename string) error {
+	return utils.SaveModel(filename, "SVM", svmVersion, svmData{W: utils.ToMatrixData(m.w), Classes: m.classes})
+}
+
+// Load a trained model from a file, replacing the weights and classes
+func (m *SVM) Load(filename string) error {
+	var d svmData
+	if err := utils.LoadModel(filename, "SVM", svmVersion, &d); err != nil {
+		return err
+	}
+	w, err := d.W.Dense()
+	if err != nil {
+		return err
+	}
+	m.w = w
+	m.classes = d.Classes
+	return nil
+}
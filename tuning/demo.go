@@ -0,0 +1,85 @@
+// Demo of hyperparameter search, using the iris data set
+
+package tuning
+
+import (
+	"fmt"
+	"mlcode/decision_tree"
+	"mlcode/metrics"
+	"mlcode/regression"
+	"mlcode/utils"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TuningDemo() {
+
+	// Read the iris data set, and convert variety to a numeric label
+	df, err := utils.ReadCSV("data/iris.csv")
+	if err != nil {
+		panic("Could not find data set")
+	}
+	variety := df.GetColumn("variety")
+	codes := map[string]float64{}
+	for i, v := range utils.Unique(variety.Strings) {
+		codes[v] = float64(i)
+	}
+	labels := make([]float64, len(variety.Strings))
+	for i, v := range variety.Strings {
+		labels[i] = codes[v]
+	}
+	feats := df.DropColumns([]string{"variety"})
+	X := feats.ToMatrix()
+	Y := mat.NewDense(len(labels), 1, labels)
+
+	// Score each fold by accuracy, using 5 stratified folds
+	accuracy := func(actual, pred *mat.Dense) float64 {
+		return metrics.Accuracy(metrics.Values(actual), metrics.Values(pred))
+	}
+	folds := utils.StratifiedKFold(labels, 5, true, 42)
+
+	// Grid search over decision tree depth and minimum leaf size
+	fmt.Println("Grid search, decision tree:")
+	s := Search{
+		Space: Space{
+			"MaxDepth": IntRange{Min: 1, Max: 5},
+			"MinLeaf":  Values{1, 5, 10, 20},
+		},
+		NewModel: func(p Params) (utils.Estimator, error) {
+			params := decision_tree.TreeParams{MaxDepth: p.Int("MaxDepth"), MinLeaf: p.Int("MinLeaf")}
+			return &decision_tree.TreeClassifier{Params: params}, nil
+		},
+		Folds: folds,
+		Score: accuracy,
+	}
+	res, err := s.Grid(X, Y)
+	if err != nil {
+		panic(err)
+	}
+	res.Print()
+
+	// Random search over learning rate and iterations for multi-class
+	// logistic regression, which needs an intercept column
+	fmt.Println("\nRandom search, multi-class logistic regression:")
+	Xi := utils.PrependBias(X)
+	s = Search{
+		Space: Space{
+			"LR":         LogUniform{Min: 1e-4, Max: 1},
+			"Iterations": IntRange{Min: 100, Max: 2000, Step: 100},
+		},
+		NewModel: func(p Params) (utils.Estimator, error) {
+			m := &regression.MultiLogRegression{LR: p.Float("LR"), Iterations: p.Int("Iterations")}
+			if err := m.Validate(); err != nil {
+				return nil, err
+			}
+			return m, nil
+		},
+		Folds: folds,
+		Score: accuracy,
+	}
+	res, err = s.Random(Xi, Y, 20, 42)
+	if err != nil {
+		panic(err)
+	}
+	res.Print()
+}
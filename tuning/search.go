@@ -0,0 +1,159 @@
+// Hyperparameter search: try combinations of hyperparameters, scoring each
+// one by cross-validation, to find the best configuration for a model.
+// Similar to GridSearchCV and RandomizedSearchCV in scikit-learn. Trials
+// are run in parallel goroutines.
+
+package tuning
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"mlcode/utils"
+	"runtime"
+	"sort"
+	"sync"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Settings for a hyperparameter search
+type Search struct {
+	Space    Space                                   // hyperparameters to search
+	NewModel func(p Params) (utils.Estimator, error) // create a model with the given hyperparameters
+	Folds    []utils.Fold                            // cross-validation folds
+	Score    func(actual, pred *mat.Dense) float64   // score for one fold, higher is better
+	Workers  int                                     // number of trials to run at once, default number of CPUs
+	Verbose  bool                                    // print each trial as it finishes
+}
+
+// Result of one combination of hyperparameters
+type Trial struct {
+	Params    Params    // hyperparameters
+	Scores    []float64 // score on each fold
+	Mean, Std float64   // mean and standard deviation of scores
+	Err       error     // error if the model could not be created or trained
+}
+
+// Results of a search, trials are sorted from best to worst mean score,
+// with failed trials at the end
+type Result struct {
+	Best   Trial
+	Trials []Trial
+}
+
+// Try every combination of the grid values of each hyperparameter
+func (s *Search) Grid(X, Y *mat.Dense) (*Result, error) {
+	if err := s.Space.check(); err != nil {
+		return nil, err
+	}
+	return s.run(X, Y, s.Space.grid())
+}
+
+// Try n random combinations of hyperparameters, sampled from their
+// distributions using the given random seed
+func (s *Search) Random(X, Y *mat.Dense, n int, seed int64) (*Result, error) {
+	if err := s.Space.check(); err != nil {
+		return nil, err
+	}
+	rng := rand.New(rand.NewSource(seed))
+	combos := []Params{}
+	for i := 0; i < n; i++ {
+		combos = append(combos, s.Space.sample(rng))
+	}
+	return s.run(X, Y, combos)
+}
+
+// Cross-validate each combination of hyperparameters, using a pool of
+// worker goroutines, and sort the results
+func (s *Search) run(X, Y *mat.Dense, combos []Params) (*Result, error) {
+	if s.NewModel == nil || s.Score == nil {
+		return nil, errors.New("Search: NewModel and Score are required")
+	}
+	if len(s.Folds) == 0 {
+		return nil, errors.New("Search: no cross-validation folds")
+	}
+	if len(combos) == 0 {
+		return nil, errors.New("Search: no hyperparameters to try")
+	}
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	// Send trial numbers to the workers, each worker saves its result in
+	// the slot for that trial, so no locking is needed
+	trials := make([]Trial, len(combos))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex // only used to keep verbose output tidy
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				trials[i] = s.trial(X, Y, combos[i])
+				if s.Verbose {
+					mu.Lock()
+					fmt.Println(trials[i].format())
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range combos {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Sort by mean score, best first, failures last; stable so that ties
+	// keep the order they were tried in
+	sort.SliceStable(trials, func(i, j int) bool {
+		if (trials[i].Err == nil) != (trials[j].Err == nil) {
+			return trials[i].Err == nil
+		}
+		return trials[i].Mean > trials[j].Mean
+	})
+	if trials[0].Err != nil {
+		return nil, fmt.Errorf("Search: all trials failed, e.g., %w", trials[0].Err)
+	}
+	return &Result{Best: trials[0], Trials: trials}, nil
+}
+
+// Cross-validate one combination of hyperparameters
+func (s *Search) trial(X, Y *mat.Dense, p Params) Trial {
+	t := Trial{Params: p}
+
+	// Create one model first to check the hyperparameters are valid
+	if _, err := s.NewModel(p); err != nil {
+		t.Err = err
+		return t
+	}
+	newModel := func() utils.Estimator {
+		m, _ := s.NewModel(p)
+		return m
+	}
+	t.Scores, t.Err = utils.CrossValidate(newModel, X, Y, s.Folds, s.Score)
+	if t.Err == nil {
+		t.Mean, t.Std = utils.MeanStd(t.Scores)
+	}
+	return t
+}
+
+// Format one trial as a line of text
+func (t Trial) format() string {
+	if t.Err != nil {
+		return fmt.Sprintf("%-10s %-10s %s (%v)", "error", "", t.Params.format(), t.Err)
+	}
+	return fmt.Sprintf("%-10.4f %-10.4f %s", t.Mean, t.Std, t.Params.format())
+}
+
+// Print the best configuration, and a table of all trials
+func (r *Result) Print() {
+	fmt.Printf("Best: %s, score %.4f (+/- %.4f)\n", r.Best.Params.format(), r.Best.Mean, r.Best.Std)
+	fmt.Printf("%-10s %-10s %s\n", "Mean", "Std", "Parameters")
+	for _, t := range r.Trials {
+		fmt.Println(t.format())
+	}
+}
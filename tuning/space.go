@@ -0,0 +1,230 @@
+// Parameter spaces for hyperparameter search: each hyperparameter has a
+// distribution, which gives a list of values for grid search, and draws
+// random values for random search.
+
+package tuning
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Distribution of values for one hyperparameter
+type Distribution interface {
+	Grid() []any               // values to try in a grid search
+	Sample(rng *rand.Rand) any // random value for a random search
+}
+
+// Parameter space: distribution for each hyperparameter, by name
+type Space map[string]Distribution
+
+// Values of the hyperparameters for one model, by name
+type Params map[string]any
+
+// A fixed list of values (of any type), sampled with equal probability
+type Values []any
+
+func (v Values) Grid() []any {
+	return v
+}
+
+func (v Values) Sample(rng *rand.Rand) any {
+	return v[rng.Intn(len(v))]
+}
+
+// Integers from Min to Max inclusive, in steps of Step (default 1)
+type IntRange struct {
+	Min, Max, Step int
+}
+
+func (r IntRange) Grid() []any {
+	step := r.Step
+	if step <= 0 {
+		step = 1
+	}
+	vals := []any{}
+	for i := r.Min; i <= r.Max; i += step {
+		vals = append(vals, i)
+	}
+	return vals
+}
+
+func (r IntRange) Sample(rng *rand.Rand) any {
+	step := r.Step
+	if step <= 0 {
+		step = 1
+	}
+	return r.Min + step*rng.Intn((r.Max-r.Min)/step+1)
+}
+
+// Floating point numbers uniformly distributed between Min and Max, grid
+// search uses N evenly spaced values (default 5)
+type Uniform struct {
+	Min, Max float64
+	N        int
+}
+
+func (u Uniform) Grid() []any {
+	n := gridPoints(u.N)
+	vals := []any{}
+	for i := 0; i < n; i++ {
+		vals = append(vals, u.Min+(u.Max-u.Min)*float64(i)/float64(n-1))
+	}
+	return vals
+}
+
+func (u Uniform) Sample(rng *rand.Rand) any {
+	return u.Min + rng.Float64()*(u.Max-u.Min)
+}
+
+// Positive numbers between Min and Max whose logarithm is uniformly
+// distributed, e.g., learning rates from 1e-5 to 1. Grid search uses N
+// values evenly spaced on a log scale (default 5).
+type LogUniform struct {
+	Min, Max float64
+	N        int
+}
+
+func (u LogUniform) Grid() []any {
+	n := gridPoints(u.N)
+	lmin, lmax := math.Log(u.Min), math.Log(u.Max)
+	vals := []any{}
+	for i := 0; i < n; i++ {
+		vals = append(vals, math.Exp(lmin+(lmax-lmin)*float64(i)/float64(n-1)))
+	}
+	return vals
+}
+
+func (u LogUniform) Sample(rng *rand.Rand) any {
+	lmin, lmax := math.Log(u.Min), math.Log(u.Max)
+	return math.Exp(lmin + rng.Float64()*(lmax-lmin))
+}
+
+// Number of grid points, at least 2
+func gridPoints(n int) int {
+	if n <= 0 {
+		return 5
+	}
+	if n < 2 {
+		return 2
+	}
+	return n
+}
+
+// Names of the hyperparameters in a space, sorted so that searches are
+// repeatable
+func (s Space) names() []string {
+	names := []string{}
+	for n := range s {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check that no distribution in the space is empty, as it would have no
+// values to try
+func (s Space) check() error {
+	for _, name := range s.names() {
+		empty := false
+		switch d := s[name].(type) {
+		case Values:
+			empty = len(d) == 0
+		case IntRange:
+			empty = d.Max < d.Min
+		case Uniform:
+			empty = d.Max < d.Min
+		case LogUniform:
+			empty = d.Min <= 0 || d.Max < d.Min
+		}
+		if empty {
+			return fmt.Errorf("Search: no values for %s", name)
+		}
+	}
+	return nil
+}
+
+// All combinations of grid values
+func (s Space) grid() []Params {
+	combos := []Params{{}}
+	for _, name := range s.names() {
+		next := []Params{}
+		for _, p := range combos {
+			for _, v := range s[name].Grid() {
+				p2 := Params{}
+				for k, x := range p {
+					p2[k] = x
+				}
+				p2[name] = v
+				next = append(next, p2)
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// Random sample of parameters
+func (s Space) sample(rng *rand.Rand) Params {
+	p := Params{}
+	for _, name := range s.names() {
+		p[name] = s[name].Sample(rng)
+	}
+	return p
+}
+
+// Get a hyperparameter as an integer
+func (p Params) Int(name string) int {
+	switch v := p[name].(type) {
+	case int:
+		return v
+	case float64:
+		return int(math.Round(v))
+	default:
+		panic(fmt.Sprintf("Params: %s is %T, not a number", name, p[name]))
+	}
+}
+
+// Get a hyperparameter as a floating point number
+func (p Params) Float(name string) float64 {
+	switch v := p[name].(type) {
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	default:
+		panic(fmt.Sprintf("Params: %s is %T, not a number", name, p[name]))
+	}
+}
+
+// Get a hyperparameter as a string
+func (p Params) String(name string) string {
+	s, ok := p[name].(string)
+	if !ok {
+		panic(fmt.Sprintf("Params: %s is %T, not a string", name, p[name]))
+	}
+	return s
+}
+
+// Format parameters as name=value pairs, sorted by name
+func (p Params) format() string {
+	names := []string{}
+	for n := range p {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	s := ""
+	for i, n := range names {
+		if i > 0 {
+			s += " "
+		}
+		if f, ok := p[n].(float64); ok {
+			s += fmt.Sprintf("%s=%.4g", n, f)
+		} else {
+			s += fmt.Sprintf("%s=%v", n, p[n])
+		}
+	}
+	return s
+}
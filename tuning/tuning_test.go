@@ -0,0 +1,112 @@
+// Unit tests for hyperparameter search
+
+package tuning
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"mlcode/utils"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Model that always predicts the same value, to test searches
+type constModel struct{ c float64 }
+
+func (m *constModel) Fit(X, Y *mat.Dense) error {
+	return nil
+}
+
+func (m *constModel) Predict(X *mat.Dense) *mat.Dense {
+	res := mat.NewDense(utils.MatRows(X), 1, nil)
+	for i := 0; i < utils.MatRows(X); i++ {
+		res.Set(i, 0, m.c)
+	}
+	return res
+}
+
+// Test the parameter distributions
+func TestSpace(t *testing.T) {
+	if g := (IntRange{Min: 2, Max: 8, Step: 3}).Grid(); len(g) != 3 || g[2] != 8 {
+		t.Errorf("IntRange grid wrong: %v", g)
+	}
+	if g := (Uniform{Min: 0, Max: 1, N: 3}).Grid(); len(g) != 3 || g[1] != .5 {
+		t.Errorf("Uniform grid wrong: %v", g)
+	}
+	g := (LogUniform{Min: 1e-3, Max: 1, N: 4}).Grid()
+	if len(g) != 4 || !utils.Close(g[1].(float64), 1e-2) || !utils.Close(g[3].(float64), 1) {
+		t.Errorf("LogUniform grid wrong: %v", g)
+	}
+
+	// Random samples are within range
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		x := (LogUniform{Min: 1e-4, Max: 1e-2}).Sample(rng).(float64)
+		n := (IntRange{Min: 1, Max: 3}).Sample(rng).(int)
+		if x < 1e-4 || x > 1e-2 || n < 1 || n > 3 {
+			t.Errorf("Samples out of range: %f, %d", x, n)
+		}
+		if k := (IntRange{Min: 2, Max: 9, Step: 3}).Sample(rng).(int); k != 2 && k != 5 && k != 8 {
+			t.Errorf("Sample not on the grid: %d", k)
+		}
+	}
+
+	// Grid of all combinations
+	s := Space{"a": Values{"x", "y"}, "b": IntRange{Min: 1, Max: 3}}
+	if combos := s.grid(); len(combos) != 6 || combos[5].String("a") != "y" || combos[5].Int("b") != 3 {
+		t.Errorf("Wrong combinations: %v", combos)
+	}
+}
+
+// Test grid and random search, the best constant is the one closest to
+// all the Y values
+func TestSearch(t *testing.T) {
+	X := mat.NewDense(10, 1, nil)
+	Y := mat.NewDense(10, 1, nil)
+	for i := 0; i < 10; i++ {
+		Y.Set(i, 0, 2)
+	}
+	s := Search{
+		Space: Space{"C": Values{0.0, 1.0, 2.0, 3.0, -1.0}},
+		NewModel: func(p Params) (utils.Estimator, error) {
+			if p.Float("C") < 0 {
+				return nil, errors.New("C must not be negative")
+			}
+			return &constModel{c: p.Float("C")}, nil
+		},
+		Folds: utils.KFold(10, 5, true, 1),
+		Score: func(actual, pred *mat.Dense) float64 {
+			return -math.Abs(actual.At(0, 0) - pred.At(0, 0))
+		},
+		Workers: 3,
+	}
+	res, err := s.Grid(X, Y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Best.Params.Float("C") != 2 || len(res.Trials) != 5 || res.Trials[4].Err == nil {
+		t.Errorf("Grid search gave wrong result")
+		res.Print()
+	}
+
+	// Random search over a continuous range
+	s.Space = Space{"C": Uniform{Min: 0, Max: 4}}
+	res, err = s.Random(X, Y, 30, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(res.Best.Params.Float("C")-2) > .3 || len(res.Trials) != 30 {
+		t.Errorf("Random search gave wrong result: %v", res.Best)
+	}
+
+	// Empty ranges are rejected before any trial
+	s.Space = Space{"C": IntRange{Min: 3, Max: 1}}
+	if _, err := s.Random(X, Y, 5, 1); err == nil {
+		t.Error("Random search accepted an empty range")
+	}
+	if _, err := s.Grid(X, Y); err == nil {
+		t.Error("Grid search accepted an empty range")
+	}
+}
@@ -62,7 +62,7 @@ func ReadCSV(filename string) (*DataFrame, error) {
 			}
 		} else {
 			if len(l) != len(df) {
-				fmt.Println("WARNING: row %d has %d instead of %d columns, ignored\n", line_no, len(l), len(df))
+				fmt.Printf("WARNING: row %d has %d instead of %d columns, ignored\n", line_no, len(l), len(df))
 			}
 			for i, c := range l {
 				df[i].Strings = append(df[i].Strings, c)
@@ -219,6 +219,33 @@ func (df *DataFrame) GetRow(row int) *DataFrame {
 	return &df2
 }
 
+// Values of a column as strings, for grouping rows by value
+func (s *Series) Keys() []string {
+	keys := make([]string, 0, len(s.Ints)+len(s.Floats)+len(s.Strings))
+	for _, v := range s.Ints {
+		keys = append(keys, fmt.Sprint(v))
+	}
+	for _, v := range s.Floats {
+		keys = append(keys, fmt.Sprint(v))
+	}
+	return append(keys, s.Strings...)
+}
+
+// Is value i of a column missing? That is, the MISSING_INT or
+// MISSING_FLOAT value (as set by ReadCSV for empty values), NaN, or an
+// empty string.
+func (s *Series) IsMissing(i int) bool {
+	switch s.Dtype {
+	case "int64":
+		return s.Ints[i] == MISSING_INT
+	case "float64":
+		return s.Floats[i] == MISSING_FLOAT || math.IsNaN(s.Floats[i])
+	case "string":
+		return s.Strings[i] == ""
+	}
+	return false
+}
+
 // Show summary, i.e., number of rows, column descriptors
 func (df *DataFrame) Summary() {
 	fmt.Printf("Dataframe with %d rows, %d cols:\n", df.NRows(), len(*df))
@@ -298,3 +325,22 @@ func (df *DataFrame) ToMatrix() *mat.Dense {
 	// Convert to matrix
 	return mat.NewDense(nrows, len(cols), nums)
 }
+
+// Create a dataframe from a matrix, with one float64 column per matrix
+// column. Column names are taken from the list, or x0, x1, etc. if nil.
+func FromMatrix(m *mat.Dense, names []string) *DataFrame {
+	nr, nc := m.Dims()
+	df := DataFrame{}
+	for c := 0; c < nc; c++ {
+		name := fmt.Sprintf("x%d", c)
+		if names != nil {
+			name = names[c]
+		}
+		col := Series{Name: name, Dtype: "float64", Floats: make([]float64, nr)}
+		for r := 0; r < nr; r++ {
+			col.Floats[r] = m.At(r, c)
+		}
+		df = append(df, col)
+	}
+	return &df
+}
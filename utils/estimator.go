@@ -0,0 +1,43 @@
+// Common interface for all models, so they can be used interchangeably
+// by generic tools such as cross-validation and grid search
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Interface implemented by all models. X has one row per instance, Y and
+// the predictions are column vectors with the target value or class label
+// for each row (class labels are numbers, e.g., 0/1 or 0-9).
+type Estimator interface {
+	Fit(X, Y *mat.Dense) error
+	Predict(X *mat.Dense) *mat.Dense
+}
+
+// Classifiers can also predict the probability of each class, one column
+// per class, in the order of the sorted class labels
+type Classifier interface {
+	Estimator
+	PredictProba(X *mat.Dense) *mat.Dense
+}
+
+// Check that X and Y are suitable for fitting a model, i.e., not empty,
+// same number of rows, and Y is a single column
+func CheckXY(X, Y *mat.Dense) error {
+	if X == nil || Y == nil || X.IsEmpty() || Y.IsEmpty() {
+		return errors.New("no data to fit")
+	}
+	xr, _ := X.Dims()
+	yr, yc := Y.Dims()
+	if xr != yr {
+		return fmt.Errorf("X has %d rows but Y has %d", xr, yr)
+	}
+	if yc != 1 {
+		return fmt.Errorf("Y must be a single column, has %d", yc)
+	}
+	return nil
+}
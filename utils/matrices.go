@@ -168,3 +168,47 @@ func WriteMatrix(m *mat.Dense, filename string) {
 	f.Close()
 	fmt.Println("  finished")
 }
+
+// First column of a matrix, as a list
+func matColumn(m *mat.Dense) []float64 {
+	nr, _ := m.Dims()
+	vals := make([]float64, nr)
+	for i := 0; i < nr; i++ {
+		vals[i] = m.At(i, 0)
+	}
+	return vals
+}
+
+// Sorted list of the unique values in the first column of a matrix,
+// e.g., the class labels
+func Classes(Y *mat.Dense) []float64 {
+	return Unique(matColumn(Y))
+}
+
+// One-hot encode a column of class labels, using the given list of
+// classes: result has one column per class, with a 1 in the column for
+// the label of each row
+func OneHot(Y *mat.Dense, classes []float64) *mat.Dense {
+	nr, _ := Y.Dims()
+	result := mat.NewDense(nr, len(classes), nil)
+	for i := 0; i < nr; i++ {
+		for j, c := range classes {
+			if Y.At(i, 0) == c {
+				result.Set(i, j, 1)
+			}
+		}
+	}
+	return result
+}
+
+// Convert a matrix with one column per class (e.g., probabilities) to a
+// column vector of class labels, taking the column with the highest
+// value in each row
+func ArgMaxLabels(P *mat.Dense, classes []float64) *mat.Dense {
+	nr, _ := P.Dims()
+	result := mat.NewDense(nr, 1, nil)
+	for r := 0; r < nr; r++ {
+		result.Set(r, 0, classes[MaxCol(P, r)])
+	}
+	return result
+}
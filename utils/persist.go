@@ -0,0 +1,150 @@
+// Saving and loading trained models, either as JSON (readable) or in
+// binary gob format (compact). Each file starts with the kind of model
+// and the version of its saved data, which are checked when loading. Each
+// kind of model has its own version, to be increased when its saved data
+// changes in an incompatible way, so that older files are rejected rather
+// than loading with zero values for the fields they lack.
+
+package utils
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Contents of a model file: header, plus the model data
+type modelFile struct {
+	Kind    string // type of model, e.g., "LinearRegression"
+	Version int    // version of the saved data for this kind of model
+	Data    json.RawMessage
+}
+
+// Header of a gob model file, the model data follows
+type modelHeader struct {
+	Kind    string
+	Version int
+}
+
+// Save model data to a file, as JSON if the file name ends in .json,
+// otherwise in binary gob format. The kind identifies the type of model,
+// and version the version of its saved data.
+func SaveModel(filename, kind string, version int, data any) error {
+
+	// Encode the header and data
+	var buf bytes.Buffer
+	if isJSON(filename) {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("SaveModel: %w", err)
+		}
+		f := modelFile{Kind: kind, Version: version, Data: raw}
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f); err != nil {
+			return fmt.Errorf("SaveModel: %w", err)
+		}
+	} else {
+		enc := gob.NewEncoder(&buf)
+		if err := enc.Encode(modelHeader{Kind: kind, Version: version}); err != nil {
+			return fmt.Errorf("SaveModel: %w", err)
+		}
+		if err := enc.Encode(data); err != nil {
+			return fmt.Errorf("SaveModel: %w", err)
+		}
+	}
+
+	// Write to the file
+	return os.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+// Load model data from a file saved by SaveModel, into data (a pointer).
+// Returns an error if the file is for a different kind of model, or was
+// saved with a different version of its data.
+func LoadModel(filename, kind string, version int, data any) error {
+
+	// Read the file
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	// Decode the header, check it, then decode the data
+	if isJSON(filename) {
+		var f modelFile
+		if err := json.Unmarshal(b, &f); err != nil {
+			return fmt.Errorf("LoadModel: %s: %w", filename, err)
+		}
+		if err := checkHeader(filename, kind, version, f.Kind, f.Version); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(f.Data, data); err != nil {
+			return fmt.Errorf("LoadModel: %s: %w", filename, err)
+		}
+	} else {
+		dec := gob.NewDecoder(bytes.NewReader(b))
+		var h modelHeader
+		if err := dec.Decode(&h); err != nil {
+			return fmt.Errorf("LoadModel: %s: %w", filename, err)
+		}
+		if err := checkHeader(filename, kind, version, h.Kind, h.Version); err != nil {
+			return err
+		}
+		if err := dec.Decode(data); err != nil {
+			return fmt.Errorf("LoadModel: %s: %w", filename, err)
+		}
+	}
+	return nil
+}
+
+// Check the kind of model and version in a file header
+func checkHeader(filename, kind string, version int, fileKind string, fileVersion int) error {
+	if fileKind != kind {
+		return fmt.Errorf("LoadModel: %s contains a %s model, not %s", filename, fileKind, kind)
+	}
+	if fileVersion != version {
+		return fmt.Errorf("LoadModel: %s has %s version %d, only version %d is supported",
+			filename, kind, fileVersion, version)
+	}
+	return nil
+}
+
+// Does the file name indicate JSON format?
+func isJSON(filename string) bool {
+	return strings.HasSuffix(strings.ToLower(filename), ".json")
+}
+
+// A matrix in a form that can be saved, nil matrices are saved as 0 x 0
+type MatrixData struct {
+	Rows, Cols int
+	Data       []float64
+}
+
+// Convert a matrix to a form that can be saved
+func ToMatrixData(m *mat.Dense) MatrixData {
+	if m == nil || m.IsEmpty() {
+		return MatrixData{}
+	}
+	nr, nc := m.Dims()
+	d := MatrixData{Rows: nr, Cols: nc, Data: make([]float64, 0, nr*nc)}
+	for r := 0; r < nr; r++ {
+		d.Data = append(d.Data, m.RawRowView(r)...)
+	}
+	return d
+}
+
+// Convert saved matrix data back to a matrix, nil if empty
+func (d MatrixData) Dense() (*mat.Dense, error) {
+	if d.Rows == 0 && d.Cols == 0 && len(d.Data) == 0 {
+		return nil, nil
+	}
+	if d.Rows <= 0 || d.Cols <= 0 || len(d.Data) != d.Rows*d.Cols {
+		return nil, fmt.Errorf("invalid matrix data: %d x %d with %d values", d.Rows, d.Cols, len(d.Data))
+	}
+	return mat.NewDense(d.Rows, d.Cols, d.Data), nil
+}
@@ -0,0 +1,54 @@
+// Unit tests for saving and loading models
+
+package utils
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Test that data round-trips exactly in both formats, and that files
+// for the wrong kind of model or version are rejected
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	m := mat.NewDense(2, 2, []float64{math.Pi, -1e-300, 1.0 / 3, 12345.6789})
+	for _, name := range []string{"model.json", "model.gob"} {
+		filename := filepath.Join(dir, name)
+		if err := SaveModel(filename, "Test", 3, ToMatrixData(m)); err != nil {
+			t.Fatal(err)
+		}
+		var d MatrixData
+		if err := LoadModel(filename, "Test", 3, &d); err != nil {
+			t.Fatal(err)
+		}
+		m2, err := d.Dense()
+		if err != nil || !mat.Equal(m, m2) {
+			t.Errorf("%s: matrix did not round-trip", name)
+		}
+		if err := LoadModel(filename, "Other", 3, &d); err == nil {
+			t.Errorf("%s: loaded wrong kind of model", name)
+		}
+	}
+
+	// Change the version in a JSON file, to an older or a newer one
+	filename := filepath.Join(dir, "model.json")
+	b, _ := os.ReadFile(filename)
+	for _, version := range []int{2, 4} {
+		var f modelFile
+		json.Unmarshal(b, &f)
+		f.Version = version
+		b2, _ := json.Marshal(f)
+		os.WriteFile(filename, b2, 0644)
+		var d MatrixData
+		err := LoadModel(filename, "Test", 3, &d)
+		if err == nil || !strings.Contains(err.Error(), "version") {
+			t.Errorf("Loaded file with version %d: %v", version, err)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// Splitting data into training and test sets, and folds for
+// cross-validation. All random splits use a seeded random number
+// generator, so they can be repeated.
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// One fold for cross-validation: row numbers to train and test on
+type Fold struct {
+	Train, Test []int
+}
+
+// Extract rows from a dataframe, returns a new dataframe
+func (df *DataFrame) SelectRows(rows []int) *DataFrame {
+	df2 := df.CopyStructure()
+	for _, r := range rows {
+		df2.CopyRow(df, r)
+	}
+	return df2
+}
+
+// Extract rows from a matrix, returns a new matrix
+func SelectRows(m *mat.Dense, rows []int) *mat.Dense {
+	_, nc := m.Dims()
+	res := mat.NewDense(len(rows), nc, nil)
+	for i, r := range rows {
+		res.SetRow(i, m.RawRowView(r))
+	}
+	return res
+}
+
+// Split a dataframe randomly into training and test sets, with the given
+// fraction of rows in the test set. If stratify is the name of a column
+// (usually the label), each of its values has the same proportion of rows
+// in the test set.
+func TrainTestSplit(df *DataFrame, testFrac float64, stratify string, seed int64) (*DataFrame, *DataFrame) {
+	var groups [][]int
+	if len(stratify) > 0 {
+		col := df.GetColumn(stratify)
+		Assert(col != nil, "TrainTestSplit: no column "+stratify)
+		groups = groupRows(col.Keys())
+	} else {
+		groups = [][]int{seq(df.NRows())}
+	}
+	train, test := splitGroups(groups, testFrac, seed)
+	return df.SelectRows(train), df.SelectRows(test)
+}
+
+// Split X and Y matrices randomly into training and test sets, with the
+// given fraction of rows in the test set, optionally stratified by the
+// values in Y (first column). Returns X train, X test, Y train, Y test.
+func MatTrainTestSplit(X, Y *mat.Dense, testFrac float64, stratify bool, seed int64) (*mat.Dense, *mat.Dense, *mat.Dense, *mat.Dense) {
+	nr, _ := X.Dims()
+	var groups [][]int
+	if stratify {
+		groups = groupRows(matColumn(Y))
+	} else {
+		groups = [][]int{seq(nr)}
+	}
+	train, test := splitGroups(groups, testFrac, seed)
+	return SelectRows(X, train), SelectRows(X, test), SelectRows(Y, train), SelectRows(Y, test)
+}
+
+// Divide rows 0..n-1 into k folds, each used once as the test set, with
+// the remaining rows used for training. Rows are shuffled first if
+// required, otherwise each test set is a consecutive block of rows.
+func KFold(n, k int, shuffle bool, seed int64) []Fold {
+	Assert(k >= 2 && k <= n, "KFold: invalid number of folds")
+	rows := seq(n)
+	if shuffle {
+		rng := rand.New(rand.NewSource(seed))
+		rng.Shuffle(n, func(i, j int) { rows[i], rows[j] = rows[j], rows[i] })
+	}
+	assign := make([]int, n) // fold number for each row
+	for i, r := range rows {
+		assign[r] = i * k / n
+	}
+	return makeFolds(assign, k)
+}
+
+// Divide rows into k folds, keeping the proportion of each label about
+// the same in every fold
+func StratifiedKFold[T int | int64 | float64 | string](labels []T, k int, shuffle bool, seed int64) []Fold {
+	Assert(k >= 2 && k <= len(labels), "StratifiedKFold: invalid number of folds")
+	rng := rand.New(rand.NewSource(seed))
+	assign := make([]int, len(labels))
+	next := 0 // next fold to assign, continues across labels to balance fold sizes
+	for _, rows := range groupRows(labels) {
+		if shuffle {
+			rng.Shuffle(len(rows), func(i, j int) { rows[i], rows[j] = rows[j], rows[i] })
+		}
+		for _, r := range rows {
+			assign[r] = next
+			next = (next + 1) % k
+		}
+	}
+	return makeFolds(assign, k)
+}
+
+// One fold per row, testing on just that row
+func LeaveOneOut(n int) []Fold {
+	assign := seq(n)
+	return makeFolds(assign, n)
+}
+
+// Folds for time series data, where the rows are in time order: each fold
+// trains on all rows before its test set, so the model never sees the
+// future. Data is divided into k+1 blocks, the first block is only used
+// for training.
+func TimeSeriesSplit(n, k int) []Fold {
+	Assert(k >= 1 && k < n, "TimeSeriesSplit: invalid number of folds")
+	size := n / (k + 1)
+	folds := []Fold{}
+	for i := 0; i < k; i++ {
+		start := n - (k-i)*size
+		folds = append(folds, Fold{Train: seqRange(0, start), Test: seqRange(start, start+size)})
+	}
+	return folds
+}
+
+// Train and score a model on each fold, returns the score for each fold.
+// A new model is created for each fold. The score function compares
+// actual and predicted values for the test rows (e.g., accuracy).
+func CrossValidate(newModel func() Estimator, X, Y *mat.Dense, folds []Fold,
+	score func(actual, pred *mat.Dense) float64) ([]float64, error) {
+	if len(folds) == 0 {
+		return nil, errors.New("CrossValidate: no folds")
+	}
+	scores := []float64{}
+	for i, f := range folds {
+		m := newModel()
+		if err := m.Fit(SelectRows(X, f.Train), SelectRows(Y, f.Train)); err != nil {
+			return nil, fmt.Errorf("CrossValidate: fold %d: %w", i, err)
+		}
+		Ytest := SelectRows(Y, f.Test)
+		scores = append(scores, score(Ytest, m.Predict(SelectRows(X, f.Test))))
+	}
+	return scores, nil
+}
+
+// Mean and standard deviation of a list of scores
+func MeanStd(x []float64) (float64, float64) {
+	var mean, v float64
+	for _, s := range x {
+		mean += s
+	}
+	mean /= float64(len(x))
+	for _, s := range x {
+		v += (s - mean) * (s - mean)
+	}
+	return mean, math.Sqrt(v / float64(len(x)))
+}
+
+// Group row numbers by label, groups are in order of the sorted labels
+func groupRows[T int | int64 | float64 | string](labels []T) [][]int {
+	index := map[T]int{}
+	for i, l := range Unique(labels) {
+		index[l] = i
+	}
+	groups := make([][]int, len(index))
+	for r, l := range labels {
+		groups[index[l]] = append(groups[index[l]], r)
+	}
+	return groups
+}
+
+// Split groups of rows randomly into train and test rows, taking the
+// given fraction of each group for testing
+func splitGroups(groups [][]int, testFrac float64, seed int64) ([]int, []int) {
+	Assert(testFrac > 0 && testFrac < 1, "splitGroups: test fraction must be between 0 and 1")
+	rng := rand.New(rand.NewSource(seed))
+	train, test := []int{}, []int{}
+	for _, rows := range groups {
+		rows = append([]int{}, rows...)
+		rng.Shuffle(len(rows), func(i, j int) { rows[i], rows[j] = rows[j], rows[i] })
+		nTest := int(math.Round(testFrac * float64(len(rows))))
+		test = append(test, rows[:nTest]...)
+		train = append(train, rows[nTest:]...)
+	}
+	return train, test
+}
+
+// Create folds from the fold number assigned to each row
+func makeFolds(assign []int, k int) []Fold {
+	folds := make([]Fold, k)
+	for f := 0; f < k; f++ {
+		for r, a := range assign {
+			if a == f {
+				folds[f].Test = append(folds[f].Test, r)
+			} else {
+				folds[f].Train = append(folds[f].Train, r)
+			}
+		}
+	}
+	return folds
+}
+
+// List of numbers 0..n-1
+func seq(n int) []int {
+	return seqRange(0, n)
+}
+
+// List of numbers from start up to (not including) end
+func seqRange(start, end int) []int {
+	res := make([]int, 0, end-start)
+	for i := start; i < end; i++ {
+		res = append(res, i)
+	}
+	return res
+}
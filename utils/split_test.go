@@ -0,0 +1,140 @@
+// Unit tests for train/test splits and cross-validation folds
+
+package utils
+
+import (
+	"sort"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Check that folds cover every row exactly once in the test sets, and
+// that no row is in both the train and test set of a fold
+func checkFolds(t *testing.T, name string, folds []Fold, n int) {
+	tested := []int{}
+	for _, f := range folds {
+		for _, r := range f.Test {
+			if In(r, f.Train) {
+				t.Errorf("%s: row %d in both train and test", name, r)
+			}
+		}
+		if len(f.Train)+len(f.Test) != n {
+			t.Errorf("%s: fold does not use all rows", name)
+		}
+		tested = append(tested, f.Test...)
+	}
+	sort.Ints(tested)
+	for i, r := range tested {
+		if r != i {
+			t.Errorf("%s: rows not tested exactly once", name)
+			break
+		}
+	}
+}
+
+// Test splitting dataframes and matrices
+func TestSplits(t *testing.T) {
+
+	// Stratified split of Iris keeps the 50/50/50 balance
+	df, err := ReadCSV("../data/iris.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	train, test := TrainTestSplit(df, .2, "variety", 42)
+	if train.NRows() != 120 || test.NRows() != 30 {
+		t.Errorf("Split into %d and %d rows", train.NRows(), test.NRows())
+	}
+	for _, v := range Unique(test.GetColumn("variety").Strings) {
+		n := 0
+		for _, s := range test.GetColumn("variety").Strings {
+			if s == v {
+				n++
+			}
+		}
+		if n != 10 {
+			t.Errorf("Test set has %d of %s instead of 10", n, v)
+		}
+	}
+
+	// Same seed gives same split
+	_, test2 := TrainTestSplit(df, .2, "variety", 42)
+	if !sameFloats(test.GetColumn("sepal_length").Floats, test2.GetColumn("sepal_length").Floats) {
+		t.Error("Same seed gave a different split")
+	}
+
+	// Matrix split
+	X := mat.NewDense(10, 1, []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	Xtr, Xte, Ytr, Yte := MatTrainTestSplit(X, X, .3, false, 1)
+	if MatRows(Xtr) != 7 || MatRows(Xte) != 3 || !mat.Equal(Xtr, Ytr) || !mat.Equal(Xte, Yte) {
+		t.Error("Matrix split failed")
+	}
+}
+
+// Test the different types of folds
+func TestFolds(t *testing.T) {
+	checkFolds(t, "KFold", KFold(10, 3, false, 0), 10)
+	checkFolds(t, "KFold shuffled", KFold(10, 3, true, 5), 10)
+	checkFolds(t, "LeaveOneOut", LeaveOneOut(5), 5)
+	labels := []string{"a", "a", "a", "a", "b", "b", "b", "b", "b", "b"}
+	folds := StratifiedKFold(labels, 2, true, 3)
+	checkFolds(t, "StratifiedKFold", folds, 10)
+	for _, f := range folds {
+		nb := 0
+		for _, r := range f.Test {
+			if labels[r] == "b" {
+				nb++
+			}
+		}
+		if nb != 3 {
+			t.Errorf("Stratified fold has %d b labels instead of 3", nb)
+		}
+	}
+
+	// Time series folds only train on earlier rows
+	for _, f := range TimeSeriesSplit(10, 4) {
+		if Max(f.Train) >= Min(f.Test) {
+			t.Errorf("Time series fold trains on the future: %v", f)
+		}
+	}
+}
+
+// Simple model that predicts the mean, to test cross-validation
+type meanModel struct{ mean float64 }
+
+func (m *meanModel) Fit(X, Y *mat.Dense) error {
+	m.mean = mat.Sum(Y) / float64(MatRows(Y))
+	return nil
+}
+
+func (m *meanModel) Predict(X *mat.Dense) *mat.Dense {
+	res := mat.NewDense(MatRows(X), 1, nil)
+	for i := 0; i < MatRows(X); i++ {
+		res.Set(i, 0, m.mean)
+	}
+	return res
+}
+
+// Test cross-validation, each fold trains on the other one
+func TestCrossValidate(t *testing.T) {
+	X := mat.NewDense(4, 1, nil)
+	Y := mat.NewDense(4, 1, []float64{1, 1, 3, 3})
+	scores, err := CrossValidate(func() Estimator { return &meanModel{} }, X, Y, KFold(4, 2, false, 0),
+		func(actual, pred *mat.Dense) float64 { return pred.At(0, 0) })
+	if err != nil || !sameFloats(scores, []float64{3, 1}) {
+		t.Errorf("CrossValidate gave %v, %v", scores, err)
+	}
+}
+
+// Are two lists exactly the same?
+func sameFloats(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+// Sample weights and class weights, so that some rows count more than
+// others in training, e.g., to make up for imbalanced classes
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Class weight mode that gives each class the same total weight, i.e.,
+// each row of a class is weighted by n / (classes * rows of the class),
+// as class_weight="balanced" in scikit-learn
+const ClassWeightBalanced = "balanced"
+
+// Check a class weight setting, which is "" for none or "balanced"
+func CheckClassWeight(classWeight string) error {
+	if classWeight != "" && classWeight != ClassWeightBalanced {
+		return fmt.Errorf("unknown class weight %q", classWeight)
+	}
+	return nil
+}
+
+// Weight of row i, 1 if there are no weights
+func Weight(weights []float64, i int) float64 {
+	if weights == nil {
+		return 1
+	}
+	return weights[i]
+}
+
+// Work out the weight of each row for training, from optional sample
+// weights (one per label) and an optional class weight mode. Returns nil
+// if there are neither, meaning all rows count the same. Otherwise the
+// weights are scaled so that their mean is 1, so they do not change the
+// size of the loss, or the best learning rate.
+func SampleWeights[T int | int64 | float64 | byte | string](labels []T, weights []float64, classWeight string) ([]float64, error) {
+	if err := CheckClassWeight(classWeight); err != nil {
+		return nil, err
+	}
+	if weights == nil && classWeight == "" {
+		return nil, nil
+	}
+	n := len(labels)
+	if weights != nil && len(weights) != n {
+		return nil, fmt.Errorf("%d sample weights for %d rows", len(weights), n)
+	}
+
+	// Start with the sample weights, or all ones
+	res := make([]float64, n)
+	for i := range res {
+		res[i] = 1
+		if weights != nil {
+			if weights[i] < 0 {
+				return nil, fmt.Errorf("sample weights must not be negative, got %v", weights[i])
+			}
+			res[i] = weights[i]
+		}
+	}
+
+	// Multiply by the class weights
+	if classWeight == ClassWeightBalanced {
+		counts := map[T]int{}
+		for _, l := range labels {
+			counts[l]++
+		}
+		for i, l := range labels {
+			res[i] *= float64(n) / float64(len(counts)*counts[l])
+		}
+	}
+
+	// Scale so the mean is 1
+	var tot float64
+	for _, w := range res {
+		tot += w
+	}
+	if tot == 0 {
+		return nil, errors.New("sample weights are all zero")
+	}
+	for i := range res {
+		res[i] *= float64(n) / tot
+	}
+	return res, nil
+}
@@ -0,0 +1,51 @@
+// Unit tests for sample and class weights
+
+package utils
+
+import (
+	"testing"
+)
+
+// Test combining sample weights and class weights
+func TestSampleWeights(t *testing.T) {
+	labels := []string{"a", "a", "a", "b"}
+
+	// No weights means nil, i.e., all rows count the same
+	if w, err := SampleWeights(labels, nil, ""); w != nil || err != nil {
+		t.Error("Expected no weights")
+	}
+	if Weight(nil, 3) != 1 || Weight([]float64{.5, 2}, 1) != 2 {
+		t.Error("Wrong weight of a row")
+	}
+
+	// Balanced: each class gets the same total, and the mean is 1
+	w, err := SampleWeights(labels, nil, ClassWeightBalanced)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Same(w, []float64{2.0 / 3, 2.0 / 3, 2.0 / 3, 2}) {
+		t.Errorf("Wrong balanced weights %v", w)
+	}
+
+	// Sample weights are scaled to a mean of 1, then multiplied by the
+	// class weights
+	w, _ = SampleWeights(labels, []float64{1, 1, 2, 4}, "")
+	if !Same(w, []float64{.5, .5, 1, 2}) {
+		t.Errorf("Wrong sample weights %v", w)
+	}
+	w, _ = SampleWeights(labels, []float64{1, 1, 1, 3}, ClassWeightBalanced)
+	if !Same(w, []float64{1.0 / 3, 1.0 / 3, 1.0 / 3, 3}) {
+		t.Errorf("Wrong combined weights %v", w)
+	}
+
+	// Invalid weights
+	if _, err := SampleWeights(labels, []float64{1, 2}, ""); err == nil {
+		t.Error("Accepted wrong number of weights")
+	}
+	if _, err := SampleWeights(labels, []float64{1, 1, -1, 1}, ""); err == nil {
+		t.Error("Accepted negative weight")
+	}
+	if _, err := SampleWeights(labels, nil, "equal"); err == nil {
+		t.Error("Accepted unknown class weight")
+	}
+}